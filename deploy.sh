@@ -18,7 +18,14 @@ trap 'exit 1' ERR
 if [ ! -f "$GUESTS_DB" ]; then
     echo "$GUESTS_DB does not exist. Copying from S3..."
     
-    aws s3 cp "s3://$S3_BUCKET_BACKUPS/$GUESTS_DB" "$GUESTS_DB"
+    # Backups are encrypted, so decrypt with the new binary using the key in .env
+    if aws s3 cp "s3://$S3_BUCKET_BACKUPS/$GUESTS_DB.enc" "$GUESTS_DB.enc"; then
+        ./"$GO_APP"-temp decrypt "$GUESTS_DB.enc" "$GUESTS_DB"
+        rm -f "$GUESTS_DB.enc"
+    else
+        # Fall back to a backup from before they were encrypted
+        aws s3 cp "s3://$S3_BUCKET_BACKUPS/$GUESTS_DB" "$GUESTS_DB"
+    fi
     
     if [ ! -f "$GUESTS_DB" ]; then
         echo "$GUESTS_DB could not be copied from S3. Attempting to copy fallback file..."
@@ -1,31 +1,71 @@
 package backup
 
 import (
-	"io"
+	"database/sql"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
 
-	"golang.org/x/sys/unix"
+	_ "github.com/mattn/go-sqlite3"
 )
 
-func BackupDatabaseLocally(dbPath, backupPath string) error {
-	dbFile, err := os.OpenFile(dbPath, os.O_RDONLY, 0666)
+// BackupDatabaseLocally writes a consistent copy of db to backupPath with
+// VACUUM INTO, which SQLite runs inside a read transaction so writes made
+// during the backup (including any still in the WAL) can't corrupt it. The
+// copy is integrity checked before it is handed back for uploading.
+func BackupDatabaseLocally(db *sql.DB, backupPath string) error {
+	// VACUUM INTO refuses to overwrite an existing file
+	err := os.Remove(backupPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove old backup: %v", err)
+	}
+
+	_, err = db.Exec(`VACUUM INTO ?`, backupPath)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to back up database: %v", err)
 	}
-	defer dbFile.Close()
 
-	// Acquire a lock on the database file
-	if err := unix.Flock(int(dbFile.Fd()), unix.LOCK_EX); err != nil {
+	if err := CheckIntegrity(backupPath); err != nil {
+		os.Remove(backupPath)
 		return err
 	}
-	defer unix.Flock(int(dbFile.Fd()), unix.LOCK_UN) // Ensure the lock is released
 
-	backupFile, err := os.Create(backupPath)
+	return nil
+}
+
+// CheckIntegrity opens the database file at path read only and runs SQLite's
+// integrity check against it.
+func CheckIntegrity(path string) error {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to open backup: %v", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return fmt.Errorf("failed to check backup integrity: %v", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return fmt.Errorf("failed to check backup integrity: %v", err)
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to check backup integrity: %v", err)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("backup failed integrity check: %s", strings.Join(problems, "; "))
 	}
-	defer backupFile.Close()
 
-	_, err = io.Copy(backupFile, dbFile)
-	return err
+	return nil
 }
@@ -0,0 +1,252 @@
+package backup
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// ListBackups returns the database backups in the target, newest first.
+func ListBackups(target BackupTarget) ([]Backup, error) {
+	keys, err := target.ListFiles(DBBackupPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return ParseBackups(keys), nil
+}
+
+// PruneBackups deletes the backups the retention policy doesn't keep, along
+// with the increments only needed by them, and returns them. With dryRun
+// nothing is deleted.
+func PruneBackups(target BackupTarget, r Retention, dryRun bool) ([]Backup, []Increment, error) {
+	backups, err := ListBackups(target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keep, remove := Prune(backups, r)
+	if !dryRun {
+		for i, b := range remove {
+			if err := target.DeleteFile(b.Key); err != nil {
+				return remove[:i], nil, err
+			}
+		}
+	}
+	if len(keep) == 0 {
+		return remove, nil, nil
+	}
+
+	increments, err := PruneIncrements(target, keep[len(keep)-1].Date, dryRun)
+	return remove, increments, err
+}
+
+// RunPruneCommand deletes the backups in every target which fall outside the
+// retention policy, or with -dry-run prints which would be deleted.
+func RunPruneCommand(targets []BackupTarget, r Retention, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("prune-backups", flag.ContinueOnError)
+	flags.SetOutput(out)
+	dryRun := flags.Bool("dry-run", false, "print the backups which would be deleted without deleting them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if !r.Enabled() {
+		return fmt.Errorf("no retention policy is set, set BACKUP_KEEP_DAILY, BACKUP_KEEP_WEEKLY or BACKUP_KEEP_MONTHLY")
+	}
+
+	verb := "Deleted"
+	if *dryRun {
+		verb = "Would delete"
+	}
+	fmt.Fprintf(out, "Keeping %s backups.\n", r)
+
+	var failed []string
+	for _, target := range targets {
+		removed, increments, err := PruneBackups(target, r, *dryRun)
+		fmt.Fprintf(out, "%s: %s %d backups and %d increments\n", target.Name(), strings.ToLower(verb), len(removed), len(increments))
+		for _, b := range removed {
+			fmt.Fprintf(out, "  %s\n", b.Key)
+		}
+		for _, inc := range increments {
+			fmt.Fprintf(out, "  %s\n", inc.Key)
+		}
+		if err != nil {
+			fmt.Fprintf(out, "  error: %v\n", err)
+			failed = append(failed, target.Name())
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to prune %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// RunRestoreCommand lists the backups in a target, the first unless -from
+// names another, or given a date or key downloads that backup, decrypts it with
+// key if it was encrypted, checks its integrity and replaces the database at
+// dbPath with it. With -at it instead restores the database as it was at that
+// time, replaying the increments shipped since the last full backup before it.
+// The current database is kept alongside in case the restore needs undoing.
+// The server should be stopped first as it holds the database open.
+func RunRestoreCommand(targets []BackupTarget, key []byte, dbPath string, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	flags.SetOutput(out)
+	dryRun := flags.Bool("dry-run", false, "download and check the backup without replacing the database")
+	from := flags.String("from", "", "name of the backup target to restore from, defaults to the first")
+	at := flags.String("at", "", "restore the database as it was at this UTC time, as YYYY-MM-DD HH:MM[:SS]")
+	flags.Usage = func() {
+		fmt.Fprintln(out, "usage: restore [-dry-run] [-from target] [YYYY-MM-DD | key | -at \"YYYY-MM-DD HH:MM[:SS]\"]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	target, err := findTarget(targets, *from)
+	if err != nil {
+		return err
+	}
+
+	backups, err := ListBackups(target)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 && *at == "" {
+		if len(backups) == 0 {
+			fmt.Fprintf(out, "There are no backups in %s.\n", target.Name())
+			return nil
+		}
+		fmt.Fprintf(out, "%d backups available in %s, newest first:\n", len(backups), target.Name())
+		for _, b := range backups {
+			fmt.Fprintf(out, "  %s  %s\n", b.Date.Format(dbBackupDateLayout), b.Key)
+		}
+		fmt.Fprintln(out, "\nRun restore with a date to restore that backup, or with -at to restore to a moment since the oldest.")
+		return nil
+	}
+	if flags.NArg() > 1 || (flags.NArg() == 1 && *at != "") {
+		flags.Usage()
+		return fmt.Errorf("expected one backup or time to restore to, got %d", flags.NArg())
+	}
+
+	downloadPath := dbPath + ".restore"
+	defer os.Remove(downloadPath)
+
+	var restored string
+	if *at != "" {
+		t, err := parseRestoreTime(*at)
+		if err != nil {
+			return err
+		}
+		restored, err = restoreToTime(target, key, backups, t, downloadPath, out)
+		if err != nil {
+			return err
+		}
+	} else {
+		chosen, err := findBackup(backups, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		if err := downloadBackup(target, key, chosen.Key, chosen.Encrypted, downloadPath); err != nil {
+			return err
+		}
+		restored = chosen.Key
+	}
+
+	if err := CheckIntegrity(downloadPath); err != nil {
+		return fmt.Errorf("not restoring %s: %v", restored, err)
+	}
+	fmt.Fprintf(out, "%s passed the integrity check.\n", restored)
+
+	if *dryRun {
+		fmt.Fprintln(out, "Nothing has been restored. Run again without -dry-run to restore it.")
+		return nil
+	}
+
+	if err := logRestore(target, downloadPath); err != nil {
+		return err
+	}
+
+	keptPath, err := setAsideDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(downloadPath, dbPath); err != nil {
+		return fmt.Errorf("failed to replace database: %v", err)
+	}
+
+	fmt.Fprintf(out, "Restored %s to %s.\n", restored, dbPath)
+	if keptPath != "" {
+		fmt.Fprintf(out, "The previous database has been kept at %s.\n", keptPath)
+	}
+	return nil
+}
+
+// downloadBackup downloads the file stored at backupKey to filePath,
+// decrypting it with key if it was encrypted.
+func downloadBackup(target BackupTarget, key []byte, backupKey string, encrypted bool, filePath string) error {
+	if !encrypted {
+		return target.DownloadFile(backupKey, filePath)
+	}
+	if key == nil {
+		return fmt.Errorf("%s is encrypted, set BACKUP_ENCRYPTION_KEY to restore it", backupKey)
+	}
+
+	encryptedPath := filePath + EncryptedSuffix
+	if err := target.DownloadFile(backupKey, encryptedPath); err != nil {
+		return err
+	}
+	defer os.Remove(encryptedPath)
+
+	return DecryptFile(key, encryptedPath, filePath)
+}
+
+func findTarget(targets []BackupTarget, name string) (BackupTarget, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no backup targets are configured")
+	}
+	if name == "" {
+		return targets[0], nil
+	}
+
+	var names []string
+	for _, target := range targets {
+		if target.Name() == name {
+			return target, nil
+		}
+		names = append(names, target.Name())
+	}
+	return nil, fmt.Errorf("there is no backup target %q, the targets are: %s", name, strings.Join(names, ", "))
+}
+
+func findBackup(backups []Backup, want string) (Backup, error) {
+	for _, b := range backups {
+		if b.Key == want || b.Date.Format(dbBackupDateLayout) == want {
+			return b, nil
+		}
+	}
+	return Backup{}, fmt.Errorf("there is no backup %q, run restore without arguments to list them", want)
+}
+
+// setAsideDatabase renames the database at dbPath, along with any journal
+// SQLite left next to it which would otherwise be applied to the restored
+// database, and returns where it was moved to.
+func setAsideDatabase(dbPath string) (string, error) {
+	if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+
+	keptPath := fmt.Sprintf("%s.before-restore-%s", dbPath, time.Now().Format("20060102-150405"))
+	for _, suffix := range []string{"", "-journal", "-wal", "-shm"} {
+		err := os.Rename(dbPath+suffix, keptPath+suffix)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("failed to move current database aside: %v", err)
+		}
+	}
+	return keptPath, nil
+}
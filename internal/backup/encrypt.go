@@ -0,0 +1,170 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// EncryptedSuffix is added to the name of every file encrypted before upload.
+const EncryptedSuffix = ".enc"
+
+const keyLen = 32
+
+// header starts every encrypted file so the format can change later.
+var header = []byte("wedding-rsvps-backup-v1\n")
+
+var ErrNotEncrypted = errors.New("file is not an encrypted backup")
+
+// ParseKey decodes a hex encoded 32 byte key, as generated by
+// `openssl rand -hex 32`.
+func ParseKey(hexKey string) ([]byte, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, fmt.Errorf("backup encryption key is not hex: %v", err)
+	}
+	if len(key) != keyLen {
+		return nil, fmt.Errorf("backup encryption key is %v bytes long when it should be %v", len(key), keyLen)
+	}
+	return key, nil
+}
+
+// Encrypt seals plaintext with a random data key, which is itself sealed with
+// key and stored alongside. Both use AES-256-GCM, so tampering with any part of
+// the file is caught on decryption.
+func Encrypt(key, plaintext []byte) ([]byte, error) {
+	dataKey := make([]byte, keyLen)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	out := append([]byte{}, header...)
+	out, err := seal(key, out, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return seal(dataKey, out, plaintext)
+}
+
+// Decrypt reverses Encrypt.
+func Decrypt(key, ciphertext []byte) ([]byte, error) {
+	if !bytes.HasPrefix(ciphertext, header) {
+		return nil, ErrNotEncrypted
+	}
+	rest := ciphertext[len(header):]
+
+	dataKey, rest, err := open(key, ciphertext[:len(header)], rest, keyLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key, check the backup encryption key: %v", err)
+	}
+
+	plaintext, _, err := open(dataKey, ciphertext[:len(ciphertext)-len(rest)], rest, -1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt backup: %v", err)
+	}
+	return plaintext, nil
+}
+
+// seal appends a nonce and msg sealed with key to out, authenticating
+// everything already in out as well.
+func seal(key, out, msg []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	additionalData := append([]byte{}, out...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, msg, additionalData), nil
+}
+
+// open reads a nonce and a sealed message of msgLen bytes, or the rest of in
+// when msgLen is -1, returning the message and what follows it.
+func open(key, additionalData, in []byte, msgLen int) ([]byte, []byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sealedLen := len(in) - gcm.NonceSize()
+	if msgLen >= 0 {
+		sealedLen = msgLen + gcm.Overhead()
+	}
+	if sealedLen < gcm.Overhead() || gcm.NonceSize()+sealedLen > len(in) {
+		return nil, nil, errors.New("file is too short")
+	}
+
+	nonce := in[:gcm.NonceSize()]
+	sealed := in[gcm.NonceSize() : gcm.NonceSize()+sealedLen]
+	msg, err := gcm.Open(nil, nonce, sealed, additionalData)
+	if err != nil {
+		return nil, nil, err
+	}
+	return msg, in[gcm.NonceSize()+sealedLen:], nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptFile writes an encrypted copy of the file at src to dst.
+func EncryptFile(key []byte, src, dst string) error {
+	plaintext, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	ciphertext, err := Encrypt(key, plaintext)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt %v: %v", src, err)
+	}
+
+	return os.WriteFile(dst, ciphertext, 0600)
+}
+
+// DecryptFile writes the decrypted contents of the file at src to dst.
+func DecryptFile(key []byte, src, dst string) error {
+	ciphertext, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := Decrypt(key, ciphertext)
+	if err != nil {
+		return fmt.Errorf("%v: %w", src, err)
+	}
+
+	return os.WriteFile(dst, plaintext, 0600)
+}
+
+// UploadEncrypted encrypts the file at filePath and uploads it to the target
+// at backupKey with EncryptedSuffix added, so nothing readable leaves the
+// server.
+func UploadEncrypted(target BackupTarget, key []byte, filePath, backupKey string) error {
+	tmp, err := os.CreateTemp("", "backup-*"+EncryptedSuffix)
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := EncryptFile(key, filePath, tmp.Name()); err != nil {
+		return err
+	}
+
+	return target.UploadFile(tmp.Name(), backupKey+EncryptedSuffix)
+}
@@ -0,0 +1,123 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, keyLen)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	key := testKey(t)
+	for _, plaintext := range [][]byte{nil, []byte("x"), bytes.Repeat([]byte("guests"), 100000)} {
+		ciphertext, err := Encrypt(key, plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// a byte or two turns up in random ciphertext by chance
+		if len(plaintext) > 16 && bytes.Contains(ciphertext, plaintext) {
+			t.Error("ciphertext contains the plaintext")
+		}
+
+		got, err := Decrypt(key, ciphertext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("decrypted %d bytes, want %d", len(got), len(plaintext))
+		}
+	}
+}
+
+func TestEncryptFileRoundTrip(t *testing.T) {
+	key := testKey(t)
+	dir := t.TempDir()
+	src, enc, dst := filepath.Join(dir, "guests.db"), filepath.Join(dir, "guests.db.enc"), filepath.Join(dir, "restored.db")
+	plaintext := []byte("SQLite format 3\x00 and some rows")
+	if err := os.WriteFile(src, plaintext, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := EncryptFile(key, src, enc); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecryptFile(key, enc, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("restored %q, want %q", got, plaintext)
+	}
+
+	if err := DecryptFile(key, src, dst); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("decrypting a plain file returned %v, want ErrNotEncrypted", err)
+	}
+}
+
+func TestDecryptWrongKey(t *testing.T) {
+	ciphertext, err := Encrypt(testKey(t), []byte("guests"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(testKey(t), ciphertext); err == nil {
+		t.Error("decrypted with the wrong key")
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	key := testKey(t)
+	ciphertext, err := Encrypt(key, []byte("the guest list"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the file is the header, then the nonce and sealed data key, then the
+	// nonce and sealed body
+	nonceSize := 12
+	for name, offset := range map[string]int{
+		"header":   len(header) - 2,
+		"data key": len(header) + nonceSize + 3,
+		"body":     len(ciphertext) - 1,
+	} {
+		tampered := append([]byte{}, ciphertext...)
+		tampered[offset] ^= 0x01
+		if _, err := Decrypt(key, tampered); err == nil {
+			t.Errorf("decrypted with the %s tampered with", name)
+		}
+	}
+}
+
+func TestDecryptTruncated(t *testing.T) {
+	key := testKey(t)
+	ciphertext, err := Encrypt(key, []byte("the guest list"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, length := range []int{len(header), len(header) + 20, len(ciphertext) - 20, len(ciphertext) - 1} {
+		if _, err := Decrypt(key, ciphertext[:length]); err == nil {
+			t.Errorf("decrypted a file cut to %d of %d bytes", length, len(ciphertext))
+		}
+	}
+}
+
+func TestDecryptPlaintext(t *testing.T) {
+	if _, err := Decrypt(testKey(t), []byte("SQLite format 3\x00")); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("got %v, want ErrNotEncrypted", err)
+	}
+}
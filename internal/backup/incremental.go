@@ -0,0 +1,381 @@
+package backup
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/database"
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+// ChangesPrefix is where increments are uploaded, each a JSON line per change
+// named changes_<first id>-<last id>_YYYY-MM-DD.jsonl after the changes it
+// holds and the day the last of them was made.
+const ChangesPrefix = "backup_changes/"
+
+// changesPerIncrement caps how many changes go in one file, so catching up
+// after a long gap doesn't build one huge upload.
+const changesPerIncrement = 5000
+
+// ChangeTimeLayout is the layout of the UTC timestamps in the change log.
+const ChangeTimeLayout = "2006-01-02 15:04:05.000"
+
+// IncrementKey returns the key the increment holding changes first to last,
+// the last of which was made on date, is stored at.
+func IncrementKey(first, last int64, date time.Time) string {
+	return fmt.Sprintf("%schanges_%012d-%012d_%s.jsonl", ChangesPrefix, first, last, date.Format(dbBackupDateLayout))
+}
+
+// Increment is a file of changes stored in a target.
+type Increment struct {
+	Key       string
+	First     int64
+	Last      int64
+	Date      time.Time
+	Encrypted bool
+}
+
+// ParseIncrements picks out the increments from keys, oldest first.
+func ParseIncrements(keys []string) []Increment {
+	var increments []Increment
+	for _, key := range keys {
+		name := strings.TrimSuffix(path.Base(key), EncryptedSuffix)
+		if !strings.HasPrefix(key, ChangesPrefix) || !strings.HasPrefix(name, "changes_") || !strings.HasSuffix(name, ".jsonl") {
+			continue
+		}
+
+		fields := strings.Split(strings.TrimSuffix(strings.TrimPrefix(name, "changes_"), ".jsonl"), "_")
+		if len(fields) != 2 {
+			continue
+		}
+		ids := strings.Split(fields[0], "-")
+		if len(ids) != 2 {
+			continue
+		}
+		first, err := strconv.ParseInt(ids[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		last, err := strconv.ParseInt(ids[1], 10, 64)
+		if err != nil || first > last {
+			continue
+		}
+		date, err := time.Parse(dbBackupDateLayout, fields[1])
+		if err != nil {
+			continue
+		}
+
+		increments = append(increments, Increment{Key: key, First: first, Last: last, Date: date, Encrypted: strings.HasSuffix(key, EncryptedSuffix)})
+	}
+
+	sort.Slice(increments, func(i, j int) bool {
+		return increments[i].First < increments[j].First
+	})
+	return increments
+}
+
+// ListIncrements returns the increments in the target, oldest first.
+func ListIncrements(target BackupTarget) ([]Increment, error) {
+	keys, err := target.ListFiles(ChangesPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIncrements(keys), nil
+}
+
+// lastShipped returns the id of the last change in increments, or 0 if there
+// are none.
+func lastShipped(increments []Increment) int64 {
+	var last int64
+	for _, inc := range increments {
+		last = max(last, inc.Last)
+	}
+	return last
+}
+
+// ChangeSource is somewhere to read the change log from.
+type ChangeSource interface {
+	GetChanges(afterID int64, limit int) ([]models.Change, error)
+}
+
+// ShipChanges uploads every change logged since the last one in the target,
+// encrypted with key, and returns how many were shipped.
+func ShipChanges(target BackupTarget, key []byte, source ChangeSource) (int, error) {
+	increments, err := ListIncrements(target)
+	if err != nil {
+		return 0, err
+	}
+	after := lastShipped(increments)
+
+	dir, err := os.MkdirTemp("", "wedding-rsvps-changes-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	shipped := 0
+	for {
+		changes, err := source.GetChanges(after, changesPerIncrement)
+		if err != nil {
+			return shipped, fmt.Errorf("failed to read change log: %v", err)
+		}
+		if len(changes) == 0 {
+			return shipped, nil
+		}
+
+		first, last := changes[0], changes[len(changes)-1]
+		date, err := time.Parse(ChangeTimeLayout, last.CreatedAt)
+		if err != nil {
+			return shipped, fmt.Errorf("change %d has an invalid time %q", last.ID, last.CreatedAt)
+		}
+		incrementKey := IncrementKey(first.ID, last.ID, date)
+
+		filePath := filepath.Join(dir, path.Base(incrementKey))
+		if err := writeChanges(filePath, changes); err != nil {
+			return shipped, err
+		}
+		if err := UploadEncrypted(target, key, filePath, incrementKey); err != nil {
+			return shipped, err
+		}
+
+		shipped += len(changes)
+		after = last.ID
+	}
+}
+
+func writeChanges(filePath string, changes []models.Change) error {
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, change := range changes {
+		if err := enc.Encode(change); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+func readChanges(filePath string) ([]models.Change, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var changes []models.Change
+	dec := json.NewDecoder(file)
+	for dec.More() {
+		var change models.Change
+		if err := dec.Decode(&change); err != nil {
+			return nil, fmt.Errorf("failed to read changes: %v", err)
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// PruneIncrements deletes the increments which only hold changes from before
+// the oldest full backup kept, as there is nothing left to replay them onto,
+// and returns them. The newest increment is always kept as shipping carries on
+// from it. With dryRun nothing is deleted.
+func PruneIncrements(target BackupTarget, oldestKept time.Time, dryRun bool) ([]Increment, error) {
+	increments, err := ListIncrements(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var remove []Increment
+	for i, inc := range increments {
+		if i < len(increments)-1 && inc.Date.Before(oldestKept) {
+			remove = append(remove, inc)
+		}
+	}
+	if dryRun {
+		return remove, nil
+	}
+
+	for i, inc := range remove {
+		if err := target.DeleteFile(inc.Key); err != nil {
+			return remove[:i], err
+		}
+	}
+	return remove, nil
+}
+
+var restoreTimeLayouts = []string{"2006-01-02 15:04:05", "2006-01-02 15:04", time.RFC3339}
+
+func parseRestoreTime(s string) (time.Time, error) {
+	for _, layout := range restoreTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected YYYY-MM-DD HH:MM[:SS] in UTC", s)
+}
+
+// restoreToTime downloads the newest full backup taken before at to filePath
+// and replays the changes in the target's increments on top of it, up to the
+// last one made at or before at. It returns a description of what was
+// restored.
+func restoreToTime(target BackupTarget, key []byte, backups []Backup, at time.Time, filePath string, out io.Writer) (string, error) {
+	until := at.Format(ChangeTimeLayout)
+
+	db, base, position, err := openBackupBefore(target, key, backups, at, filePath, out)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	increments, err := ListIncrements(target)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := os.MkdirTemp("", "wedding-rsvps-changes-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	// each change must follow on from the one before, as a missing increment
+	// would otherwise quietly lose changes
+	var changes []models.Change
+	next := position + 1
+	done := false
+	for _, inc := range increments {
+		if done {
+			break
+		}
+		if inc.Last < next {
+			continue
+		}
+		if inc.First > next {
+			return "", fmt.Errorf("changes %d to %d are missing from %s, the earliest a restore from %s can reach is the time of change %d", next, inc.First-1, target.Name(), base.Key, next-1)
+		}
+
+		incPath := filepath.Join(dir, path.Base(strings.TrimSuffix(inc.Key, EncryptedSuffix)))
+		if err := downloadBackup(target, key, inc.Key, inc.Encrypted, incPath); err != nil {
+			return "", err
+		}
+		incChanges, err := readChanges(incPath)
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", inc.Key, err)
+		}
+
+		for _, change := range incChanges {
+			if change.ID < next {
+				continue
+			}
+			if change.ID != next {
+				return "", fmt.Errorf("%s is missing change %d", inc.Key, next)
+			}
+			if change.CreatedAt > until {
+				done = true
+				break
+			}
+			if change.Operation == models.ChangeRestore {
+				return "", fmt.Errorf("the database was restored from a backup at %s, restore from a full backup taken since then", change.CreatedAt)
+			}
+			changes = append(changes, change)
+			next++
+		}
+	}
+
+	if err := database.NewGuestStore(db).ApplyChanges(changes); err != nil {
+		return "", fmt.Errorf("failed to replay changes: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		return "", err
+	}
+
+	if len(changes) == 0 {
+		return fmt.Sprintf("%s with no changes since", base.Key), nil
+	}
+	last := changes[len(changes)-1]
+	if !done {
+		fmt.Fprintf(out, "The last change shipped to %s was at %s, there is nothing later to replay.\n", target.Name(), last.CreatedAt)
+	}
+	return fmt.Sprintf("%s with %d changes replayed up to %s", base.Key, len(changes), last.CreatedAt), nil
+}
+
+// openBackupBefore downloads the newest full backup whose last change was made
+// at or before at, and returns it opened along with the id of that change.
+func openBackupBefore(target BackupTarget, key []byte, backups []Backup, at time.Time, filePath string, out io.Writer) (*sql.DB, Backup, int64, error) {
+	until := at.Format(ChangeTimeLayout)
+
+	for _, b := range backups {
+		// backups are named after the day they were taken, so only the
+		// ones from the same day can have been taken after at
+		if b.Date.After(at) {
+			continue
+		}
+
+		if err := downloadBackup(target, key, b.Key, b.Encrypted, filePath); err != nil {
+			return nil, Backup{}, 0, err
+		}
+		db, err := sql.Open("sqlite3", filePath)
+		if err != nil {
+			return nil, Backup{}, 0, err
+		}
+
+		position, createdAt, ok, err := database.NewGuestStore(db).GetChangeLogPosition()
+		if err != nil {
+			db.Close()
+			return nil, Backup{}, 0, fmt.Errorf("failed to read the change log from %s: %v", b.Key, err)
+		}
+		if !ok {
+			db.Close()
+			return nil, Backup{}, 0, fmt.Errorf("%s was taken before changes were logged, so there is nothing to replay on top of it", b.Key)
+		}
+		if createdAt > until {
+			db.Close()
+			fmt.Fprintf(out, "Skipping %s, it was taken after %s.\n", b.Key, until)
+			continue
+		}
+
+		fmt.Fprintf(out, "Replaying changes on top of %s.\n", b.Key)
+		return db, b, position, nil
+	}
+
+	return nil, Backup{}, 0, fmt.Errorf("there is no full backup in %s from before %s", target.Name(), until)
+}
+
+// logRestore marks the database at filePath as restored, so changes made to
+// it aren't mixed up with the ones shipped from before the restore.
+func logRestore(target BackupTarget, filePath string) error {
+	increments, err := ListIncrements(target)
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.Open("sqlite3", filePath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := database.NewGuestStore(db).LogRestore(lastShipped(increments)); err != nil {
+		return err
+	}
+	return db.Close()
+}
@@ -0,0 +1,244 @@
+package backup
+
+import (
+	"database/sql"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/database"
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+// restoreTest is a database with a full backup taken at 09:00 and changes
+// made and shipped at 10:00 and 12:00 on 2024-06-01.
+type restoreTest struct {
+	db     *sql.DB
+	store  database.GuestStore
+	target *DirTarget
+	key    []byte
+	dir    string
+}
+
+func newRestoreTest(t *testing.T) *restoreTest {
+	t.Helper()
+	dir := t.TempDir()
+
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "guests.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	target, err := NewDirTarget(filepath.Join(dir, "backups"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt := &restoreTest{db: db, store: database.NewGuestStore(db), target: target, key: testKey(t), dir: dir}
+	if err := rt.store.SetupDatabase([][]string{{"Alice Smith"}, {"Bob Smith"}}); err != nil {
+		t.Fatal(err)
+	}
+	rt.stamp(t, "09:00")
+
+	backupPath := filepath.Join(dir, "backup.db")
+	if err := BackupDatabaseLocally(db, backupPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := UploadEncrypted(target, rt.key, backupPath, DBBackupKey(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))); err != nil {
+		t.Fatal(err)
+	}
+
+	return rt
+}
+
+// stamp dates every change not yet shipped at clock on 2024-06-01, so the
+// test controls when each change was made.
+func (rt *restoreTest) stamp(t *testing.T, clock string) {
+	t.Helper()
+	position, err := lastShippedID(rt.target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = rt.db.Exec(`UPDATE change_log SET created_at = ? WHERE id > ?`, "2024-06-01 "+clock+":00.000", position)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func lastShippedID(target BackupTarget) (int64, error) {
+	increments, err := ListIncrements(target)
+	if err != nil {
+		return 0, err
+	}
+	return lastShipped(increments), nil
+}
+
+func (rt *restoreTest) ship(t *testing.T, clock string) {
+	t.Helper()
+	rt.stamp(t, clock)
+	if n, err := ShipChanges(rt.target, rt.key, rt.store); err != nil {
+		t.Fatal(err)
+	} else if n == 0 {
+		t.Fatalf("nothing was shipped at %s", clock)
+	}
+}
+
+func (rt *restoreTest) code(t *testing.T, name string) string {
+	t.Helper()
+	code, err := rt.store.GetGuestCode(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// makeChanges renames Alice and has Bob accept at 10:00, then deletes Bob and
+// adds Carol at 12:00, shipping each.
+func (rt *restoreTest) makeChanges(t *testing.T, between func()) {
+	t.Helper()
+	alice, bob := rt.code(t, "Alice Smith"), rt.code(t, "Bob Smith")
+
+	if err := rt.store.RenameGuest(alice, "Alice Jones", models.ActorAPI); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.store.UpdateGuestAttendance(bob, true, false, models.ActorGuest); err != nil {
+		t.Fatal(err)
+	}
+	rt.ship(t, "10:00")
+
+	if between != nil {
+		between()
+	}
+
+	if err := rt.store.DeleteGuest(bob, models.ActorAPI); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.store.InsertGuest("Carol Jones"); err != nil {
+		t.Fatal(err)
+	}
+	rt.ship(t, "12:00")
+}
+
+func (rt *restoreTest) restore(at string) (map[string]models.Guest, error) {
+	t, err := parseRestoreTime(at)
+	if err != nil {
+		return nil, err
+	}
+	backups, err := ListBackups(rt.target)
+	if err != nil {
+		return nil, err
+	}
+
+	filePath := filepath.Join(rt.dir, "restored.db")
+	if _, err := restoreToTime(rt.target, rt.key, backups, t, filePath, io.Discard); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite3", filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	guests, err := database.NewGuestStore(db).GetAllGuests(true)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]models.Guest)
+	for _, guest := range guests {
+		byName[guest.Name] = guest
+	}
+	return byName, nil
+}
+
+func TestRestoreToTime(t *testing.T) {
+	rt := newRestoreTest(t)
+	rt.makeChanges(t, nil)
+
+	guests, err := rt.restore("2024-06-01 11:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(guests) != 2 {
+		t.Errorf("restored %d guests at 11:00, want 2: %v", len(guests), guests)
+	}
+	if _, ok := guests["Alice Jones"]; !ok {
+		t.Error("Alice's rename from 10:00 wasn't replayed")
+	}
+	if bob := guests["Bob Smith"]; !bob.Attendance || bob.Deleted {
+		t.Errorf("Bob at 11:00 = %+v, want accepted and not deleted", bob)
+	}
+	if _, ok := guests["Carol Jones"]; ok {
+		t.Error("Carol, added at 12:00, was restored at 11:00")
+	}
+
+	guests, err = rt.restore("2024-06-01 12:30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !guests["Bob Smith"].Deleted {
+		t.Error("Bob's deletion at 12:00 wasn't replayed")
+	}
+	if _, ok := guests["Carol Jones"]; !ok {
+		t.Error("Carol wasn't restored at 12:30")
+	}
+
+	guests, err = rt.restore("2024-06-01 09:30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := guests["Alice Smith"]; !ok {
+		t.Error("the backup from 09:00 wasn't restored as it was")
+	}
+
+	if _, err := rt.restore("2024-05-31 12:00"); err == nil {
+		t.Error("restored to a time before the oldest backup")
+	}
+}
+
+func TestRestoreToTimeMissingIncrement(t *testing.T) {
+	rt := newRestoreTest(t)
+	rt.makeChanges(t, nil)
+
+	increments, err := ListIncrements(rt.target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(increments) != 2 {
+		t.Fatalf("got %d increments, want 2", len(increments))
+	}
+	if err := rt.target.DeleteFile(increments[0].Key); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = rt.restore("2024-06-01 12:30")
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("restoring past a missing increment returned %v, want an error saying changes are missing", err)
+	}
+}
+
+func TestRestoreToTimeStopsAtRestoreMarker(t *testing.T) {
+	rt := newRestoreTest(t)
+	rt.makeChanges(t, func() {
+		lastShipped, err := lastShippedID(rt.target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := rt.store.LogRestore(lastShipped); err != nil {
+			t.Fatal(err)
+		}
+		rt.stamp(t, "11:00")
+	})
+
+	if _, err := rt.restore("2024-06-01 10:30"); err != nil {
+		t.Errorf("restoring to before the restore marker failed: %v", err)
+	}
+
+	_, err := rt.restore("2024-06-01 12:30")
+	if err == nil || !strings.Contains(err.Error(), "restored from a backup") {
+		t.Errorf("replaying past a restore marker returned %v, want an error", err)
+	}
+}
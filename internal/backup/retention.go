@@ -0,0 +1,112 @@
+package backup
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DBBackupPrefix is where the nightly database backups are uploaded, each
+// named guests_YYYY-MM-DD.db after the day they cover.
+const DBBackupPrefix = "backup_dbs/"
+
+const dbBackupDateLayout = "2006-01-02"
+
+// DBBackupKey returns the key the backup of the database on date is stored at.
+func DBBackupKey(date time.Time) string {
+	return fmt.Sprintf("%sguests_%s.db", DBBackupPrefix, date.Format(dbBackupDateLayout))
+}
+
+// Backup is a database backup stored in a target.
+type Backup struct {
+	Key       string
+	Date      time.Time
+	Encrypted bool
+}
+
+// ParseBackups picks out the database backups from keys, newest first. Keys
+// which don't look like a backup are ignored so they are never pruned.
+func ParseBackups(keys []string) []Backup {
+	var backups []Backup
+	for _, key := range keys {
+		name := strings.TrimSuffix(path.Base(key), EncryptedSuffix)
+		if !strings.HasPrefix(key, DBBackupPrefix) || !strings.HasPrefix(name, "guests_") || !strings.HasSuffix(name, ".db") {
+			continue
+		}
+		date, err := time.Parse(dbBackupDateLayout, strings.TrimSuffix(strings.TrimPrefix(name, "guests_"), ".db"))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{Key: key, Date: date, Encrypted: strings.HasSuffix(key, EncryptedSuffix)})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Date.After(backups[j].Date)
+	})
+	return backups
+}
+
+// Retention is how many daily, weekly and monthly backups to keep. The newest
+// backup of each of the last Daily days, Weekly weeks and Monthly months which
+// have a backup is kept, so a backup can be kept by more than one rule.
+type Retention struct {
+	Daily   int
+	Weekly  int
+	Monthly int
+}
+
+// Enabled reports whether anything should be pruned. With nothing configured
+// every backup is kept, as it was before retention existed.
+func (r Retention) Enabled() bool {
+	return r.Daily > 0 || r.Weekly > 0 || r.Monthly > 0
+}
+
+func (r Retention) String() string {
+	return fmt.Sprintf("%d daily, %d weekly, %d monthly", r.Daily, r.Weekly, r.Monthly)
+}
+
+// Prune splits backups, which must be sorted newest first, into the ones the
+// retention policy keeps and the ones which can be deleted.
+func Prune(backups []Backup, r Retention) (keep, remove []Backup) {
+	if !r.Enabled() {
+		return backups, nil
+	}
+
+	kept := make(map[string]bool)
+	keepNewest := func(n int, period func(time.Time) string) {
+		seen := make(map[string]bool)
+		for _, b := range backups {
+			p := period(b.Date)
+			if seen[p] {
+				continue
+			}
+			if len(seen) == n {
+				return
+			}
+			seen[p] = true
+			kept[b.Key] = true
+		}
+	}
+
+	keepNewest(r.Daily, func(t time.Time) string {
+		return t.Format(dbBackupDateLayout)
+	})
+	keepNewest(r.Weekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+	keepNewest(r.Monthly, func(t time.Time) string {
+		return t.Format("2006-01")
+	})
+
+	for _, b := range backups {
+		if kept[b.Key] {
+			keep = append(keep, b)
+		} else {
+			remove = append(remove, b)
+		}
+	}
+	return keep, remove
+}
@@ -0,0 +1,136 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const defaultS3Region = "eu-west-2"
+
+type S3Config struct {
+	Bucket string
+	// Region defaults to eu-west-2.
+	Region string
+	// Endpoint is only needed for S3 compatible storage other than AWS, e.g.
+	// http://localhost:4566 for LocalStack.
+	Endpoint string
+}
+
+// S3Target stores backups in an S3 bucket or any storage with an S3
+// compatible API.
+type S3Target struct {
+	S3Client *s3.Client
+	Bucket   string
+}
+
+func NewS3Target(cfg S3Config) (*S3Target, error) {
+	region := cfg.Region
+	if region == "" {
+		region = defaultS3Region
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("unable to load SDK config, %v", err)
+	}
+
+	s3Client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+			// most S3 compatible storage, including LocalStack, only
+			// supports path style bucket addressing
+			o.UsePathStyle = true
+		}
+	})
+
+	return &S3Target{
+		S3Client: s3Client,
+		Bucket:   cfg.Bucket,
+	}, nil
+}
+
+func (target *S3Target) Name() string {
+	return "s3://" + target.Bucket
+}
+
+func (target *S3Target) UploadFile(filePath string, s3FilePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file %v: %v", filePath, err)
+	}
+	defer file.Close()
+
+	_, err = target.S3Client.PutObject(context.TODO(), &s3.PutObjectInput{
+		Bucket: aws.String(target.Bucket),
+		Key:    aws.String(s3FilePath),
+		Body:   file,
+		ACL:    types.ObjectCannedACLPrivate,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to upload file to S3: %v", err)
+	}
+
+	return nil
+}
+
+func (target *S3Target) ListFiles(prefix string) ([]string, error) {
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(target.S3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(target.Bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files in S3: %v", err)
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, aws.ToString(object.Key))
+		}
+	}
+
+	return keys, nil
+}
+
+func (target *S3Target) DownloadFile(s3FilePath string, filePath string) error {
+	result, err := target.S3Client.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(target.Bucket),
+		Key:    aws.String(s3FilePath),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to download file from S3: %v", err)
+	}
+	defer result.Body.Close()
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create file %v: %v", filePath, err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, result.Body); err != nil {
+		return fmt.Errorf("failed to write file %v: %v", filePath, err)
+	}
+
+	return file.Close()
+}
+
+func (target *S3Target) DeleteFile(s3FilePath string) error {
+	_, err := target.S3Client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
+		Bucket: aws.String(target.Bucket),
+		Key:    aws.String(s3FilePath),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete file from S3: %v", err)
+	}
+
+	return nil
+}
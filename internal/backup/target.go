@@ -0,0 +1,140 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BackupTarget is somewhere backups are stored. Files are identified by a key,
+// a slash separated path such as backup_dbs/guests_2024-06-01.db.enc.
+type BackupTarget interface {
+	// Name identifies the target in logs and commands.
+	Name() string
+	UploadFile(filePath string, key string) error
+	// ListFiles returns the key of every file starting with prefix.
+	ListFiles(prefix string) ([]string, error)
+	DownloadFile(key string, filePath string) error
+	DeleteFile(key string) error
+}
+
+// DirTarget stores backups in a local directory, laid out the same as in a
+// bucket. Pointing it at a mounted disk or network share mirrors the backups
+// off the server without anything else to set up.
+type DirTarget struct {
+	Dir string
+}
+
+func NewDirTarget(dir string) (*DirTarget, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %v", err)
+	}
+	return &DirTarget{Dir: dir}, nil
+}
+
+func (target *DirTarget) Name() string {
+	return "dir:" + target.Dir
+}
+
+// path returns where key is stored, refusing keys which would end up outside
+// the directory.
+func (target *DirTarget) path(key string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(key)) {
+		return "", fmt.Errorf("invalid backup key %q", key)
+	}
+	return filepath.Join(target.Dir, filepath.FromSlash(key)), nil
+}
+
+func (target *DirTarget) UploadFile(filePath string, key string) error {
+	dst, err := target.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return fmt.Errorf("failed to create backup directory: %v", err)
+	}
+
+	// copy to a temporary file first so a half written backup never has the
+	// real name
+	tmp := dst + ".tmp"
+	if err := copyFile(filePath, tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save backup file: %v", err)
+	}
+
+	return nil
+}
+
+func (target *DirTarget) ListFiles(prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.WalkDir(target.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(target.Dir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backup files: %v", err)
+	}
+
+	return keys, nil
+}
+
+func (target *DirTarget) DownloadFile(key string, filePath string) error {
+	src, err := target.path(key)
+	if err != nil {
+		return err
+	}
+	return copyFile(src, filePath)
+}
+
+func (target *DirTarget) DeleteFile(key string) error {
+	path, err := target.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete backup file: %v", err)
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open file %v: %v", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create file %v: %v", dst, err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return fmt.Errorf("failed to write file %v: %v", dst, err)
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,239 @@
+package backup
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// testTarget runs every BackupTarget operation against target, so each kind
+// of target is held to the same behaviour.
+func testTarget(t *testing.T, target BackupTarget) {
+	t.Helper()
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"backup_dbs/guests_2024-06-01.db.enc":                                   "first",
+		"backup_dbs/guests_2024-06-02.db.enc":                                   "second",
+		"backup_changes/changes_000000000001-000000000002_2024-06-02.jsonl.enc": "changes",
+		"backup_logs/2024-06-01.log.enc":                                        "logs",
+	}
+	for key, contents := range files {
+		src := filepath.Join(dir, "upload")
+		if err := os.WriteFile(src, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := target.UploadFile(src, key); err != nil {
+			t.Fatalf("uploading %s: %v", key, err)
+		}
+	}
+
+	keys, err := target.ListFiles("backup_dbs/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(keys)
+	want := []string{"backup_dbs/guests_2024-06-01.db.enc", "backup_dbs/guests_2024-06-02.db.enc"}
+	if !slices.Equal(keys, want) {
+		t.Errorf("listed %v, want %v", keys, want)
+	}
+
+	all, err := target.ListFiles("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != len(files) {
+		t.Errorf("listed %d files with no prefix, want %d", len(all), len(files))
+	}
+
+	for key, contents := range files {
+		dst := filepath.Join(dir, "download")
+		if err := target.DownloadFile(key, dst); err != nil {
+			t.Fatalf("downloading %s: %v", key, err)
+		}
+		got, err := os.ReadFile(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != contents {
+			t.Errorf("downloaded %q from %s, want %q", got, key, contents)
+		}
+	}
+
+	if err := target.DownloadFile("backup_dbs/missing.db.enc", filepath.Join(dir, "missing")); err == nil {
+		t.Error("downloaded a file which doesn't exist")
+	}
+
+	if err := target.DeleteFile("backup_dbs/guests_2024-06-01.db.enc"); err != nil {
+		t.Fatal(err)
+	}
+	keys, err = target.ListFiles("backup_dbs/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(keys, []string{"backup_dbs/guests_2024-06-02.db.enc"}) {
+		t.Errorf("listed %v after deleting", keys)
+	}
+}
+
+func TestDirTarget(t *testing.T) {
+	target, err := NewDirTarget(filepath.Join(t.TempDir(), "backups"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTarget(t, target)
+}
+
+func TestDirTargetRejectsKeysOutsideDir(t *testing.T) {
+	root := t.TempDir()
+	target, err := NewDirTarget(filepath.Join(root, "backups"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(root, "upload")
+	if err := os.WriteFile(src, []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"../escaped", "backup_dbs/../../escaped", "/escaped", ""} {
+		if err := target.UploadFile(src, key); err == nil {
+			t.Errorf("uploaded to %q", key)
+		}
+		if err := target.DownloadFile(key, filepath.Join(root, "download")); err == nil {
+			t.Errorf("downloaded from %q", key)
+		}
+		if err := target.DeleteFile(key); err == nil {
+			t.Errorf("deleted %q", key)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "escaped")); err == nil {
+		t.Error("a file was written outside the backup directory")
+	}
+}
+
+func TestS3Target(t *testing.T) {
+	server := httptest.NewServer(newFakeS3("backups"))
+	t.Cleanup(server.Close)
+
+	client := s3.New(s3.Options{
+		Region:       "eu-west-2",
+		BaseEndpoint: aws.String(server.URL),
+		UsePathStyle: true,
+		Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "test", SecretAccessKey: "test"}, nil
+		}),
+	})
+	testTarget(t, &S3Target{S3Client: client, Bucket: "backups"})
+}
+
+// fakeS3 is just enough of the S3 API, with path style addressing, for
+// S3Target. Listings are split into pages of two so paging is exercised.
+type fakeS3 struct {
+	bucket string
+
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+const fakeS3PageSize = 2
+
+func newFakeS3(bucket string) *fakeS3 {
+	return &fakeS3{bucket: bucket, objects: make(map[string][]byte)}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case req.Method == http.MethodGet && key == "":
+		f.list(w, req)
+	case req.Method == http.MethodPut:
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = body
+	case req.Method == http.MethodGet:
+		body, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `<Error><Code>NoSuchKey</Code></Error>`)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Write(body)
+	case req.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "NotImplemented", http.StatusNotImplemented)
+	}
+}
+
+type listBucketResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	Name                  string
+	Prefix                string
+	KeyCount              int
+	IsTruncated           bool
+	NextContinuationToken string `xml:",omitempty"`
+	Contents              []struct {
+		Key  string
+		Size int
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, req *http.Request) {
+	prefix := req.URL.Query().Get("prefix")
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	// the continuation token is the key to carry on after
+	if after := req.URL.Query().Get("continuation-token"); after != "" {
+		i, _ := slices.BinarySearch(keys, after)
+		keys = keys[min(i+1, len(keys)):]
+	}
+
+	result := listBucketResult{Name: f.bucket, Prefix: prefix}
+	if len(keys) > fakeS3PageSize {
+		keys = keys[:fakeS3PageSize]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[len(keys)-1]
+	}
+	for _, key := range keys {
+		result.Contents = append(result.Contents, struct {
+			Key  string
+			Size int
+		}{key, len(f.objects[key])})
+	}
+	result.KeyCount = len(result.Contents)
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
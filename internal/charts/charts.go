@@ -0,0 +1,120 @@
+// Package charts draws the simple SVG charts used on the admin pages so they
+// render without any JavaScript.
+package charts
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+const (
+	width       = 600
+	barHeight   = 24
+	barGap      = 8
+	labelWidth  = 170
+	valueWidth  = 50
+	lineHeight  = 240
+	linePadding = 40
+
+	barColour  = "#6d8b74"
+	lineColour = "#5f7161"
+	axisColour = "#999"
+	textStyle  = `font-family="sans-serif" font-size="13" fill="#333"`
+)
+
+// BarChart draws a horizontal bar for each count, scaled to the largest.
+func BarChart(counts []models.Count) template.HTML {
+	if len(counts) == 0 {
+		return empty()
+	}
+
+	largest := 0
+	for _, count := range counts {
+		largest = max(largest, count.Count)
+	}
+
+	height := len(counts)*(barHeight+barGap) + barGap
+	barArea := float64(width - labelWidth - valueWidth)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="100%%" role="img">`, width, height)
+	for idx, count := range counts {
+		y := barGap + idx*(barHeight+barGap)
+		barWidth := 0.0
+		if largest > 0 {
+			barWidth = barArea * float64(count.Count) / float64(largest)
+		}
+		textY := y + barHeight/2 + 5
+
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end" %s>%s</text>`, labelWidth-10, textY, textStyle, html.EscapeString(count.Label))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="%d" fill="%s"/>`, labelWidth, y, barWidth, barHeight, barColour)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" %s>%d</text>`, float64(labelWidth)+barWidth+6, textY, textStyle, count.Count)
+	}
+	b.WriteString(`</svg>`)
+
+	return template.HTML(b.String())
+}
+
+// LineChart draws the running total of responses as a percentage of total,
+// spacing the points by date so quiet weeks show as flat stretches.
+func LineChart(counts []models.DailyCount, total int) template.HTML {
+	if len(counts) == 0 || total == 0 {
+		return empty()
+	}
+
+	first, err := time.Parse(time.DateOnly, counts[0].Date)
+	if err != nil {
+		return empty()
+	}
+	last, err := time.Parse(time.DateOnly, counts[len(counts)-1].Date)
+	if err != nil {
+		return empty()
+	}
+	days := last.Sub(first).Hours() / 24
+
+	plotWidth := float64(width - 2*linePadding)
+	plotHeight := float64(lineHeight - 2*linePadding)
+	left, bottom := float64(linePadding), float64(lineHeight-linePadding)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="100%%" role="img">`, width, lineHeight)
+
+	// Axes with gridlines every 25%
+	for pct := 0; pct <= 100; pct += 25 {
+		y := bottom - plotHeight*float64(pct)/100
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="0.5"/>`, left, y, left+plotWidth, y, axisColour)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="end" %s>%d%%</text>`, left-6, y+4, textStyle, pct)
+	}
+	fmt.Fprintf(&b, `<text x="%.1f" y="%d" %s>%s</text>`, left, lineHeight-12, textStyle, counts[0].Date)
+	if len(counts) > 1 {
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="end" %s>%s</text>`, left+plotWidth, lineHeight-12, textStyle, counts[len(counts)-1].Date)
+	}
+
+	points := make([]string, 0, len(counts))
+	for _, count := range counts {
+		date, err := time.Parse(time.DateOnly, count.Date)
+		if err != nil {
+			continue
+		}
+		x := left
+		if days > 0 {
+			x += plotWidth * date.Sub(first).Hours() / 24 / days
+		}
+		y := bottom - plotHeight*float64(count.Count)/float64(total)
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+		fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"><title>%s: %d</title></circle>`, x, y, lineColour, count.Date, count.Count)
+	}
+	fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`, strings.Join(points, " "), lineColour)
+	b.WriteString(`</svg>`)
+
+	return template.HTML(b.String())
+}
+
+func empty() template.HTML {
+	return template.HTML(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d 40" width="100%%"><text x="0" y="25" %s>No data yet</text></svg>`, width, textStyle))
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Path is the config file to read, config.toml unless CONFIG_FILE names
+// another.
+func Path() string {
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		return path
+	}
+	return DefaultPath
+}
+
+// LoadDefault loads the config from Path and .env.
+func LoadDefault() (*Config, error) {
+	return Load(Path(), DefaultEnvPath)
+}
+
+// RunCommand runs `config check`, which loads and validates the config and
+// prints every problem with it, or the settings in use with secrets redacted.
+func RunCommand(args []string, out io.Writer) error {
+	if len(args) != 1 || args[0] != "check" {
+		return fmt.Errorf("usage: config check")
+	}
+
+	for _, path := range []string{Path(), DefaultEnvPath} {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(out, "%s doesn't exist, skipping it.\n", path)
+		}
+	}
+
+	cfg, err := LoadDefault()
+	var cfgErr *Error
+	if errors.As(err, &cfgErr) {
+		fmt.Fprintf(out, "Found %d problems:\n", len(cfgErr.Problems))
+		for _, problem := range cfgErr.Problems {
+			fmt.Fprintf(out, "  %s\n", problem)
+		}
+		return fmt.Errorf("config is invalid")
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "Config is valid. The settings in use are:")
+	fmt.Fprintln(out)
+	return toml.NewEncoder(out).Encode(cfg)
+}
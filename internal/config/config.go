@@ -0,0 +1,333 @@
+// Package config loads the server's settings from a TOML file, with
+// environment variables (including any set in a .env file) overriding it.
+package config
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/backup"
+	"github.com/nesquikmike/wedding-rsvps/internal/logging"
+	"github.com/nesquikmike/wedding-rsvps/internal/schedule"
+)
+
+const (
+	DefaultPath    = "config.toml"
+	DefaultEnvPath = ".env"
+
+	EnvironmentDevelopment = "development"
+	EnvironmentProduction  = "production"
+
+	PagesVisibleToGuests   = "guests"
+	PagesVisibleToEveryone = "everyone"
+
+	defaultSchedule            = "@daily"
+	requiredLenSecretCookieKey = 32
+)
+
+// Config is every setting the server reads. Each field can be set in the
+// config file under its toml name or by the environment variable in its env
+// tag, which wins.
+type Config struct {
+	Environment        string  `toml:"environment" env:"ENVIRONMENT"`
+	URL                string  `toml:"url" env:"URL"`
+	APIKey             Secret  `toml:"api_key" env:"API_KEY"`
+	SecretCookieKey    Secret  `toml:"secret_cookie_key" env:"SECRET_COOKIE_KEY"`
+	MetricsAddr        string  `toml:"metrics_addr" env:"METRICS_ADDR"`
+	S3BucketAssets     string  `toml:"s3_bucket_assets" env:"S3_BUCKET_ASSETS"`
+	SeatingVisibleFrom Date    `toml:"seating_visible_from" env:"SEATING_VISIBLE_FROM"`
+	PagesVisibleTo     string  `toml:"pages_visible_to" env:"PAGES_VISIBLE_TO"`
+	TrustedProxies     Proxies `toml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+
+	Wedding Wedding      `toml:"wedding"`
+	Bank    Bank         `toml:"bank"`
+	Logging Logging      `toml:"logging"`
+	Backup  BackupConfig `toml:"backup"`
+}
+
+type Wedding struct {
+	PartnerOne            string `toml:"partner_one" env:"PARTNER_ONE"`
+	PartnerTwo            string `toml:"partner_two" env:"PARTNER_TWO"`
+	Date                  string `toml:"date" env:"DATE"`
+	RSVPBy                Date   `toml:"rsvp_by" env:"RSVP_BY"`
+	VenueVague            string `toml:"venue_vague" env:"VENUE_VAGUE"`
+	VenueAddress          string `toml:"venue_address" env:"VENUE_ADDRESS"`
+	VenueTravelDetails    string `toml:"venue_travel_details" env:"VENUE_TRAVEL_DETAILS"`
+	TimeStart             string `toml:"time_start" env:"TIME_START"`
+	TimeArrival           string `toml:"time_arrival" env:"TIME_ARRIVAL"`
+	MainPhotoFileName     string `toml:"main_photo_file_name" env:"MAIN_PHOTO_FILE_NAME"`
+	PostCeremonyItinerary string `toml:"post_ceremony_itinerary" env:"POST_CEREMONY_ITINERARY"`
+	FooterMessage         string `toml:"footer_message" env:"FOOTER_MESSAGE"`
+}
+
+type Bank struct {
+	Name          string `toml:"name" env:"BANK_NAME"`
+	AccountName   string `toml:"account_name" env:"BANK_ACCOUNT_NAME"`
+	SortCode      string `toml:"sort_code" env:"BANK_SORT_CODE"`
+	AccountNumber string `toml:"account_number" env:"BANK_ACCOUNT_NUMBER"`
+}
+
+type Logging struct {
+	Level            string `toml:"level" env:"LOG_LEVEL"`
+	RotationSchedule string `toml:"rotation_schedule" env:"LOG_ROTATION_SCHEDULE"`
+}
+
+type BackupConfig struct {
+	S3Bucket            string `toml:"s3_bucket" env:"S3_BUCKET_BACKUPS"`
+	S3Region            string `toml:"s3_region" env:"BACKUP_S3_REGION"`
+	S3Endpoint          string `toml:"s3_endpoint" env:"BACKUP_S3_ENDPOINT"`
+	Dir                 string `toml:"dir" env:"BACKUP_DIR"`
+	EncryptionKey       Secret `toml:"encryption_key" env:"BACKUP_ENCRYPTION_KEY"`
+	Schedule            string `toml:"schedule" env:"BACKUP_SCHEDULE"`
+	IncrementalSchedule string `toml:"incremental_schedule" env:"INCREMENTAL_BACKUP_SCHEDULE"`
+	KeepDaily           int    `toml:"keep_daily" env:"BACKUP_KEEP_DAILY"`
+	KeepWeekly          int    `toml:"keep_weekly" env:"BACKUP_KEEP_WEEKLY"`
+	KeepMonthly         int    `toml:"keep_monthly" env:"BACKUP_KEEP_MONTHLY"`
+}
+
+// Secret is a setting which must never be logged. It prints as [redacted] and
+// its value is only available from Value.
+type Secret string
+
+func (s Secret) Value() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "[redacted]"
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Secret) UnmarshalText(text []byte) error {
+	*s = Secret(text)
+	return nil
+}
+
+// Date is a day written as YYYY-MM-DD, or unset.
+type Date struct {
+	time.Time
+}
+
+func (d Date) MarshalText() ([]byte, error) {
+	if d.IsZero() {
+		return []byte{}, nil
+	}
+	return []byte(d.Format(time.DateOnly)), nil
+}
+
+func (d *Date) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*d = Date{}
+		return nil
+	}
+	t, err := time.Parse(time.DateOnly, string(text))
+	if err != nil {
+		return fmt.Errorf("expected a date as YYYY-MM-DD, got %q", text)
+	}
+	*d = Date{t}
+	return nil
+}
+
+// Proxies are the addresses requests can be forwarded from, written as IP
+// addresses or CIDR ranges separated by commas, e.g. 10.0.0.0/8, ::1.
+type Proxies []netip.Prefix
+
+func (p Proxies) MarshalText() ([]byte, error) {
+	written := make([]string, len(p))
+	for i, prefix := range p {
+		written[i] = prefix.String()
+	}
+	return []byte(strings.Join(written, ", ")), nil
+}
+
+func (p *Proxies) UnmarshalText(text []byte) error {
+	var proxies Proxies
+	for _, part := range strings.Split(string(text), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(part); err == nil {
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(part)
+		if err != nil {
+			return fmt.Errorf("expected an IP address or CIDR range, got %q", part)
+		}
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	*p = proxies
+	return nil
+}
+
+// Error lists every problem found with the config, so they can all be fixed
+// at once.
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid config:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+func defaults() Config {
+	return Config{
+		Environment:    EnvironmentDevelopment,
+		PagesVisibleTo: PagesVisibleToGuests,
+		Logging: Logging{
+			Level:            "info",
+			RotationSchedule: defaultSchedule,
+		},
+		Backup: BackupConfig{
+			Schedule: defaultSchedule,
+		},
+	}
+}
+
+// Load reads the config file at path, which is optional, then applies the
+// variables in the .env file at envPath, also optional, and finally the
+// environment. The result is validated and every problem returned together
+// as an *Error.
+func Load(path, envPath string) (*Config, error) {
+	cfg := defaults()
+	var problems []string
+
+	meta, err := toml.DecodeFile(path, &cfg)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	for _, key := range meta.Undecoded() {
+		problems = append(problems, fmt.Sprintf("%s: unknown setting %q", path, key.String()))
+	}
+
+	envVars, err := ReadEnvFile(envPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	problems = append(problems, applyEnv(&cfg, envVars, os.LookupEnv)...)
+	problems = append(problems, cfg.validate()...)
+
+	if len(problems) > 0 {
+		return &cfg, &Error{Problems: problems}
+	}
+	return &cfg, nil
+}
+
+func (cfg *Config) validate() []string {
+	var problems []string
+
+	if cfg.Environment != EnvironmentDevelopment && cfg.Environment != EnvironmentProduction {
+		problems = append(problems, fmt.Sprintf("ENVIRONMENT must be %s or %s, got %q", EnvironmentDevelopment, EnvironmentProduction, cfg.Environment))
+	}
+	if cfg.URL == "" {
+		problems = append(problems, "URL must be set")
+	}
+	if cfg.PagesVisibleTo != PagesVisibleToGuests && cfg.PagesVisibleTo != PagesVisibleToEveryone {
+		problems = append(problems, fmt.Sprintf("PAGES_VISIBLE_TO must be %s or %s, got %q", PagesVisibleToGuests, PagesVisibleToEveryone, cfg.PagesVisibleTo))
+	}
+	if cfg.APIKey == "" {
+		problems = append(problems, "API_KEY must be set")
+	}
+	if _, err := cfg.CookieKey(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if _, err := logging.ParseLevel(cfg.Logging.Level); err != nil {
+		problems = append(problems, fmt.Sprintf("LOG_LEVEL: %v", err))
+	}
+	if _, err := schedule.Parse(cfg.Logging.RotationSchedule); err != nil {
+		problems = append(problems, fmt.Sprintf("LOG_ROTATION_SCHEDULE: %v", err))
+	}
+
+	b := cfg.Backup
+	if _, err := schedule.Parse(b.Schedule); err != nil {
+		problems = append(problems, fmt.Sprintf("BACKUP_SCHEDULE: %v", err))
+	}
+	if b.IncrementalSchedule != "" {
+		if _, err := schedule.Parse(b.IncrementalSchedule); err != nil {
+			problems = append(problems, fmt.Sprintf("INCREMENTAL_BACKUP_SCHEDULE: %v", err))
+		}
+	}
+	for _, keep := range []struct {
+		name string
+		n    int
+	}{
+		{"BACKUP_KEEP_DAILY", b.KeepDaily},
+		{"BACKUP_KEEP_WEEKLY", b.KeepWeekly},
+		{"BACKUP_KEEP_MONTHLY", b.KeepMonthly},
+	} {
+		if keep.n < 0 {
+			problems = append(problems, fmt.Sprintf("%s can't be negative, got %d", keep.name, keep.n))
+		}
+	}
+	if b.HasTargets() {
+		if _, err := cfg.BackupKey(); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	return problems
+}
+
+func (cfg *Config) IsProd() bool {
+	return cfg.Environment == EnvironmentProduction
+}
+
+// CookieKey decodes SECRET_COOKIE_KEY, generated with `openssl rand -hex 32`.
+func (cfg *Config) CookieKey() ([]byte, error) {
+	key, err := hex.DecodeString(cfg.SecretCookieKey.Value())
+	if err != nil {
+		return nil, fmt.Errorf("SECRET_COOKIE_KEY must be hex encoded")
+	}
+	if len(key) != requiredLenSecretCookieKey {
+		return nil, fmt.Errorf("SECRET_COOKIE_KEY is %v bytes long when it should be %v", len(key), requiredLenSecretCookieKey)
+	}
+	return key, nil
+}
+
+// BackupKey reads the key backups are encrypted with. It has to be separate
+// from the cookie key so that leaking one doesn't expose the other.
+func (cfg *Config) BackupKey() ([]byte, error) {
+	hexKey := cfg.Backup.EncryptionKey.Value()
+	if hexKey == "" {
+		return nil, fmt.Errorf("BACKUP_ENCRYPTION_KEY must be set to make backups")
+	}
+	if strings.EqualFold(hexKey, cfg.SecretCookieKey.Value()) {
+		return nil, fmt.Errorf("BACKUP_ENCRYPTION_KEY must be different to SECRET_COOKIE_KEY")
+	}
+
+	key, err := backup.ParseKey(hexKey)
+	if err != nil {
+		return nil, fmt.Errorf("BACKUP_ENCRYPTION_KEY: %v", err)
+	}
+	return key, nil
+}
+
+// HasTargets reports whether anywhere to send backups is set.
+func (b BackupConfig) HasTargets() bool {
+	return b.S3Bucket != "" || b.Dir != ""
+}
+
+// Retention is how many backups of each kind to keep. With none set nothing
+// is pruned.
+func (b BackupConfig) Retention() backup.Retention {
+	return backup.Retention{
+		Daily:   b.KeepDaily,
+		Weekly:  b.KeepWeekly,
+		Monthly: b.KeepMonthly,
+	}
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"bufio"
+	"encoding"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ReadEnvFile reads KEY=value lines from a .env file. Blank lines and lines
+// starting with # are skipped, and a leading "export " is allowed. Values can
+// be wrapped in double quotes, inside which \", \\ and \n are unescaped, or in
+// single quotes, which are taken literally. Unquoted values end at " #".
+func ReadEnvFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	envVars := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	// HTML settings can make for long lines
+	scanner.Buffer(nil, 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		k, v, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			return nil, fmt.Errorf("%s line %d: expected KEY=value", path, lineNumber)
+		}
+		k = strings.TrimSpace(k)
+		v, err := parseEnvValue(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", path, lineNumber, err)
+		}
+
+		envVars[k] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	return envVars, nil
+}
+
+func parseEnvValue(v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, `"`):
+		var b strings.Builder
+		for i := 1; i < len(v); i++ {
+			switch c := v[i]; c {
+			case '"':
+				if rest := strings.TrimSpace(v[i+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+					return "", fmt.Errorf("unexpected %q after closing quote", rest)
+				}
+				return b.String(), nil
+			case '\\':
+				if i+1 == len(v) {
+					return "", fmt.Errorf("unterminated quoted value")
+				}
+				i++
+				switch v[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case '"', '\\':
+					b.WriteByte(v[i])
+				default:
+					// anything else is kept as it was written
+					b.WriteByte('\\')
+					b.WriteByte(v[i])
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return "", fmt.Errorf("unterminated quoted value")
+	case strings.HasPrefix(v, "'"):
+		end := strings.IndexByte(v[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted value")
+		}
+		return v[1 : end+1], nil
+	default:
+		if i := strings.Index(v, " #"); i >= 0 {
+			v = v[:i]
+		}
+		return strings.TrimSpace(v), nil
+	}
+}
+
+// applyEnv sets every field of cfg with an env tag from the environment, or
+// failing that from fileVars, leaving fields whose variable is empty alone.
+// It returns any values which couldn't be parsed along with any keys in
+// fileVars which aren't a setting.
+func applyEnv(cfg *Config, fileVars map[string]string, lookupEnv func(string) (string, bool)) []string {
+	var problems []string
+	known := make(map[string]bool)
+
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		for i := 0; i < v.NumField(); i++ {
+			field, fieldType := v.Field(i), v.Type().Field(i)
+			name := fieldType.Tag.Get("env")
+			if name == "" {
+				if field.Kind() == reflect.Struct {
+					walk(field)
+				}
+				continue
+			}
+			known[name] = true
+
+			value, ok := lookupEnv(name)
+			if !ok {
+				value, ok = fileVars[name]
+			}
+			// the deploy writes every variable, leaving unused ones empty
+			if !ok || value == "" {
+				continue
+			}
+			if err := setField(field, value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+			}
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem())
+
+	var unknown []string
+	for name := range fileVars {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		problems = append(problems, fmt.Sprintf("%s is not a setting", name))
+	}
+
+	return problems
+}
+
+func setField(field reflect.Value, value string) error {
+	if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("must be a whole number, got %q", value)
+		}
+		field.SetInt(int64(n))
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+// Package content renders the wedding content guests see and reads and writes
+// it in the TOML format it is edited in.
+package content
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/renderer/html"
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+var (
+	// raw HTML is let through the Markdown renderer so content written as
+	// HTML before keeps working, and is then cleaned by the policy
+	markdown = goldmark.New(goldmark.WithRendererOptions(html.WithUnsafe(), html.WithHardWraps()))
+	policy   = newPolicy()
+)
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.RequireNoFollowOnLinks(false)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	// lets content use the site's own styles, e.g. light-bold
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[\w\- ]+$`)).OnElements("span", "p", "div")
+	return p
+}
+
+// Markdown renders s as HTML with anything which could run script or change
+// the page around it removed, so it is safe to put straight into a template.
+func Markdown(s string) template.HTML {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(s), &buf); err != nil {
+		return template.HTML(template.HTMLEscapeString(s))
+	}
+	balanced, err := balance(policy.SanitizeBytes(buf.Bytes()))
+	if err != nil {
+		return template.HTML(template.HTMLEscapeString(s))
+	}
+	return template.HTML(balanced)
+}
+
+// balance closes any tags left open and drops closing tags with nothing to
+// close, which the sanitiser lets through, so raw HTML in the content can't
+// end an element of the page it is shown in.
+func balance(b []byte) (string, error) {
+	container := &xhtml.Node{Type: xhtml.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := xhtml.ParseFragment(bytes.NewReader(b), container)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	for _, node := range nodes {
+		if err := xhtml.Render(&buf, node); err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), nil
+}
+
+// InlineMarkdown renders s like Markdown but without the paragraph around a
+// single line, for content which sits inside a sentence or list.
+func InlineMarkdown(s string) template.HTML {
+	rendered := strings.TrimSpace(string(Markdown(s)))
+	inner, ok := strings.CutPrefix(rendered, "<p>")
+	if !ok || !strings.HasSuffix(inner, "</p>") || strings.Contains(inner, "<p>") {
+		return template.HTML(rendered)
+	}
+	return template.HTML(strings.TrimSuffix(inner, "</p>"))
+}
+
+// FuncMap is added to the templates so they can render Markdown fields with
+// {{ markdown .Content.Venue.Directions }} or {{ inlineMarkdown .Content.Footer }}.
+var FuncMap = template.FuncMap{
+	"markdown":       Markdown,
+	"inlineMarkdown": InlineMarkdown,
+}
+
+// Parse reads content written as TOML, refusing any settings it doesn't know
+// so typos aren't silently dropped, and validates it.
+func Parse(s string) (models.Content, error) {
+	var c models.Content
+	meta, err := toml.Decode(s, &c)
+	if err != nil {
+		return c, err
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		var keys []string
+		for _, key := range undecoded {
+			keys = append(keys, key.String())
+		}
+		return c, fmt.Errorf("unknown settings: %s", strings.Join(keys, ", "))
+	}
+
+	return c, Validate(c)
+}
+
+// Format writes c as TOML for editing.
+func Format(c models.Content) (string, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(c); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Validate checks everything guests would otherwise see broken.
+func Validate(c models.Content) error {
+	var problems []string
+
+	if c.Venue.MapURL != "" && !isWebURL(c.Venue.MapURL) {
+		problems = append(problems, "venue map_url must be an http or https link")
+	}
+	for i, item := range c.Itinerary {
+		if item.Title == "" && item.Description == "" {
+			problems = append(problems, fmt.Sprintf("itinerary item %d needs a title or a description", i+1))
+		}
+	}
+	for i, section := range c.FAQ {
+		if section.Title == "" {
+			problems = append(problems, fmt.Sprintf("faq section %d has no title", i+1))
+		}
+		for j, entry := range section.Questions {
+			if entry.Question == "" || entry.Answer == "" {
+				problems = append(problems, fmt.Sprintf("faq section %d question %d needs a question and an answer", i+1, j+1))
+			}
+		}
+	}
+	for i, section := range c.Travel {
+		if section.Title == "" {
+			problems = append(problems, fmt.Sprintf("travel section %d has no title", i+1))
+		}
+	}
+	for i, place := range c.Accommodation {
+		if place.Name == "" {
+			problems = append(problems, fmt.Sprintf("accommodation %d has no name", i+1))
+		}
+		if place.BookingURL != "" && !isWebURL(place.BookingURL) {
+			problems = append(problems, fmt.Sprintf("accommodation %d booking_url must be an http or https link", i+1))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func isWebURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+var lineBreak = regexp.MustCompile(`(?i)\s*<br\s*/?>\s*|\n`)
+
+// FromSettings builds the content from the HTML settings it used to be
+// configured with, so a site upgrading keeps showing the same details until
+// they are edited.
+func FromSettings(venueAddress, travelDetails, itinerary, footer string) models.Content {
+	var c models.Content
+	for _, line := range lineBreak.Split(venueAddress, -1) {
+		if line = strings.TrimSpace(line); line != "" {
+			c.Venue.AddressLines = append(c.Venue.AddressLines, line)
+		}
+	}
+	c.Venue.Directions = travelDetails
+	if itinerary != "" {
+		c.Itinerary = []models.ItineraryItem{{Description: itinerary}}
+	}
+	c.Footer = footer
+	return c
+}
@@ -0,0 +1,153 @@
+package content
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMarkdownRemovesScript(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		markdown string
+		banned   []string
+	}{
+		{"script element", `<script>alert(1)</script>`, []string{"<script", "alert(1)"}},
+		{"script in a paragraph", "hello <script src=\"https://evil.example.com/x.js\"></script>", []string{"<script", "evil.example.com"}},
+		{"onerror", `<img src="x.png" onerror="alert(1)">`, []string{"onerror", "alert(1)"}},
+		{"onclick", `<span onclick="alert(1)">hi</span>`, []string{"onclick", "alert(1)"}},
+		{"markdown javascript link", `[click](javascript:alert(1))`, []string{"javascript:", "href"}},
+		{"html javascript link", `<a href="javascript:alert(1)">click</a>`, []string{"javascript:", "href"}},
+		{"encoded javascript link", `<a href="&#106;avascript:alert(1)">click</a>`, []string{"avascript:", "href"}},
+		{"iframe", `<iframe src="https://evil.example.com"></iframe>`, []string{"<iframe", "evil.example.com"}},
+		{"style attribute", `<p style="position:fixed;top:0">covering the page</p>`, []string{"style", "position"}},
+		{"style element", `<style>body { display: none }</style>`, []string{"<style", "display"}},
+		{"class with other characters", `<span class="x&quot; onmouseover=&quot;alert(1)">hi</span>`, []string{"class", "onmouseover"}},
+		{"class on a link", `<a class="button" href="https://example.com">book</a>`, []string{"class"}},
+		{"form", `<form action="https://evil.example.com"><input name="code"></form>`, []string{"<form", "<input"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(Markdown(tt.markdown))
+			for _, banned := range tt.banned {
+				if strings.Contains(got, banned) {
+					t.Errorf("Markdown(%q) = %q, which contains %q", tt.markdown, got, banned)
+				}
+			}
+		})
+	}
+}
+
+func TestMarkdownKeeps(t *testing.T) {
+	for markdown, want := range map[string]string{
+		"**bold** and _italic_":                         "<p><strong>bold</strong> and <em>italic</em></p>",
+		"[the venue](https://example.com/venue)":        `<a href="https://example.com/venue" target="_blank" rel="noopener">the venue</a>`,
+		`<span class="light-bold">Oak</span>`:           `<span class="light-bold">Oak</span>`,
+		"line one\nline two":                            "line one<br/>\nline two",
+		"- parking\n- taxis":                            "<li>parking</li>",
+		`<p class="light-bold notice">Arrive early</p>`: `<p class="light-bold notice">Arrive early</p>`,
+	} {
+		if got := string(Markdown(markdown)); !strings.Contains(got, want) {
+			t.Errorf("Markdown(%q) = %q, want it to contain %q", markdown, got, want)
+		}
+	}
+}
+
+var (
+	reTag       = regexp.MustCompile(`<(/?)([a-zA-Z][a-zA-Z0-9]*)[^>]*?(/?)>`)
+	voidElement = map[string]bool{"br": true, "hr": true, "img": true}
+)
+
+// balanced reports whether every element opened in s is closed in order.
+func balanced(s string) bool {
+	var open []string
+	for _, tag := range reTag.FindAllStringSubmatch(s, -1) {
+		closing, name, selfClosing := tag[1] == "/", strings.ToLower(tag[2]), tag[3] == "/"
+		switch {
+		case voidElement[name] || selfClosing:
+		case !closing:
+			open = append(open, name)
+		case len(open) == 0 || open[len(open)-1] != name:
+			return false
+		default:
+			open = open[:len(open)-1]
+		}
+	}
+	return len(open) == 0
+}
+
+func TestMarkdownBalanced(t *testing.T) {
+	for _, markdown := range []string{
+		"plain",
+		"</div>closing the page's div",
+		"x</span>",
+		"<em>never closed",
+		"<div><p>nested and open",
+		"**bold",
+		"a</p>b",
+		"one\n\ntwo",
+	} {
+		if got := string(Markdown(markdown)); !balanced(got) {
+			t.Errorf("Markdown(%q) = %q, which isn't balanced", markdown, got)
+		}
+	}
+}
+
+func TestInlineMarkdown(t *testing.T) {
+	for markdown, want := range map[string]string{
+		"Love from **Anna & Ben**": "Love from <strong>Anna &amp; Ben</strong>",
+		"one\n\ntwo":               "<p>one</p>\n<p>two</p>",
+		"":                         "",
+	} {
+		if got := strings.TrimSpace(string(InlineMarkdown(markdown))); got != want {
+			t.Errorf("InlineMarkdown(%q) = %q, want %q", markdown, got, want)
+		}
+	}
+
+	for _, markdown := range []string{
+		"a</p>b",
+		"a</p><p>b",
+		"<p>a</p>\n\n<p>b</p>",
+		"x</span>",
+		"<em>a",
+		"</div>",
+		"a <p>b</p> c",
+		"<p class=\"light-bold\">a</p>",
+	} {
+		if got := string(InlineMarkdown(markdown)); !balanced(got) {
+			t.Errorf("InlineMarkdown(%q) = %q, which isn't balanced", markdown, got)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	valid := `
+[venue]
+name = "The Barn"
+map_url = "https://maps.example.com/barn"
+
+[[accommodation]]
+name = "The Inn"
+booking_url = "http://inn.example.com/book"
+`
+	c, err := Parse(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Venue.Name != "The Barn" || len(c.Accommodation) != 1 {
+		t.Errorf("parsed %+v", c)
+	}
+
+	for name, source := range map[string]string{
+		"unknown key":              "[venue]\nname = \"The Barn\"\nmap = \"https://maps.example.com\"\n",
+		"unknown section":          "[wedding]\ndate = \"soon\"\n",
+		"javascript map url":       "[venue]\nmap_url = \"javascript:alert(1)\"\n",
+		"relative map url":         "[venue]\nmap_url = \"/maps/barn\"\n",
+		"data booking url":         "[[accommodation]]\nname = \"The Inn\"\nbooking_url = \"data:text/html,<script>alert(1)</script>\"\n",
+		"booking url with no host": "[[accommodation]]\nname = \"The Inn\"\nbooking_url = \"https://\"\n",
+		"invalid toml":             "[venue\n",
+	} {
+		if _, err := Parse(source); err == nil {
+			t.Errorf("parsed content with %s", name)
+		}
+	}
+}
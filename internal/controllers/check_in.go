@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/cookies"
+	"github.com/nesquikmike/wedding-rsvps/internal/database"
+	"github.com/nesquikmike/wedding-rsvps/internal/logging"
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+// The admin cookie only needs to last the wedding weekend
+const adminSessionSeconds = 3 * 24 * 60 * 60
+
+// adminRedirect only follows links back to pages on this site after login.
+func adminRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/check-in"
+	}
+	return next
+}
+
+func (c Controller) AdminLogin(w http.ResponseWriter, req *http.Request) {
+	next := adminRedirect(req.FormValue("next"))
+	data := map[string]any{
+		"PartnerOne": c.viewData.PartnerOne,
+		"PartnerTwo": c.viewData.PartnerTwo,
+		"Next":       next,
+		"Invalid":    false,
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		c.tpl.ExecuteTemplate(w, "admin_login.gohtml", data)
+	case http.MethodPost:
+		apiKey := req.FormValue("api_key")
+		if subtle.ConstantTimeCompare([]byte(apiKey), []byte(c.apiKey)) != 1 {
+			c.logger.WarnContext(req.Context(), "admin login failed")
+			w.WriteHeader(http.StatusForbidden)
+			data["Invalid"] = true
+			c.tpl.ExecuteTemplate(w, "admin_login.gohtml", data)
+			return
+		}
+
+		adminCookie := cookies.GenerateCookie(cookies.AdminTokenName, apiKey, c.isProd)
+		adminCookie.MaxAge = adminSessionSeconds
+		if err := cookies.WriteEncrypted(w, adminCookie, c.secretCookieKey); err != nil {
+			c.logger.ErrorContext(req.Context(), "could not write admin cookie", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		c.logger.InfoContext(req.Context(), "admin login succeeded")
+		http.Redirect(w, req, next, http.StatusFound)
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+// codeFromScan accepts either a bare guest code or the login url printed in
+// the invitation QR codes.
+func codeFromScan(scanned string) string {
+	scanned = strings.TrimSpace(scanned)
+	if u, err := url.Parse(scanned); err == nil {
+		if code := u.Query().Get("code"); code != "" {
+			return code
+		}
+	}
+	return scanned
+}
+
+// arrivalTime turns the stored UTC timestamp into the local time of day.
+func arrivalTime(arrivedAt string) string {
+	t, err := time.ParseInLocation(time.DateTime, arrivedAt, time.UTC)
+	if err != nil {
+		return arrivedAt
+	}
+	return t.Local().Format("15:04")
+}
+
+func (c Controller) CheckIn(w http.ResponseWriter, req *http.Request) {
+	data := models.CheckInData{
+		PartnerOne: c.viewData.PartnerOne,
+		PartnerTwo: c.viewData.PartnerTwo,
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		data.Code = codeFromScan(req.FormValue("code"))
+		c.logger.InfoContext(req.Context(), "/check-in request")
+
+		guest, err := c.guestStore.GetGuest(data.Code)
+		if err != nil {
+			c.logger.ErrorContext(req.Context(), "error getting guest", "error", err)
+			http.Error(w, "Error getting guest", http.StatusInternalServerError)
+			return
+		}
+		if guest == nil {
+			data.NotFound = true
+			break
+		}
+		logging.SetGuestID(req.Context(), guest.ID)
+
+		data.AlreadyArrived = guest.ArrivedAt != ""
+		if !data.AlreadyArrived {
+			err := c.guestStore.CheckInGuest(guest.Code, models.ActorAdmin)
+			if errors.Is(err, database.ErrGuestNotFound) {
+				data.NotFound = true
+				break
+			} else if err != nil {
+				c.logger.ErrorContext(req.Context(), "error checking in guest", "error", err)
+				http.Error(w, "Error checking in guest", http.StatusInternalServerError)
+				return
+			}
+
+			guest, err = c.guestStore.GetGuest(guest.Code)
+			if err != nil || guest == nil {
+				c.logger.ErrorContext(req.Context(), "error getting guest after check in", "error", err)
+				http.Error(w, "Error getting guest", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		data.Guest = guest
+		data.ArrivedAt = arrivalTime(guest.ArrivedAt)
+
+		data.SeatAssignment, err = c.guestStore.GetSeatAssignment(guest.ID)
+		if err != nil {
+			c.logger.ErrorContext(req.Context(), "could not get seat assignment", "error", err)
+		}
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	counts, err := c.guestStore.GetCheckInCounts()
+	if err != nil {
+		c.logger.ErrorContext(req.Context(), "error getting check in counts", "error", err)
+	}
+	data.Counts = counts
+
+	c.tpl.ExecuteTemplate(w, "check_in.gohtml", data)
+}
+
+func (c Controller) GetCheckInCounts(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	counts, err := c.guestStore.GetCheckInCounts()
+	if err != nil {
+		c.logger.ErrorContext(req.Context(), "error getting check in counts", "error", err)
+		http.Error(w, "Error getting check in counts", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(counts); err != nil {
+		c.logger.ErrorContext(req.Context(), "error writing check in counts", "error", err)
+	}
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/content"
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+// EditContent lets the wedding content be edited as TOML from the admin area,
+// previewing it before it is saved.
+func (c Controller) EditContent(w http.ResponseWriter, req *http.Request) {
+	data := models.ContentData{
+		PartnerOne: c.viewData.PartnerOne,
+		PartnerTwo: c.viewData.PartnerTwo,
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		source, err := content.Format(*c.content.Load())
+		if err != nil {
+			c.logger.ErrorContext(req.Context(), "error formatting content", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		data.Source = source
+	case http.MethodPost:
+		data.Source = req.FormValue("content")
+		parsed, err := content.Parse(data.Source)
+		if err != nil {
+			data.Error = err.Error()
+			w.WriteHeader(http.StatusBadRequest)
+			break
+		}
+		data.Preview = &parsed
+		if req.FormValue("action") == "preview" {
+			break
+		}
+
+		c.logger.InfoContext(req.Context(), "/admin/content request")
+		if err := c.guestStore.SaveContent(parsed); err != nil {
+			c.logger.ErrorContext(req.Context(), "error saving content", "error", err)
+			http.Error(w, "Error saving content", http.StatusInternalServerError)
+			return
+		}
+		c.content.Store(&parsed)
+		data.Saved = true
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	c.tpl.ExecuteTemplate(w, "content.gohtml", data)
+}
+
+func (c Controller) GetContent(w http.ResponseWriter, req *http.Request) {
+	c.logger.InfoContext(req.Context(), "/get-content request")
+
+	if req.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(c.content.Load()); err != nil {
+		c.logger.ErrorContext(req.Context(), "error writing content", "error", err)
+	}
+}
+
+type UpdateContentRequest struct {
+	Content models.Content `json:"content"`
+}
+
+func (c Controller) UpdateContent(w http.ResponseWriter, req *http.Request) {
+	c.logger.InfoContext(req.Context(), "/update-content request")
+
+	if req.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var updateReq UpdateContentRequest
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	if err := json.Unmarshal(body, &updateReq); err != nil {
+		http.Error(w, "Bad Request: Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := content.Validate(updateReq.Content); err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := c.guestStore.SaveContent(updateReq.Content); err != nil {
+		c.logger.ErrorContext(req.Context(), "error saving content", "error", err)
+		http.Error(w, "Error saving content", http.StatusInternalServerError)
+		return
+	}
+	c.content.Store(&updateReq.Content)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(updateReq.Content); err != nil {
+		c.logger.ErrorContext(req.Context(), "error writing content", "error", err)
+	}
+}
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/funnel"
+)
+
+type FunnelRequest struct {
+	Format string `json:"format"`
+}
+
+func (c Controller) GetFunnel(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var funnelReq FunnelRequest
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	if err := json.Unmarshal(body, &funnelReq); err != nil {
+		http.Error(w, "Bad Request: Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	c.logger.InfoContext(req.Context(), "/get-funnel request", "format", funnelReq.Format)
+
+	if funnelReq.Format != "" && funnelReq.Format != "json" && funnelReq.Format != "csv" {
+		http.Error(w, "Bad Request: format must be json or csv", http.StatusBadRequest)
+		return
+	}
+
+	guests, err := c.guestStore.GetAllGuests(false)
+	if err != nil {
+		c.logger.ErrorContext(req.Context(), "error getting guests", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	visits, err := c.guestStore.GetVisitEvents()
+	if err != nil {
+		c.logger.ErrorContext(req.Context(), "error getting visit events", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	attendance, err := c.guestStore.GetFieldEvents("attendance")
+	if err != nil {
+		c.logger.ErrorContext(req.Context(), "error getting attendance events", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	report := funnel.Build(guests, visits, attendance)
+
+	if funnelReq.Format == "csv" {
+		c.writeFunnelCSV(w, req, report)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		c.logger.ErrorContext(req.Context(), "error writing funnel", "error", err)
+	}
+}
+
+func (c Controller) writeFunnelCSV(w http.ResponseWriter, req *http.Request, report funnel.Report) {
+	w.Header().Set("Content-Disposition", "attachment;filename=funnel.csv")
+	w.Header().Set("Content-Type", "text/csv")
+	csvWriter := csv.NewWriter(w)
+
+	headers := []string{
+		"Step",
+		"Reached",
+		"Median Time From Previous Step",
+		"Stuck",
+		"Stuck Guests",
+	}
+	if err := csvWriter.Write(headers); err != nil {
+		c.logger.ErrorContext(req.Context(), "CSV header error", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	for _, step := range report.Steps {
+		var median string
+		if step.MedianSeconds != nil {
+			median = (time.Duration(*step.MedianSeconds) * time.Second).String()
+		}
+
+		stuck := make([]string, 0, len(step.Stuck))
+		for _, guest := range step.Stuck {
+			stuck = append(stuck, guest.Name+" ("+guest.Code+")")
+		}
+
+		record := []string{
+			step.Name,
+			strconv.Itoa(step.Reached),
+			median,
+			strconv.Itoa(len(step.Stuck)),
+			strings.Join(stuck, "; "),
+		}
+		if err := csvWriter.Write(record); err != nil {
+			c.logger.ErrorContext(req.Context(), "CSV write error", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		c.logger.ErrorContext(req.Context(), "CSV flush error", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
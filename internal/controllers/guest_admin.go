@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/database"
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+type GuestAdminRequest struct {
+	Code          string `json:"code"`
+	Name          string `json:"name"`
+	DuplicateCode string `json:"duplicate_code"`
+}
+
+// readGuestAdminRequest checks the method and decodes the body shared by the
+// guest admin endpoints, writing an error response if either is wrong.
+func readGuestAdminRequest(w http.ResponseWriter, req *http.Request, method string) (GuestAdminRequest, bool) {
+	var adminReq GuestAdminRequest
+
+	if req.Method != method {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return adminReq, false
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return adminReq, false
+	}
+
+	if err := json.Unmarshal(body, &adminReq); err != nil {
+		http.Error(w, "Bad Request: Invalid JSON", http.StatusBadRequest)
+		return adminReq, false
+	}
+
+	if adminReq.Code == "" {
+		http.Error(w, "Bad Request: code is required", http.StatusBadRequest)
+		return adminReq, false
+	}
+
+	return adminReq, true
+}
+
+func (c Controller) writeGuestAdminError(w http.ResponseWriter, req *http.Request, action string, err error) {
+	switch {
+	case errors.Is(err, database.ErrGuestNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, database.ErrGuestMerged), errors.Is(err, database.ErrGuestNotDeleted):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		c.logger.ErrorContext(req.Context(), "admin action failed", "action", action, "error", err)
+		http.Error(w, fmt.Sprintf("Error trying to %s", action), http.StatusInternalServerError)
+	}
+}
+
+func (c Controller) RenameGuest(w http.ResponseWriter, req *http.Request) {
+	adminReq, ok := readGuestAdminRequest(w, req, http.MethodPost)
+	if !ok {
+		return
+	}
+	if adminReq.Name == "" {
+		http.Error(w, "Bad Request: name is required", http.StatusBadRequest)
+		return
+	}
+
+	c.logger.InfoContext(req.Context(), "/rename-guest request")
+
+	if err := c.guestStore.RenameGuest(adminReq.Code, adminReq.Name, models.ActorAPI); err != nil {
+		c.writeGuestAdminError(w, req, "rename guest", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf("guest %v renamed to %v", adminReq.Code, adminReq.Name)))
+}
+
+func (c Controller) DeleteGuest(w http.ResponseWriter, req *http.Request) {
+	adminReq, ok := readGuestAdminRequest(w, req, http.MethodPost)
+	if !ok {
+		return
+	}
+
+	c.logger.InfoContext(req.Context(), "/delete-guest request")
+
+	if err := c.guestStore.DeleteGuest(adminReq.Code, models.ActorAPI); err != nil {
+		c.writeGuestAdminError(w, req, "delete guest", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf("guest %v deleted", adminReq.Code)))
+}
+
+func (c Controller) RestoreGuest(w http.ResponseWriter, req *http.Request) {
+	adminReq, ok := readGuestAdminRequest(w, req, http.MethodPost)
+	if !ok {
+		return
+	}
+
+	c.logger.InfoContext(req.Context(), "/restore-guest request")
+
+	if err := c.guestStore.RestoreGuest(adminReq.Code, models.ActorAPI); err != nil {
+		c.writeGuestAdminError(w, req, "restore guest", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf("guest %v restored", adminReq.Code)))
+}
+
+func (c Controller) MergeGuests(w http.ResponseWriter, req *http.Request) {
+	adminReq, ok := readGuestAdminRequest(w, req, http.MethodPost)
+	if !ok {
+		return
+	}
+	if adminReq.DuplicateCode == "" {
+		http.Error(w, "Bad Request: duplicate_code is required", http.StatusBadRequest)
+		return
+	}
+
+	c.logger.InfoContext(req.Context(), "/merge-guests request")
+
+	if err := c.guestStore.MergeGuests(adminReq.Code, adminReq.DuplicateCode, models.ActorAPI); err != nil {
+		c.writeGuestAdminError(w, req, "merge guests", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf("guest %v merged into %v", adminReq.DuplicateCode, adminReq.Code)))
+}
+
+func (c Controller) GetGuestTimeline(w http.ResponseWriter, req *http.Request) {
+	adminReq, ok := readGuestAdminRequest(w, req, http.MethodGet)
+	if !ok {
+		return
+	}
+
+	c.logger.InfoContext(req.Context(), "/get-guest-timeline request")
+
+	events, err := c.guestStore.GetGuestTimeline(adminReq.Code)
+	if err != nil {
+		c.writeGuestAdminError(w, req, "get guest timeline", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(events); err != nil {
+		c.logger.ErrorContext(req.Context(), "error writing guest timeline", "error", err)
+	}
+}
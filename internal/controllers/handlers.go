@@ -4,41 +4,68 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/netip"
 	"regexp"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/nesquikmike/wedding-rsvps/internal/cookies"
 	"github.com/nesquikmike/wedding-rsvps/internal/database"
+	"github.com/nesquikmike/wedding-rsvps/internal/logging"
+	"github.com/nesquikmike/wedding-rsvps/internal/metrics"
 	"github.com/nesquikmike/wedding-rsvps/internal/models"
+	"github.com/nesquikmike/wedding-rsvps/internal/schedule"
+	"github.com/nesquikmike/wedding-rsvps/internal/validate"
 )
 
 type Controller struct {
-	isProd          bool
-	tpl             *template.Template
-	guestStore      database.GuestStore
-	logger          *log.Logger
-	viewData        *models.ViewData
-	secretCookieKey []byte
-	apiKey          string
-	s3AssetsBucket  string
+	isProd             bool
+	tpl                *template.Template
+	guestStore         database.GuestStore
+	logger             *slog.Logger
+	viewData           *models.ViewData
+	content            *atomic.Pointer[models.Content]
+	secretCookieKey    []byte
+	apiKey             string
+	s3AssetsBucket     string
+	seatingVisibleFrom time.Time
+	visitorIDs         *visitorIDs
+	scheduler          *schedule.Scheduler
 }
 
-func NewController(isProd bool, t *template.Template, guestStore database.GuestStore, logger *log.Logger, viewData *models.ViewData, secretCookieKey []byte, apiKey, s3AssetsBucket string) *Controller {
+func NewController(isProd bool, t *template.Template, guestStore database.GuestStore, logger *slog.Logger, viewData *models.ViewData, secretCookieKey []byte, apiKey, s3AssetsBucket string, seatingVisibleFrom time.Time, trustedProxies []netip.Prefix, scheduler *schedule.Scheduler) *Controller {
+	content := &atomic.Pointer[models.Content]{}
+	content.Store(viewData.Content)
+
 	return &Controller{
-		isProd:          isProd,
-		tpl:             t,
-		guestStore:      guestStore,
-		logger:          logger,
-		viewData:        viewData,
-		secretCookieKey: secretCookieKey,
-		apiKey:          apiKey,
-		s3AssetsBucket:  s3AssetsBucket,
+		isProd:             isProd,
+		tpl:                t,
+		guestStore:         guestStore,
+		logger:             logger,
+		viewData:           viewData,
+		content:            content,
+		secretCookieKey:    secretCookieKey,
+		apiKey:             apiKey,
+		s3AssetsBucket:     s3AssetsBucket,
+		seatingVisibleFrom: seatingVisibleFrom,
+		visitorIDs:         &visitorIDs{trustedProxies: trustedProxies},
+		scheduler:          scheduler,
 	}
 }
 
+// pageData returns a copy of the view data for a single request to fill in.
+// The Controller's view data is shared by every request, so per-request
+// fields must never be set on it. The content can be edited while the server
+// runs, so it is held apart and read into each copy.
+func (c Controller) pageData() models.ViewData {
+	data := *c.viewData
+	data.Content = c.content.Load()
+	return data
+}
+
 var ErrInvalidGuest error = errors.New("guestCode is invalid")
 
 func (c Controller) RSVP(w http.ResponseWriter, req *http.Request) {
@@ -49,10 +76,11 @@ func (c Controller) RSVP(w http.ResponseWriter, req *http.Request) {
 			guestCode := req.FormValue("guest-code")
 			re := regexp.MustCompile(`^[A-Z][a-z]+-[A-Za-z0-9]+$`)
 			if len(guestCode) != 12 || !re.MatchString(guestCode) {
-				c.logger.Printf("invalid code %s was used\n", guestCode)
+				c.logger.InfoContext(req.Context(), "invalid code was used")
+				metrics.InvalidCodeAttempted()
 				invalidGuestCookie := cookies.GenerateCookie(cookies.SessionTokenName, models.InvalidGuestKey, c.isProd)
 				if err := cookies.WriteEncrypted(w, invalidGuestCookie, c.secretCookieKey); err != nil {
-					c.logger.Printf("could not write invalid guest cookie: %v\n", err)
+					c.logger.ErrorContext(req.Context(), "could not write invalid guest cookie", "error", err)
 				}
 				http.Redirect(w, req, "/", http.StatusFound)
 				return
@@ -60,25 +88,26 @@ func (c Controller) RSVP(w http.ResponseWriter, req *http.Request) {
 
 			i, err := c.guestStore.GetGuest(guestCode)
 			if i == nil || guestCode == "" {
-				c.logger.Printf("invalid code %s was used\n", guestCode)
+				c.logger.InfoContext(req.Context(), "invalid code was used")
+				metrics.InvalidCodeAttempted()
 				invalidGuestCookie := cookies.GenerateCookie(cookies.SessionTokenName, models.InvalidGuestKey, c.isProd)
 				if err := cookies.WriteEncrypted(w, invalidGuestCookie, c.secretCookieKey); err != nil {
-					c.logger.Printf("could not write invalid guest cookie: %v\n", err)
+					c.logger.ErrorContext(req.Context(), "could not write invalid guest cookie", "error", err)
 				}
 				http.Redirect(w, req, "/", http.StatusFound)
 				return
 			}
 			if err != nil {
-				c.logger.Printf("could not get guest: %v\n", err)
+				c.logger.ErrorContext(req.Context(), "could not get guest", "error", err)
 				w.WriteHeader(http.StatusBadRequest)
 				http.Redirect(w, req, "/error", http.StatusFound)
 				return
 			}
 
-			c.viewData.Guest = i
 			guest = i
+			logging.SetGuestID(req.Context(), guest.ID)
 		} else {
-			c.logger.Println(err)
+			c.logger.ErrorContext(req.Context(), "could not get guest from cookie", "error", err)
 			http.Redirect(w, req, "/error", http.StatusFound)
 			return
 		}
@@ -86,14 +115,18 @@ func (c Controller) RSVP(w http.ResponseWriter, req *http.Request) {
 
 	guestCookie := cookies.GenerateCookie(cookies.SessionTokenName, guest.Code, c.isProd)
 	if err := cookies.WriteEncrypted(w, guestCookie, c.secretCookieKey); err != nil {
-		c.logger.Printf("for guest %v could not write guest cookie: %v\n", guest.Code, err)
+		c.logger.ErrorContext(req.Context(), "could not write guest cookie", "error", err)
 	}
 
+	c.recordVisit(req, guest.ID, "rsvp")
+
 	attendance := req.FormValue("attendance")
 	if attendance == "true" {
-		c.guestStore.UpdateGuestAttendance(guest.Code, true, guest.FormCompleted)
+		c.guestStore.UpdateGuestAttendance(guest.Code, true, guest.FormCompleted, models.ActorGuest)
+		metrics.RSVPSubmitted(metrics.OutcomeAccepted)
 	} else {
-		c.guestStore.UpdateGuestAttendance(guest.Code, false, true)
+		c.guestStore.UpdateGuestAttendance(guest.Code, false, true, models.ActorGuest)
+		metrics.RSVPSubmitted(metrics.OutcomeDeclined)
 	}
 
 	http.Redirect(w, req, "/", http.StatusFound)
@@ -119,6 +152,7 @@ func (c Controller) getGuestFromCookie(w http.ResponseWriter, req *http.Request)
 		return &models.InvalidGuest, fmt.Errorf("guestCode %s: %w", guestCode, ErrInvalidGuest)
 	}
 
+	logging.SetGuestID(req.Context(), guest.ID)
 	return guest, nil
 }
 
@@ -129,7 +163,7 @@ func (c Controller) GuestDetails(w http.ResponseWriter, req *http.Request) {
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
-		c.logger.Printf("could not get guest: %v\n", err)
+		c.logger.ErrorContext(req.Context(), "could not get guest", "error", err)
 		w.WriteHeader(http.StatusBadRequest)
 		http.Redirect(w, req, "/error", http.StatusFound)
 		return
@@ -138,76 +172,97 @@ func (c Controller) GuestDetails(w http.ResponseWriter, req *http.Request) {
 	detailsAllValid := true
 
 	email := req.FormValue("email")
-	if !strings.Contains(email, "@") || !strings.Contains(email, ".") || len(email) < 6 {
-		c.logger.Println(fmt.Sprintf("email %s for guestCode %s is invalid", email, guest.Code))
+	if !validate.Email(email) {
+		c.logger.InfoContext(req.Context(), "guest details are invalid", "field", "email")
+		metrics.ValidationFailed("email")
 		if err := c.guestStore.UpdateSessionInvalidEmail(guest.Code, true); err != nil {
-			c.logger.Printf("could not update session %s that email is invalid: %v", guest.Code, err)
+			c.logger.ErrorContext(req.Context(), "could not update session that email is invalid", "error", err)
 		}
 		if err := c.guestStore.UpdateGuestInvalidDetails(guest.Code, true); err != nil {
-			c.logger.Printf("could not update that guest %s details are invalid: %v", guest.Code, err)
+			c.logger.ErrorContext(req.Context(), "could not update that guest details are invalid", "error", err)
 		}
 		detailsAllValid = false
 	} else {
 		if err := c.guestStore.UpdateSessionInvalidEmail(guest.Code, false); err != nil {
-			c.logger.Printf("could not update session %s that email is valid: %v", guest.Code, err)
+			c.logger.ErrorContext(req.Context(), "could not update session that email is valid", "error", err)
 		}
-		if err := c.guestStore.UpdateGuestEmail(guest.Code, email); err != nil {
-			c.logger.Printf("could not update guest %s email: %v", guest.Code, err)
+		if err := c.guestStore.UpdateGuestEmail(guest.Code, email, models.ActorGuest); err != nil {
+			c.logger.ErrorContext(req.Context(), "could not update guest email", "error", err)
 		}
 	}
 
 	phoneNumber := req.FormValue("phone-number")
 	phoneNumber = strings.ReplaceAll(phoneNumber, " ", "")
-	rePhoneNumber := regexp.MustCompile(`^[0-9+][0-9]+$`)
-	if !rePhoneNumber.MatchString(phoneNumber) {
-		c.logger.Println(fmt.Sprintf("phoneNumber %s for guestCode %s is invalid", phoneNumber, guest.Code))
+	if !validate.PhoneNumber(phoneNumber) {
+		c.logger.InfoContext(req.Context(), "guest details are invalid", "field", "phone_number")
+		metrics.ValidationFailed("phone_number")
 		if err := c.guestStore.UpdateSessionInvalidPhoneNumber(guest.Code, true); err != nil {
-			c.logger.Printf("could not update session %s that phone number is invalid: %v", guest.Code, err)
+			c.logger.ErrorContext(req.Context(), "could not update session that phone number is invalid", "error", err)
 		}
 		if err := c.guestStore.UpdateGuestInvalidDetails(guest.Code, true); err != nil {
-			c.logger.Printf("could not update that guest %s details are invalid: %v", guest.Code, err)
+			c.logger.ErrorContext(req.Context(), "could not update that guest details are invalid", "error", err)
 		}
 		detailsAllValid = false
 	} else {
 		if err := c.guestStore.UpdateSessionInvalidPhoneNumber(guest.Code, false); err != nil {
-			c.logger.Printf("could not update session %s that phone number is valid: %v", guest.Code, err)
+			c.logger.ErrorContext(req.Context(), "could not update session that phone number is valid", "error", err)
 		}
-		if err := c.guestStore.UpdateGuestPhoneNumber(guest.Code, phoneNumber); err != nil {
-			c.logger.Printf("could not update guest %s phone number: %v", guest.Code, err)
+		if err := c.guestStore.UpdateGuestPhoneNumber(guest.Code, phoneNumber, models.ActorGuest); err != nil {
+			c.logger.ErrorContext(req.Context(), "could not update guest phone number", "error", err)
 		}
 	}
 
 	mealChoice := req.FormValue("meal-choice")
-	if err := c.guestStore.UpdateGuestMealChoice(guest.Code, mealChoice); err != nil {
-		c.logger.Printf("could not update guest %s meal choice: %v", guest.Code, err)
+	if err := c.guestStore.UpdateGuestMealChoice(guest.Code, mealChoice, models.ActorGuest); err != nil {
+		c.logger.ErrorContext(req.Context(), "could not update guest meal choice", "error", err)
 	}
 
 	// Normalize the input
 	dietaryRequirements := strings.ReplaceAll(req.FormValue("dietary-requirements"), "\n", " ")
 	dietaryRequirements = strings.TrimSpace(dietaryRequirements)
-	reDietaryRequirements := regexp.MustCompile(`^(?:(?:[A-Za-z’\'\.\,!\"#&()\-£$\d*?/~@\[\]\{\}=+_^%|]{1,100})(?:\s+|$|\.))*(?:[A-Za-z\'’\.\,!\"#&()\-£$\d*?/~@\[\]\{\}=+_^%|]{1,100})$`)
-	dietaryRequirementsValid := true
-	switch {
-	case len(dietaryRequirements) > 500:
-		dietaryRequirementsValid = false
-	case len(dietaryRequirements) > 0 && !reDietaryRequirements.MatchString(dietaryRequirements):
-		dietaryRequirementsValid = false
-	}
-	if !dietaryRequirementsValid {
-		c.logger.Println(fmt.Sprintf("dietaryRequirements %s for guestCode %s is invalid", dietaryRequirements, guest.Code))
+	if !validate.DietaryRequirements(dietaryRequirements) {
+		c.logger.InfoContext(req.Context(), "guest details are invalid", "field", "dietary_requirements")
+		metrics.ValidationFailed("dietary_requirements")
 		if err := c.guestStore.UpdateSessionInvalidDietaryRequirements(guest.Code, true); err != nil {
-			c.logger.Printf("could not update session %s that dietary requirements are invalid: %v", guest.Code, err)
+			c.logger.ErrorContext(req.Context(), "could not update session that dietary requirements are invalid", "error", err)
 		}
 		if err := c.guestStore.UpdateGuestInvalidDetails(guest.Code, true); err != nil {
-			c.logger.Printf("could not update that guest %s details are invalid: %v", guest.Code, err)
+			c.logger.ErrorContext(req.Context(), "could not update that guest details are invalid", "error", err)
 		}
 		detailsAllValid = false
 	} else {
 		if err := c.guestStore.UpdateSessionInvalidDietaryRequirements(guest.Code, false); err != nil {
-			c.logger.Printf("could not update session %s that dietary requirements are valid: %v", guest.Code, err)
+			c.logger.ErrorContext(req.Context(), "could not update session that dietary requirements are valid", "error", err)
+		}
+		if err := c.guestStore.UpdateGuestDietaryRequirements(guest.Code, dietaryRequirements, models.ActorGuest); err != nil {
+			c.logger.ErrorContext(req.Context(), "could not update guest dietary requirements", "error", err)
+		}
+	}
+
+	address := models.Address{
+		Line1:    strings.TrimSpace(req.FormValue("address-line-1")),
+		Line2:    strings.TrimSpace(req.FormValue("address-line-2")),
+		City:     strings.TrimSpace(req.FormValue("address-city")),
+		County:   strings.TrimSpace(req.FormValue("address-county")),
+		Postcode: strings.ToUpper(strings.TrimSpace(req.FormValue("address-postcode"))),
+		Country:  strings.TrimSpace(req.FormValue("address-country")),
+	}
+	if !validate.Address(address) {
+		c.logger.InfoContext(req.Context(), "guest details are invalid", "field", "address")
+		metrics.ValidationFailed("address")
+		if err := c.guestStore.UpdateSessionInvalidAddress(guest.Code, true); err != nil {
+			c.logger.ErrorContext(req.Context(), "could not update session that address is invalid", "error", err)
 		}
-		if err := c.guestStore.UpdateGuestDietaryRequirements(guest.Code, dietaryRequirements); err != nil {
-			c.logger.Printf("could not update guest %s dietary requirements: %v", guest.Code, err)
+		if err := c.guestStore.UpdateGuestInvalidDetails(guest.Code, true); err != nil {
+			c.logger.ErrorContext(req.Context(), "could not update that guest details are invalid", "error", err)
+		}
+		detailsAllValid = false
+	} else {
+		if err := c.guestStore.UpdateSessionInvalidAddress(guest.Code, false); err != nil {
+			c.logger.ErrorContext(req.Context(), "could not update session that address is valid", "error", err)
+		}
+		if err := c.guestStore.UpdateGuestAddress(guest.Code, address, models.ActorGuest); err != nil {
+			c.logger.ErrorContext(req.Context(), "could not update guest address", "error", err)
 		}
 	}
 
@@ -217,7 +272,7 @@ func (c Controller) GuestDetails(w http.ResponseWriter, req *http.Request) {
 	}
 
 	if err := c.guestStore.UpdateGuestDetailsProvidedSuccessfully(guest.Code); err != nil {
-		c.logger.Printf("could not update guest %s details: %v", guest.Code, err)
+		c.logger.ErrorContext(req.Context(), "could not update guest details", "error", err)
 	}
 
 	http.Redirect(w, req, "/", http.StatusFound)
@@ -230,26 +285,28 @@ func (c Controller) ChangeDetails(w http.ResponseWriter, req *http.Request) {
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
-		c.logger.Printf("could not get guest: %v\n", err)
+		c.logger.ErrorContext(req.Context(), "could not get guest", "error", err)
 		w.WriteHeader(http.StatusBadRequest)
 		http.Redirect(w, req, "/error", http.StatusFound)
 		return
 	}
 	guestCookie := cookies.GenerateCookie(cookies.SessionTokenName, guest.Code, c.isProd)
 	if err := cookies.WriteEncrypted(w, guestCookie, c.secretCookieKey); err != nil {
-		c.logger.Printf("for guest %v could not write guest cookie: %v\n", guest.Code, err)
+		c.logger.ErrorContext(req.Context(), "could not write guest cookie", "error", err)
 	}
 
 	sessionData, err := c.guestStore.GetSessionData(guest.Code)
 	if err != nil {
-		c.logger.Printf("for guest %v could not get session data: %v\n", guest.Code, err)
+		c.logger.ErrorContext(req.Context(), "could not get session data", "error", err)
 	}
 
-	c.viewData.Guest = guest
-	c.viewData.SessionData = sessionData
-	c.guestStore.UpdatePageVisit(guest.ID, "change-details")
+	data := c.pageData()
+	data.Guest = guest
+	data.SessionData = sessionData
+	data.Lang = c.chooseLanguage(w, req, guest)
+	c.recordVisit(req, guest.ID, "change-details")
 
-	c.tpl.ExecuteTemplate(w, "guest_details.gohtml", c.viewData)
+	c.tpl.ExecuteTemplate(w, "guest_details.gohtml", &data)
 }
 
 func (c Controller) ChangeAttendanceResponse(w http.ResponseWriter, req *http.Request) {
@@ -259,38 +316,46 @@ func (c Controller) ChangeAttendanceResponse(w http.ResponseWriter, req *http.Re
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
-		c.logger.Printf("could not get guest: %v\n", err)
+		c.logger.ErrorContext(req.Context(), "could not get guest", "error", err)
 		w.WriteHeader(http.StatusBadRequest)
 		http.Redirect(w, req, "/error", http.StatusFound)
 		return
 	}
 	guestCookie := cookies.GenerateCookie(cookies.SessionTokenName, guest.Code, c.isProd)
 	if err := cookies.WriteEncrypted(w, guestCookie, c.secretCookieKey); err != nil {
-		c.logger.Printf("for guest %v could not write guest cookie: %v\n", guest.Code, err)
+		c.logger.ErrorContext(req.Context(), "could not write guest cookie", "error", err)
 	}
-	c.viewData.Guest = guest
-	c.guestStore.UpdatePageVisit(guest.ID, "change-attendance-response")
+	data := c.pageData()
+	data.Guest = guest
+	data.Lang = c.chooseLanguage(w, req, guest)
+	c.recordVisit(req, guest.ID, "change-attendance-response")
 
-	c.tpl.ExecuteTemplate(w, "change_attendance_response.gohtml", c.viewData)
+	c.tpl.ExecuteTemplate(w, "change_attendance_response.gohtml", &data)
 }
 
 func (c Controller) Index(w http.ResponseWriter, req *http.Request) {
+	data := c.pageData()
+	// Invitation QR codes link here with the guest's code to prefill the form
+	data.GuestCode = req.URL.Query().Get("code")
+
 	guest, err := c.getGuestFromCookie(w, req)
-	if guest == nil || guest.Code == models.InvalidGuestKey {
-		c.viewData.Guest = nil
-	}
+	data.Lang = c.chooseLanguage(w, req, guest)
 	if err != nil {
 		switch {
 		case err == http.ErrNoCookie:
-			c.logger.Printf("new visitor")
-			c.tpl.ExecuteTemplate(w, "index.gohtml", c.viewData)
+			c.logger.InfoContext(req.Context(), "new visitor")
+			if req.URL.Path == "/" {
+				c.recordLanding(req)
+			}
+			c.tpl.ExecuteTemplate(w, "index.gohtml", &data)
 			return
 		case err == ErrInvalidGuest || errors.Unwrap(err) == ErrInvalidGuest:
-			c.logger.Printf("invalid guest code")
-			c.tpl.ExecuteTemplate(w, "invalid_guest.gohtml", c.viewData)
+			c.logger.InfoContext(req.Context(), "invalid guest code")
+			c.recordVisit(req, 0, "invalid-guest")
+			c.tpl.ExecuteTemplate(w, "invalid_guest.gohtml", &data)
 			return
 		default:
-			c.logger.Printf("could not get guest: %v\n", err)
+			c.logger.ErrorContext(req.Context(), "could not get guest", "error", err)
 			w.WriteHeader(http.StatusBadRequest)
 			http.Redirect(w, req, "/error", http.StatusFound)
 			return
@@ -300,39 +365,48 @@ func (c Controller) Index(w http.ResponseWriter, req *http.Request) {
 	if guest != nil {
 		guestCookie := cookies.GenerateCookie(cookies.SessionTokenName, guest.Code, c.isProd)
 		if err := cookies.WriteEncrypted(w, guestCookie, c.secretCookieKey); err != nil {
-			c.logger.Printf("for guest %v could not write guest cookie: %v\n", guest.Code, err)
+			c.logger.ErrorContext(req.Context(), "could not write guest cookie", "error", err)
 		}
-		c.viewData.Guest = guest
-		c.logger.Printf("guest %s hit index", guest.Code)
+		data.Guest = guest
+		c.logger.InfoContext(req.Context(), "guest hit index")
 
 		switch {
 		case !guest.FormStarted:
 			blankCookie := cookies.GenerateBlankCookie(cookies.SessionTokenName, c.isProd)
 			http.SetCookie(w, blankCookie)
-			c.viewData.Guest = nil
+			data.Guest = nil
 
-			c.tpl.ExecuteTemplate(w, "index.gohtml", c.viewData)
+			c.recordVisit(req, guest.ID, "index")
+			c.tpl.ExecuteTemplate(w, "index.gohtml", &data)
 			return
 		case !guest.Attendance:
-			c.guestStore.UpdatePageVisit(guest.ID, "guest-declined")
-			c.tpl.ExecuteTemplate(w, "guest_declined.gohtml", c.viewData)
+			c.recordVisit(req, guest.ID, "guest-declined")
+			c.tpl.ExecuteTemplate(w, "guest_declined.gohtml", &data)
 			return
 		case guest.InvalidDetails:
 			sessionData, err := c.guestStore.GetSessionData(guest.Code)
 			if err != nil {
-				c.logger.Printf("for guest %v could not get session data: %v\n", guest.Code, err)
+				c.logger.ErrorContext(req.Context(), "could not get session data", "error", err)
 			}
-			c.viewData.SessionData = sessionData
-			c.guestStore.UpdatePageVisit(guest.ID, "guest-details")
-			c.tpl.ExecuteTemplate(w, "invalid_details.gohtml", c.viewData)
+			data.SessionData = sessionData
+			c.recordVisit(req, guest.ID, "invalid-details")
+			c.tpl.ExecuteTemplate(w, "invalid_details.gohtml", &data)
 			return
 		case !guest.DetailsProvided:
-			c.guestStore.UpdatePageVisit(guest.ID, "guest-details")
-			c.tpl.ExecuteTemplate(w, "guest_details.gohtml", c.viewData)
+			c.recordVisit(req, guest.ID, "guest-details")
+			c.tpl.ExecuteTemplate(w, "guest_details.gohtml", &data)
 			return
 		default:
-			c.guestStore.UpdatePageVisit(guest.ID, "guest-accepted")
-			c.tpl.ExecuteTemplate(w, "guest_accepted.gohtml", c.viewData)
+			// Tables are only shown once the seating plan is settled
+			if !c.seatingVisibleFrom.IsZero() && time.Now().After(c.seatingVisibleFrom) {
+				seatAssignment, err := c.guestStore.GetSeatAssignment(guest.ID)
+				if err != nil {
+					c.logger.ErrorContext(req.Context(), "could not get seat assignment", "error", err)
+				}
+				data.SeatAssignment = seatAssignment
+			}
+			c.recordVisit(req, guest.ID, "guest-accepted")
+			c.tpl.ExecuteTemplate(w, "guest_accepted.gohtml", &data)
 			return
 		}
 	}
@@ -342,7 +416,6 @@ func (c Controller) Index(w http.ResponseWriter, req *http.Request) {
 func (c Controller) ResetGuest(w http.ResponseWriter, req *http.Request) {
 	blankCookie := cookies.GenerateBlankCookie(cookies.SessionTokenName, c.isProd)
 	http.SetCookie(w, blankCookie)
-	c.viewData.Guest = nil
 
 	http.Redirect(w, req, "/", http.StatusFound)
 }
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/schedule"
+)
+
+// HealthStatus is the public health check. Errors are left out as they can
+// name buckets and paths.
+type HealthStatus struct {
+	Status            string           `json:"status"`
+	Backup            *schedule.Status `json:"backup,omitempty"`
+	IncrementalBackup *schedule.Status `json:"incremental_backup,omitempty"`
+	LogRotation       *schedule.Status `json:"log_rotation,omitempty"`
+}
+
+// Health reports "ok", or "degraded" when the last backup, incremental backup
+// or log rotation failed. It always responds 200 while the server is up so
+// that a failed backup doesn't get the server restarted.
+func (c Controller) Health(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	backupStatus, backupOK := c.jobHealth(schedule.JobBackup)
+	incrementalStatus, incrementalOK := c.jobHealth(schedule.JobIncrementalBackup)
+	logRotationStatus, logRotationOK := c.jobHealth(schedule.JobLogRotation)
+	health := HealthStatus{
+		Status:            "ok",
+		Backup:            backupStatus,
+		IncrementalBackup: incrementalStatus,
+		LogRotation:       logRotationStatus,
+	}
+	if !backupOK || !incrementalOK || !logRotationOK {
+		health.Status = "degraded"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(health); err != nil {
+		c.logger.ErrorContext(req.Context(), "error writing health", "error", err)
+	}
+}
+
+// jobHealth returns the status of a job without its error, or nil if the job
+// isn't set up, and whether its last run succeeded.
+func (c Controller) jobHealth(name string) (*schedule.Status, bool) {
+	status, ok := c.scheduler.Status(name)
+	if !ok {
+		return nil, true
+	}
+
+	healthy := status.OK()
+	status.LastError = ""
+	return &status, healthy
+}
+
+// RunBackup backs up the database straight away and responds with the result.
+func (c Controller) RunBackup(w http.ResponseWriter, req *http.Request) {
+	c.logger.InfoContext(req.Context(), "/run-backup request")
+
+	if req.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := c.scheduler.RunNow(schedule.JobBackup)
+	if errors.Is(err, schedule.ErrUnknownJob) {
+		http.Error(w, "Backups are not set up", http.StatusNotFound)
+		return
+	}
+
+	code := http.StatusOK
+	if err != nil {
+		c.logger.ErrorContext(req.Context(), "error running backup", "error", err)
+		code = http.StatusInternalServerError
+	}
+
+	status, _ := c.scheduler.Status(schedule.JobBackup)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		c.logger.ErrorContext(req.Context(), "error writing backup status", "error", err)
+	}
+}
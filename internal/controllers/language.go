@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/cookies"
+	"github.com/nesquikmike/wedding-rsvps/internal/i18n"
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+// chooseLanguage returns the language the page is shown in. A language picked
+// from the switcher wins and is remembered, for the guest if they have entered
+// their code and otherwise in a cookie. Failing that the guest's saved choice
+// is used, then one picked before they entered their code, then the browser's.
+func (c Controller) chooseLanguage(w http.ResponseWriter, req *http.Request, guest *models.Guest) string {
+	if guest != nil && guest.Code == models.InvalidGuestKey {
+		guest = nil
+	}
+
+	// only a language the visitor picked themselves is saved for the guest,
+	// so a browser's default can't override one they chose elsewhere
+	picked := false
+	language := req.URL.Query().Get("lang")
+	if i18n.Supported(language) {
+		picked = true
+		if err := cookies.Write(w, cookies.GenerateCookie(cookies.LanguageName, language, c.isProd)); err != nil {
+			c.logger.ErrorContext(req.Context(), "could not write language cookie", "error", err)
+		}
+	} else if guest != nil && i18n.Supported(guest.Language) {
+		language = guest.Language
+	} else if cookieLanguage, err := cookies.Read(req, cookies.LanguageName); err == nil && i18n.Supported(cookieLanguage) {
+		picked = true
+		language = cookieLanguage
+	} else {
+		language = i18n.Match(req.Header.Get("Accept-Language"))
+	}
+
+	if picked && guest != nil && guest.Language != language {
+		if err := c.guestStore.UpdateGuestLanguage(guest.Code, language, models.ActorGuest); err != nil {
+			c.logger.ErrorContext(req.Context(), "could not update guest language", "error", err)
+		} else {
+			guest.Language = language
+		}
+	}
+
+	return language
+}
@@ -2,9 +2,13 @@ package controllers
 
 import (
 	"bytes"
+	"crypto/subtle"
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/url"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/cookies"
 )
 
 func (c Controller) ApiKeyMiddleware(next http.HandlerFunc) http.HandlerFunc {
@@ -35,3 +39,18 @@ func (c Controller) ApiKeyMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		next.ServeHTTP(w, req)
 	}
 }
+
+// AdminCookieMiddleware guards the pages ushers use from their phones, which
+// can't send the api key in a JSON body. Requests without a valid admin
+// cookie are sent to the login page.
+func (c Controller) AdminCookieMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		apiKey, err := cookies.ReadEncrypted(req, cookies.AdminTokenName, c.secretCookieKey)
+		if err != nil || subtle.ConstantTimeCompare([]byte(apiKey), []byte(c.apiKey)) != 1 {
+			http.Redirect(w, req, "/admin/login?next="+url.QueryEscape(req.URL.Path), http.StatusFound)
+			return
+		}
+
+		next.ServeHTTP(w, req)
+	}
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+)
+
+// FAQ, Travel and Accommodation serve the information pages linked from the
+// header. They are shown to guests who have entered their code, or to
+// everyone when PAGES_VISIBLE_TO is everyone.
+func (c Controller) FAQ(w http.ResponseWriter, req *http.Request) {
+	c.infoPage(w, req, "faq", "faq.gohtml")
+}
+
+func (c Controller) Travel(w http.ResponseWriter, req *http.Request) {
+	c.infoPage(w, req, "travel", "travel.gohtml")
+}
+
+func (c Controller) Accommodation(w http.ResponseWriter, req *http.Request) {
+	c.infoPage(w, req, "accommodation", "accommodation.gohtml")
+}
+
+func (c Controller) infoPage(w http.ResponseWriter, req *http.Request, page, templateName string) {
+	guest, err := c.getGuestFromCookie(w, req)
+	if err != nil {
+		if err != http.ErrNoCookie && !errors.Is(err, ErrInvalidGuest) {
+			c.logger.ErrorContext(req.Context(), "could not get guest", "error", err)
+			http.Redirect(w, req, "/error", http.StatusFound)
+			return
+		}
+		guest = nil
+	}
+
+	if guest == nil && !c.viewData.PagesPublic {
+		http.Redirect(w, req, "/", http.StatusFound)
+		return
+	}
+
+	data := c.pageData()
+	data.Guest = guest
+	data.Lang = c.chooseLanguage(w, req, guest)
+	guestID := 0
+	if guest != nil {
+		guestID = guest.ID
+	}
+	c.recordVisit(req, guestID, page)
+
+	c.tpl.ExecuteTemplate(w, templateName, &data)
+}
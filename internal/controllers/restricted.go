@@ -1,13 +1,20 @@
 package controllers
 
 import (
+	"bytes"
 	"database/sql"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/guestlist"
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+	"github.com/nesquikmike/wedding-rsvps/internal/printables"
 )
 
 type UserRequest struct {
@@ -33,17 +40,17 @@ func (c Controller) AddGuest(w http.ResponseWriter, req *http.Request) {
 	}
 
 	name := userReq.GuestName
-	c.logger.Printf("/add-guest request for name %v", name)
+	c.logger.InfoContext(req.Context(), "/add-guest request", "name", name)
 
 	err = c.guestStore.InsertGuest(name)
 	if err != nil {
-		c.logger.Printf("error inserting guest %v: %v\n", name, err)
+		c.logger.ErrorContext(req.Context(), "error inserting guest", "name", name, "error", err)
 		http.Error(w, "Error inserting guest", http.StatusInternalServerError)
 	}
 
 	code, err := c.guestStore.GetGuestCode(name)
 	if err != nil {
-		c.logger.Printf("error getting guest code for guest %v: %v\n", name, err)
+		c.logger.ErrorContext(req.Context(), "error getting guest code", "name", name, "error", err)
 		http.Error(w, "Error returning guest code", http.StatusInternalServerError)
 	}
 
@@ -70,17 +77,17 @@ func (c Controller) GetGuest(w http.ResponseWriter, req *http.Request) {
 	}
 
 	name := userReq.GuestName
-	c.logger.Printf("/get-guest request for name %v", name)
+	c.logger.InfoContext(req.Context(), "/get-guest request", "name", name)
 
 	code, err := c.guestStore.GetGuestCode(name)
 	if err != nil {
-		c.logger.Printf("error getting guest code %v: %v\n", name, err)
+		c.logger.ErrorContext(req.Context(), "error getting guest code", "name", name, "error", err)
 		http.Error(w, "Error returning guest code", http.StatusInternalServerError)
 	}
 
 	guest, err := c.guestStore.GetGuest(code)
 	if err != nil {
-		c.logger.Printf("error getting guest %v: %v\n", code, err)
+		c.logger.ErrorContext(req.Context(), "error getting guest", "name", name, "error", err)
 		http.Error(w, "Error returning guest", http.StatusInternalServerError)
 	}
 
@@ -89,7 +96,7 @@ func (c Controller) GetGuest(w http.ResponseWriter, req *http.Request) {
 }
 
 func (c Controller) GetRSVPs(w http.ResponseWriter, r *http.Request) {
-	c.logger.Printf("/get-rsvps request")
+	c.logger.InfoContext(r.Context(), "/get-rsvps request")
 
 	if r.Method != http.MethodGet {
 		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
@@ -99,7 +106,7 @@ func (c Controller) GetRSVPs(w http.ResponseWriter, r *http.Request) {
 	// Example query (customize as needed)
 	rows, err := c.guestStore.GetRSVPs()
 	if err != nil {
-		c.logger.Printf("Query error: %v", err)
+		c.logger.ErrorContext(r.Context(), "Query error", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
@@ -126,7 +133,7 @@ func (c Controller) GetRSVPs(w http.ResponseWriter, r *http.Request) {
 		"Form Completed",
 	}
 	if err := csvWriter.Write(headers); err != nil {
-		c.logger.Printf("CSV header error: %v", err)
+		c.logger.ErrorContext(r.Context(), "CSV header error", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
@@ -151,7 +158,7 @@ func (c Controller) GetRSVPs(w http.ResponseWriter, r *http.Request) {
 			&formStarted,
 			&formCompleted,
 		); err != nil {
-			c.logger.Printf("Row scan error: %v", err)
+			c.logger.ErrorContext(r.Context(), "Row scan error", "error", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
@@ -199,7 +206,7 @@ func (c Controller) GetRSVPs(w http.ResponseWriter, r *http.Request) {
 			strconv.FormatBool(formCompletedBool),
 		}
 		if err := csvWriter.Write(record); err != nil {
-			c.logger.Printf("CSV write error: %v", err)
+			c.logger.ErrorContext(r.Context(), "CSV write error", "error", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
@@ -208,14 +215,14 @@ func (c Controller) GetRSVPs(w http.ResponseWriter, r *http.Request) {
 	// Flush the CSV writer and send the response
 	csvWriter.Flush()
 	if err := csvWriter.Error(); err != nil {
-		c.logger.Printf("CSV flush error: %v", err)
+		c.logger.ErrorContext(r.Context(), "CSV flush error", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 }
 
 func (c Controller) GetVisitsData(w http.ResponseWriter, r *http.Request) {
-	c.logger.Printf("/get-visits-data request")
+	c.logger.InfoContext(r.Context(), "/get-visits-data request")
 
 	if r.Method != http.MethodGet {
 		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
@@ -225,7 +232,7 @@ func (c Controller) GetVisitsData(w http.ResponseWriter, r *http.Request) {
 	// Example query (customize as needed)
 	rows, err := c.guestStore.GetVisitsData()
 	if err != nil {
-		c.logger.Printf("Query error: %v", err)
+		c.logger.ErrorContext(r.Context(), "Query error", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
@@ -245,7 +252,7 @@ func (c Controller) GetVisitsData(w http.ResponseWriter, r *http.Request) {
 		"Latest Visit Time",
 	}
 	if err := csvWriter.Write(headers); err != nil {
-		c.logger.Printf("CSV header error: %v", err)
+		c.logger.ErrorContext(r.Context(), "CSV header error", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
@@ -261,7 +268,7 @@ func (c Controller) GetVisitsData(w http.ResponseWriter, r *http.Request) {
 			&firstVisitTime,
 			&latestVisitTime,
 		); err != nil {
-			c.logger.Printf("Row scan error: %v", err)
+			c.logger.ErrorContext(r.Context(), "Row scan error", "error", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
@@ -274,7 +281,7 @@ func (c Controller) GetVisitsData(w http.ResponseWriter, r *http.Request) {
 			latestVisitTime,
 		}
 		if err := csvWriter.Write(record); err != nil {
-			c.logger.Printf("CSV write error: %v", err)
+			c.logger.ErrorContext(r.Context(), "CSV write error", "error", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
@@ -283,8 +290,304 @@ func (c Controller) GetVisitsData(w http.ResponseWriter, r *http.Request) {
 	// Flush the CSV writer and send the response
 	csvWriter.Flush()
 	if err := csvWriter.Error(); err != nil {
-		c.logger.Printf("CSV flush error: %v", err)
+		c.logger.ErrorContext(r.Context(), "CSV flush error", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (c Controller) GetVisitEvents(w http.ResponseWriter, r *http.Request) {
+	c.logger.InfoContext(r.Context(), "/get-visit-events request")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	visits, err := c.guestStore.GetVisitEvents()
+	if err != nil {
+		c.logger.ErrorContext(r.Context(), "Query error", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", "attachment;filename=visit_events.csv")
+	w.Header().Set("Content-Type", "text/csv")
+	csvWriter := csv.NewWriter(w)
+
+	headers := []string{
+		"ID",
+		"Guest ID",
+		"Visitor ID",
+		"Page Name",
+		"Referrer",
+		"User Agent",
+		"Time",
+	}
+	if err := csvWriter.Write(headers); err != nil {
+		c.logger.ErrorContext(r.Context(), "CSV header error", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	for _, visit := range visits {
+		var guestID string
+		if visit.GuestID != 0 {
+			guestID = strconv.Itoa(visit.GuestID)
+		}
+
+		record := []string{
+			strconv.Itoa(visit.ID),
+			guestID,
+			visit.VisitorID,
+			visit.Page,
+			visit.Referrer,
+			visit.UserAgent,
+			visit.CreatedAt,
+		}
+		if err := csvWriter.Write(record); err != nil {
+			c.logger.ErrorContext(r.Context(), "CSV write error", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		c.logger.ErrorContext(r.Context(), "CSV flush error", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 }
+
+type InvitationInsertsRequest struct {
+	Layout string `json:"layout"`
+}
+
+func (c Controller) GetInvitationInserts(w http.ResponseWriter, req *http.Request) {
+	c.logger.InfoContext(req.Context(), "/get-invitation-inserts request")
+
+	if req.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var insertsReq InvitationInsertsRequest
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	if err := json.Unmarshal(body, &insertsReq); err != nil {
+		http.Error(w, "Bad Request: Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if insertsReq.Layout != "" && insertsReq.Layout != printables.LayoutCards && insertsReq.Layout != printables.LayoutPages {
+		http.Error(w, "Bad Request: layout must be cards or pages", http.StatusBadRequest)
+		return
+	}
+
+	guests, err := c.guestStore.GetAllGuests(false)
+	if err != nil {
+		c.logger.ErrorContext(req.Context(), "error getting guests", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := printables.InvitationInserts(&buf, guests, c.viewData.Url, insertsReq.Layout); err != nil {
+		c.logger.ErrorContext(req.Context(), "error generating invitation inserts", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", "attachment;filename=invitation_inserts.pdf")
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Write(buf.Bytes())
+}
+
+type AddressesRequest struct {
+	AttendingOnly bool `json:"attending_only"`
+}
+
+// getRecipients reads the request filters and groups the matching guests into
+// one recipient per postal address.
+func (c Controller) getRecipients(req *http.Request) ([]models.Recipient, error) {
+	var addressesReq AddressesRequest
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(body, &addressesReq); err != nil {
+		return nil, err
+	}
+
+	guests, err := c.guestStore.GetAllGuests(false)
+	if err != nil {
+		return nil, err
+	}
+
+	if addressesReq.AttendingOnly {
+		var attending []models.Guest
+		for _, g := range guests {
+			if g.Attendance {
+				attending = append(attending, g)
+			}
+		}
+		guests = attending
+	}
+
+	return models.Recipients(guests), nil
+}
+
+func (c Controller) GetAddresses(w http.ResponseWriter, req *http.Request) {
+	c.logger.InfoContext(req.Context(), "/get-addresses request")
+
+	if req.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	recipients, err := c.getRecipients(req)
+	if err != nil {
+		c.logger.ErrorContext(req.Context(), "error getting recipients", "error", err)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", "attachment;filename=addresses.csv")
+	w.Header().Set("Content-Type", "text/csv")
+	csvWriter := csv.NewWriter(w)
+
+	headers := []string{
+		"Names",
+		"Address Line 1",
+		"Address Line 2",
+		"City",
+		"County",
+		"Postcode",
+		"Country",
+	}
+	if err := csvWriter.Write(headers); err != nil {
+		c.logger.ErrorContext(req.Context(), "CSV header error", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	for _, r := range recipients {
+		record := []string{
+			r.Addressee(),
+			r.Address.Line1,
+			r.Address.Line2,
+			r.Address.City,
+			r.Address.County,
+			r.Address.Postcode,
+			r.Address.Country,
+		}
+		if err := csvWriter.Write(record); err != nil {
+			c.logger.ErrorContext(req.Context(), "CSV write error", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		c.logger.ErrorContext(req.Context(), "CSV flush error", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (c Controller) GetAddressLabels(w http.ResponseWriter, req *http.Request) {
+	c.logger.InfoContext(req.Context(), "/get-address-labels request")
+
+	if req.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	recipients, err := c.getRecipients(req)
+	if err != nil {
+		c.logger.ErrorContext(req.Context(), "error getting recipients", "error", err)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := printables.AddressLabels(&buf, recipients); err != nil {
+		c.logger.ErrorContext(req.Context(), "error generating address labels", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", "attachment;filename=address_labels.pdf")
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Write(buf.Bytes())
+}
+
+type ImportGuestsRequest struct {
+	CSV    string `json:"csv"`
+	DryRun bool   `json:"dry_run"`
+}
+
+type ImportGuestsResponse struct {
+	DryRun bool                       `json:"dry_run"`
+	Errors guestlist.ValidationErrors `json:"errors,omitempty"`
+	Plan   *guestlist.Plan            `json:"plan,omitempty"`
+}
+
+func (c Controller) ImportGuests(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var importReq ImportGuestsRequest
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	if err := json.Unmarshal(body, &importReq); err != nil {
+		http.Error(w, "Bad Request: Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	c.logger.InfoContext(req.Context(), "/import-guests request", "dry_run", importReq.DryRun)
+
+	reader := csv.NewReader(strings.NewReader(importReq.CSV))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request: Invalid CSV: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	resp := ImportGuestsResponse{DryRun: importReq.DryRun}
+	status := http.StatusOK
+
+	plan, err := guestlist.Import(c.guestStore, records, importReq.DryRun, models.ActorAPI)
+	var validationErrs guestlist.ValidationErrors
+	switch {
+	case errors.As(err, &validationErrs):
+		resp.Errors = validationErrs
+		status = http.StatusBadRequest
+	case err != nil:
+		c.logger.ErrorContext(req.Context(), "error importing guests", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	default:
+		resp.Plan = &plan
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		c.logger.ErrorContext(req.Context(), "error writing import response", "error", err)
+	}
+}
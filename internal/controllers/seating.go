@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/database"
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+	"github.com/nesquikmike/wedding-rsvps/internal/printables"
+)
+
+type SeatingRequest struct {
+	Name     string `json:"name"`
+	Capacity int    `json:"capacity"`
+	Shape    string `json:"shape"`
+	Code     string `json:"code"`
+	Table    string `json:"table"`
+	Seat     int    `json:"seat"`
+	Size     string `json:"size"`
+}
+
+func readSeatingRequest(w http.ResponseWriter, req *http.Request, method string) (SeatingRequest, bool) {
+	var seatingReq SeatingRequest
+
+	if req.Method != method {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return seatingReq, false
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return seatingReq, false
+	}
+
+	if err := json.Unmarshal(body, &seatingReq); err != nil {
+		http.Error(w, "Bad Request: Invalid JSON", http.StatusBadRequest)
+		return seatingReq, false
+	}
+
+	return seatingReq, true
+}
+
+// validTable checks the fields needed to create or update a table, writing
+// an error response if they are wrong.
+func validTable(w http.ResponseWriter, seatingReq *SeatingRequest) bool {
+	if seatingReq.Name == "" {
+		http.Error(w, "Bad Request: name is required", http.StatusBadRequest)
+		return false
+	}
+	if seatingReq.Capacity < 1 {
+		http.Error(w, "Bad Request: capacity must be at least 1", http.StatusBadRequest)
+		return false
+	}
+	if seatingReq.Shape == "" {
+		seatingReq.Shape = models.TableShapeRound
+	}
+	if !slices.Contains(models.TableShapes, seatingReq.Shape) {
+		http.Error(w, fmt.Sprintf("Bad Request: shape must be one of %v", models.TableShapes), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func (c Controller) writeSeatingError(w http.ResponseWriter, req *http.Request, action string, err error) {
+	switch {
+	case errors.Is(err, database.ErrGuestNotFound), errors.Is(err, database.ErrTableNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, database.ErrTableExists), errors.Is(err, database.ErrTableFull), errors.Is(err, database.ErrSeatTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, database.ErrGuestNotAttending), errors.Is(err, database.ErrInvalidSeat):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		c.logger.ErrorContext(req.Context(), "admin action failed", "action", action, "error", err)
+		http.Error(w, fmt.Sprintf("Error trying to %s", action), http.StatusInternalServerError)
+	}
+}
+
+func (c Controller) CreateTable(w http.ResponseWriter, req *http.Request) {
+	seatingReq, ok := readSeatingRequest(w, req, http.MethodPost)
+	if !ok || !validTable(w, &seatingReq) {
+		return
+	}
+
+	c.logger.InfoContext(req.Context(), "/create-table request", "name", seatingReq.Name)
+
+	table := models.Table{Name: seatingReq.Name, Capacity: seatingReq.Capacity, Shape: seatingReq.Shape}
+	if err := c.guestStore.CreateTable(table); err != nil {
+		c.writeSeatingError(w, req, "create table", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf("%v table %v created for %v guests", table.Shape, table.Name, table.Capacity)))
+}
+
+func (c Controller) UpdateTable(w http.ResponseWriter, req *http.Request) {
+	seatingReq, ok := readSeatingRequest(w, req, http.MethodPost)
+	if !ok || !validTable(w, &seatingReq) {
+		return
+	}
+
+	c.logger.InfoContext(req.Context(), "/update-table request", "name", seatingReq.Name)
+
+	if err := c.guestStore.UpdateTable(seatingReq.Name, seatingReq.Capacity, seatingReq.Shape); err != nil {
+		c.writeSeatingError(w, req, "update table", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf("table %v updated", seatingReq.Name)))
+}
+
+func (c Controller) DeleteTable(w http.ResponseWriter, req *http.Request) {
+	seatingReq, ok := readSeatingRequest(w, req, http.MethodPost)
+	if !ok {
+		return
+	}
+
+	c.logger.InfoContext(req.Context(), "/delete-table request", "name", seatingReq.Name)
+
+	if err := c.guestStore.DeleteTable(seatingReq.Name); err != nil {
+		c.writeSeatingError(w, req, "delete table", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf("table %v deleted", seatingReq.Name)))
+}
+
+func (c Controller) AssignSeat(w http.ResponseWriter, req *http.Request) {
+	seatingReq, ok := readSeatingRequest(w, req, http.MethodPost)
+	if !ok {
+		return
+	}
+	if seatingReq.Code == "" || seatingReq.Table == "" {
+		http.Error(w, "Bad Request: code and table are required", http.StatusBadRequest)
+		return
+	}
+
+	c.logger.InfoContext(req.Context(), "/assign-seat request", "table", seatingReq.Table)
+
+	if err := c.guestStore.AssignSeat(seatingReq.Code, seatingReq.Table, seatingReq.Seat, models.ActorAPI); err != nil {
+		c.writeSeatingError(w, req, "assign seat", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf("guest %v seated at table %v", seatingReq.Code, seatingReq.Table)))
+}
+
+func (c Controller) UnassignSeat(w http.ResponseWriter, req *http.Request) {
+	seatingReq, ok := readSeatingRequest(w, req, http.MethodPost)
+	if !ok {
+		return
+	}
+	if seatingReq.Code == "" {
+		http.Error(w, "Bad Request: code is required", http.StatusBadRequest)
+		return
+	}
+
+	c.logger.InfoContext(req.Context(), "/unassign-seat request")
+
+	if err := c.guestStore.UnassignSeat(seatingReq.Code, models.ActorAPI); err != nil {
+		c.writeSeatingError(w, req, "unassign seat", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf("guest %v unseated", seatingReq.Code)))
+}
+
+func (c Controller) GetSeatingPlan(w http.ResponseWriter, req *http.Request) {
+	c.logger.InfoContext(req.Context(), "/get-seating-plan request")
+
+	if req.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	plan, err := c.guestStore.GetSeatingPlan()
+	if err != nil {
+		c.writeSeatingError(w, req, "get seating plan", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(plan); err != nil {
+		c.logger.ErrorContext(req.Context(), "error writing seating plan", "error", err)
+	}
+}
+
+func (c Controller) GetPlaceCards(w http.ResponseWriter, req *http.Request) {
+	c.logger.InfoContext(req.Context(), "/get-place-cards request")
+
+	if req.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	plan, err := c.guestStore.GetSeatingPlan()
+	if err != nil {
+		c.writeSeatingError(w, req, "get seating plan", err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := printables.PlaceCards(&buf, plan); err != nil {
+		c.logger.ErrorContext(req.Context(), "error generating place cards", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", "attachment;filename=place_cards.pdf")
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Write(buf.Bytes())
+}
+
+var tablePlanSizes = []string{"A1", "A2", "A3", "A4"}
+
+func (c Controller) GetTablePlan(w http.ResponseWriter, req *http.Request) {
+	seatingReq, ok := readSeatingRequest(w, req, http.MethodGet)
+	if !ok {
+		return
+	}
+
+	c.logger.InfoContext(req.Context(), "/get-table-plan request", "size", seatingReq.Size)
+
+	size := strings.ToUpper(seatingReq.Size)
+	if size == "" {
+		size = "A3"
+	}
+	if !slices.Contains(tablePlanSizes, size) {
+		http.Error(w, fmt.Sprintf("Bad Request: size must be one of %v", tablePlanSizes), http.StatusBadRequest)
+		return
+	}
+
+	plan, err := c.guestStore.GetSeatingPlan()
+	if err != nil {
+		c.writeSeatingError(w, req, "get seating plan", err)
+		return
+	}
+
+	title := fmt.Sprintf("%s & %s", c.viewData.PartnerOne, c.viewData.PartnerTwo)
+
+	var buf bytes.Buffer
+	if err := printables.TablePlan(&buf, plan, title, size); err != nil {
+		c.logger.ErrorContext(req.Context(), "error generating table plan", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", "attachment;filename=table_plan.pdf")
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Write(buf.Bytes())
+}
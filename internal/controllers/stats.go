@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/charts"
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+func (c Controller) GetStats(w http.ResponseWriter, req *http.Request) {
+	c.logger.InfoContext(req.Context(), "/get-stats request")
+
+	if req.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats, err := c.guestStore.GetStats()
+	if err != nil {
+		c.logger.ErrorContext(req.Context(), "error getting stats", "error", err)
+		http.Error(w, "Error getting stats", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		c.logger.ErrorContext(req.Context(), "error writing stats", "error", err)
+	}
+}
+
+func (c Controller) Stats(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats, err := c.guestStore.GetStats()
+	if err != nil {
+		c.logger.ErrorContext(req.Context(), "error getting stats", "error", err)
+		http.Error(w, "Error getting stats", http.StatusInternalServerError)
+		return
+	}
+
+	data := models.StatsData{
+		PartnerOne:      c.viewData.PartnerOne,
+		PartnerTwo:      c.viewData.PartnerTwo,
+		Stats:           stats,
+		ResponsePercent: int(math.Round(stats.ResponseRate * 100)),
+		StatusChart: charts.BarChart([]models.Count{
+			{Label: "Attending", Count: stats.Attending},
+			{Label: "Declined", Count: stats.Declined},
+			{Label: "Invalid details", Count: stats.InvalidDetails},
+			{Label: "Opened, not completed", Count: stats.OpenedNotCompleted},
+			{Label: "Not opened", Count: stats.NotOpened},
+		}),
+		MealChart:      charts.BarChart(stats.MealChoices),
+		ResponsesChart: charts.LineChart(stats.Responses, stats.Invited),
+	}
+
+	// Only the last fortnight fits on a phone screen
+	days := stats.DailyVisitors[max(0, len(stats.DailyVisitors)-14):]
+	visitors := make([]models.Count, 0, len(days))
+	for _, day := range days {
+		visitors = append(visitors, models.Count{Label: day.Date, Count: day.Landing})
+	}
+	data.VisitorsChart = charts.BarChart(visitors)
+
+	c.tpl.ExecuteTemplate(w, "stats.gohtml", data)
+}
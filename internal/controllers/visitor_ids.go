@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// visitorIDs gives anonymous visitors an id so they can be counted without
+// a cookie. The id is a hash of their IP address and user agent with a salt
+// that is replaced every day and never stored, so the same person gets a new
+// id each day and ids can't be traced back to an IP address.
+type visitorIDs struct {
+	// trustedProxies are the load balancers whose X-Forwarded-For is believed
+	trustedProxies []netip.Prefix
+
+	mu   sync.Mutex
+	day  string
+	salt []byte
+}
+
+func (v *visitorIDs) id(req *http.Request) string {
+	v.mu.Lock()
+	today := time.Now().UTC().Format(time.DateOnly)
+	if v.day != today || v.salt == nil {
+		v.salt = make([]byte, 32)
+		rand.Read(v.salt)
+		v.day = today
+	}
+	salt := v.salt
+	v.mu.Unlock()
+
+	hash := sha256.New()
+	hash.Write(salt)
+	hash.Write([]byte(clientIP(req, v.trustedProxies)))
+	hash.Write([]byte{0})
+	hash.Write([]byte(req.UserAgent()))
+
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// clientIP prefers the address the load balancer saw over its own. Anyone can
+// send X-Forwarded-For, so it is only read when the request came from a
+// trusted proxy, and then from the right as each proxy appends the address it
+// saw. The first hop that isn't a trusted proxy is the client.
+func clientIP(req *http.Request, trustedProxies []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	if !isTrustedProxy(host, trustedProxies) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(req.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		host = hop
+		if !isTrustedProxy(hop, trustedProxies) {
+			break
+		}
+	}
+	return host
+}
+
+func isTrustedProxy(ip string, trustedProxies []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+// recordVisit appends a page view to the visit log. guestID is 0 for
+// visitors who haven't entered their code yet, who are given an anonymous
+// visitor id instead.
+func (c Controller) recordVisit(req *http.Request, guestID int, page string) {
+	visit := models.Visit{
+		GuestID:   guestID,
+		Page:      page,
+		Referrer:  referrerHost(req),
+		UserAgent: coarseUserAgent(req.UserAgent()),
+	}
+	if guestID == 0 {
+		visit.VisitorID = c.visitorIDs.id(req)
+	}
+
+	if err := c.guestStore.RecordVisit(visit); err != nil {
+		c.logger.ErrorContext(req.Context(), "could not record visit", "page", page, "error", err)
+	}
+}
+
+// recordLanding records a visit to the landing page, crediting it to the
+// guest when they followed the link from their invitation.
+func (c Controller) recordLanding(req *http.Request) {
+	var guestID int
+	if code := req.URL.Query().Get("code"); code != "" {
+		guest, err := c.guestStore.GetGuest(code)
+		if err != nil {
+			c.logger.ErrorContext(req.Context(), "could not get guest", "error", err)
+		} else if guest != nil {
+			guestID = guest.ID
+		}
+	}
+
+	c.recordVisit(req, guestID, "index")
+}
+
+// referrerHost keeps only the site a visitor came from, and nothing for
+// links within this site.
+func referrerHost(req *http.Request) string {
+	referrer, err := url.Parse(req.Referer())
+	if err != nil || referrer.Host == req.Host {
+		return ""
+	}
+	return referrer.Hostname()
+}
+
+// coarseUserAgent reduces a user agent to the kind of device and browser,
+// which is all that's needed to spot a page misbehaving on one of them.
+func coarseUserAgent(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	if ua == "" {
+		return ""
+	}
+
+	device := "desktop"
+	switch {
+	case strings.Contains(ua, "bot"), strings.Contains(ua, "crawler"), strings.Contains(ua, "spider"), strings.Contains(ua, "preview"):
+		return "bot"
+	case strings.Contains(ua, "ipad"), strings.Contains(ua, "tablet"):
+		device = "tablet"
+	case strings.Contains(ua, "mobi"), strings.Contains(ua, "iphone"), strings.Contains(ua, "android"):
+		device = "mobile"
+	}
+
+	browser := "other"
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "edge"
+	case strings.Contains(ua, "firefox/"), strings.Contains(ua, "fxios/"):
+		browser = "firefox"
+	case strings.Contains(ua, "samsungbrowser/"):
+		browser = "samsung"
+	case strings.Contains(ua, "chrome/"), strings.Contains(ua, "crios/"):
+		browser = "chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "safari"
+	}
+
+	return device + " " + browser
+}
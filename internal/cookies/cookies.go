@@ -14,6 +14,8 @@ import (
 
 const (
 	SessionTokenName = "session-token"
+	AdminTokenName   = "admin-token"
+	LanguageName     = "language"
 	yearInSeconds    = 365 * 24 * 60 * 60
 )
 
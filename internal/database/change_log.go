@@ -0,0 +1,341 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+const changeLogTriggerPrefix = "change_log_"
+
+// Every write to every table is copied into change_log by triggers, so the
+// changes made since the last full backup can be shipped as increments and
+// replayed on top of it to restore the database to any moment. The triggers
+// are recreated on every start so they pick up new tables and columns.
+func (i GuestStore) createChangeLogTable() error {
+	createTableQuery := `CREATE TABLE IF NOT EXISTS change_log (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+		table_name TEXT NOT NULL,
+		operation TEXT NOT NULL,
+		row TEXT NOT NULL,
+		created_at TEXT NOT NULL
+    );`
+
+	_, err := i.db.Exec(createTableQuery)
+	if err != nil {
+		return err
+	}
+
+	tables, err := loggedTables(i.db)
+	if err != nil {
+		return err
+	}
+
+	tx, err := i.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := dropChangeLogTriggers(tx); err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if err := createChangeLogTriggers(tx, table); err != nil {
+			return fmt.Errorf("failed to create change log triggers for %s: %v", table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	slog.Info("change_log table set up successfully!")
+	return nil
+}
+
+// loggedTables returns every table whose changes are logged.
+func loggedTables(q queryer) ([]string, error) {
+	rows, err := q.Query(`SELECT name FROM sqlite_master
+	WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name != 'change_log'
+	ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+
+	return tables, rows.Err()
+}
+
+func dropChangeLogTriggers(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT name FROM sqlite_master WHERE type = 'trigger' AND name LIKE ? || '%'`, changeLogTriggerPrefix)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var triggers []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		triggers = append(triggers, name)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, trigger := range triggers {
+		if _, err := tx.Exec(fmt.Sprintf(`DROP TRIGGER "%s"`, trigger)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func createChangeLogTriggers(tx *sql.Tx, table string) error {
+	columns, err := tableColumns(tx, table)
+	if err != nil {
+		return err
+	}
+
+	var all, pk []column
+	for _, c := range columns {
+		all = append(all, c)
+		if c.pk > 0 {
+			pk = append(pk, c)
+		}
+	}
+	if len(pk) == 0 {
+		return fmt.Errorf("table has no primary key")
+	}
+
+	var pkChanged []string
+	for _, c := range pk {
+		pkChanged = append(pkChanged, fmt.Sprintf(`OLD."%s" IS NOT NEW."%s"`, c.name, c.name))
+	}
+
+	logChange := func(operation, rowRef string, cols []column) string {
+		return fmt.Sprintf(`INSERT INTO change_log (table_name, operation, row, created_at)
+		VALUES ('%s', '%s', %s, strftime('%%Y-%%m-%%d %%H:%%M:%%f', 'now'));`, table, operation, jsonObject(rowRef, cols))
+	}
+
+	triggers := map[string]string{
+		"insert": fmt.Sprintf(`AFTER INSERT ON "%s" BEGIN
+		%s
+	END`, table, logChange(models.ChangeUpsert, "NEW", all)),
+		// an update which changes the primary key leaves nothing at the old key
+		"update": fmt.Sprintf(`AFTER UPDATE ON "%s" BEGIN
+		INSERT INTO change_log (table_name, operation, row, created_at)
+		SELECT '%s', '%s', %s, strftime('%%Y-%%m-%%d %%H:%%M:%%f', 'now') WHERE %s;
+		%s
+	END`, table, table, models.ChangeDelete, jsonObject("OLD", pk), strings.Join(pkChanged, " OR "), logChange(models.ChangeUpsert, "NEW", all)),
+		"delete": fmt.Sprintf(`AFTER DELETE ON "%s" BEGIN
+		%s
+	END`, table, logChange(models.ChangeDelete, "OLD", pk)),
+	}
+
+	for event, body := range triggers {
+		_, err := tx.Exec(fmt.Sprintf(`CREATE TRIGGER "%s%s_%s" %s`, changeLogTriggerPrefix, table, event, body))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func jsonObject(rowRef string, cols []column) string {
+	var args []string
+	for _, c := range cols {
+		args = append(args, fmt.Sprintf(`'%s', %s."%s"`, c.name, rowRef, c.name))
+	}
+	return "json_object(" + strings.Join(args, ", ") + ")"
+}
+
+// GetChanges returns up to limit changes logged after the change with the id
+// afterID, oldest first.
+func (i GuestStore) GetChanges(afterID int64, limit int) ([]models.Change, error) {
+	rows, err := i.db.Query(`SELECT id, table_name, operation, row, created_at
+	FROM change_log
+	WHERE id > ?
+	ORDER BY id
+	LIMIT ?`, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []models.Change
+	for rows.Next() {
+		var change models.Change
+		var row string
+		if err := rows.Scan(&change.ID, &change.Table, &change.Operation, &row, &change.CreatedAt); err != nil {
+			return nil, err
+		}
+		change.Row = json.RawMessage(row)
+		changes = append(changes, change)
+	}
+
+	return changes, rows.Err()
+}
+
+// GetChangeLogPosition returns the id and time of the last change logged,
+// which for a backup is the point it was taken at. ok is false if the
+// database has no change log.
+func (i GuestStore) GetChangeLogPosition() (id int64, createdAt string, ok bool, err error) {
+	var count int
+	err = i.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'change_log'`).Scan(&count)
+	if err != nil || count == 0 {
+		return 0, "", false, err
+	}
+
+	// sqlite_sequence keeps the last id even if rows were deleted
+	var seq sql.NullInt64
+	err = i.db.QueryRow(`SELECT seq FROM sqlite_sequence WHERE name = 'change_log'`).Scan(&seq)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, "", false, err
+	}
+
+	var latest sql.NullString
+	err = i.db.QueryRow(`SELECT MAX(created_at) FROM change_log`).Scan(&latest)
+	if err != nil {
+		return 0, "", false, err
+	}
+
+	return seq.Int64, latest.String, true, nil
+}
+
+// ApplyChanges replays changes, which must follow on from the last change
+// already logged, and copies them into the change log. The triggers are
+// dropped first as they would log each change a second time; they are
+// recreated when the server next starts. Columns the database doesn't have
+// yet are ignored.
+func (i GuestStore) ApplyChanges(changes []models.Change) error {
+	tx, err := i.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := dropChangeLogTriggers(tx); err != nil {
+		return err
+	}
+
+	tableCols := make(map[string]map[string]bool)
+	for _, change := range changes {
+		cols, ok := tableCols[change.Table]
+		if !ok {
+			columns, err := tableColumns(tx, change.Table)
+			if err != nil {
+				return err
+			}
+			if len(columns) == 0 {
+				return fmt.Errorf("change %d is to table %s which doesn't exist", change.ID, change.Table)
+			}
+			cols = make(map[string]bool)
+			for _, c := range columns {
+				cols[c.name] = true
+			}
+			tableCols[change.Table] = cols
+		}
+
+		if err := applyChange(tx, change, cols); err != nil {
+			return fmt.Errorf("failed to apply change %d: %v", change.ID, err)
+		}
+
+		_, err = tx.Exec(`INSERT INTO change_log (id, table_name, operation, row, created_at) VALUES (?, ?, ?, ?, ?)`,
+			change.ID, change.Table, change.Operation, string(change.Row), change.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to log change %d: %v", change.ID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func applyChange(tx *sql.Tx, change models.Change, cols map[string]bool) error {
+	var row map[string]json.RawMessage
+	if err := json.Unmarshal(change.Row, &row); err != nil {
+		return err
+	}
+
+	var names []string
+	for name := range row {
+		if cols[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return fmt.Errorf("change has no columns %s has", change.Table)
+	}
+
+	// json_extract turns each value back into the type SQLite stored
+	var quoted, values, where []string
+	var args []any
+	for _, name := range names {
+		quoted = append(quoted, fmt.Sprintf(`"%s"`, name))
+		values = append(values, "json_extract(?, ?)")
+		where = append(where, fmt.Sprintf(`"%s" IS json_extract(?, ?)`, name))
+		args = append(args, string(change.Row), fmt.Sprintf(`$."%s"`, name))
+	}
+
+	var query string
+	switch change.Operation {
+	case models.ChangeUpsert:
+		query = fmt.Sprintf(`INSERT OR REPLACE INTO "%s" (%s) VALUES (%s)`, change.Table, strings.Join(quoted, ", "), strings.Join(values, ", "))
+	case models.ChangeDelete:
+		query = fmt.Sprintf(`DELETE FROM "%s" WHERE %s`, change.Table, strings.Join(where, " AND "))
+	default:
+		return fmt.Errorf("unknown operation %q", change.Operation)
+	}
+
+	_, err := tx.Exec(query, args...)
+	return err
+}
+
+// LogRestore records that the database has been restored, numbering the entry
+// after lastShippedID so changes made from now on never reuse the id of a
+// change already shipped from before the restore. Backups from before the
+// change log existed have one added.
+func (i GuestStore) LogRestore(lastShippedID int64) error {
+	if err := i.createChangeLogTable(); err != nil {
+		return err
+	}
+
+	position, _, _, err := i.GetChangeLogPosition()
+	if err != nil {
+		return err
+	}
+
+	row, err := json.Marshal(map[string]int64{"position": position})
+	if err != nil {
+		return err
+	}
+
+	_, err = i.db.Exec(`INSERT INTO change_log (id, table_name, operation, row, created_at)
+	VALUES (?, '', ?, ?, strftime('%Y-%m-%d %H:%M:%f', 'now'))`, max(position, lastShippedID)+1, models.ChangeRestore, string(row))
+	if err != nil {
+		return fmt.Errorf("failed to log restore: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+// CheckInGuest records the time the guest arrived on the day. Guests who
+// have already been checked in keep their original arrival time.
+func (i GuestStore) CheckInGuest(code, actor string) error {
+	tx, err := i.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	guest, err := getGuestForUpdate(tx, code)
+	if err != nil {
+		return err
+	}
+	if guest.Deleted {
+		return fmt.Errorf("guest %s is deleted: %w", code, ErrGuestNotFound)
+	}
+	if guest.ArrivedAt != "" {
+		return nil
+	}
+
+	_, err = tx.Exec(`UPDATE guests SET arrived_at = datetime('now') WHERE id = ?`, guest.ID)
+	if err != nil {
+		return fmt.Errorf("failed to check in guest %s: %v", code, err)
+	}
+
+	err = recordGuestEvent(tx, models.GuestEvent{
+		GuestID: guest.ID,
+		Type:    models.GuestEventCheckedIn,
+		Field:   "arrived_at",
+		Actor:   actor,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetCheckInCounts returns how many guests have arrived against how many
+// accepted their invitation.
+func (i GuestStore) GetCheckInCounts() (models.CheckInCounts, error) {
+	var counts models.CheckInCounts
+
+	query := `SELECT
+		COUNT(arrived_at),
+		COUNT(CASE WHEN form_started AND attendance THEN 1 END)
+	FROM guests
+	WHERE deleted_at IS NULL`
+
+	err := i.db.QueryRow(query).Scan(&counts.Arrived, &counts.Expected)
+	if err != nil {
+		return counts, fmt.Errorf("failed to count check ins: %v", err)
+	}
+
+	return counts, nil
+}
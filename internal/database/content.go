@@ -0,0 +1,62 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+// The content is kept as a single JSON document, as it is always read and
+// saved whole.
+func (i GuestStore) createContentTable() error {
+	createTableQuery := `CREATE TABLE IF NOT EXISTS content (
+        id INTEGER PRIMARY KEY CHECK (id = 1),
+		data TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+    );`
+
+	_, err := i.db.Exec(createTableQuery)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("content table set up successfully!")
+	return nil
+}
+
+// GetContent returns the saved content, or nil if it has never been saved.
+func (i GuestStore) GetContent() (*models.Content, error) {
+	var data string
+	err := i.db.QueryRow(`SELECT data FROM content WHERE id = 1`).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get content: %v", err)
+	}
+
+	var content models.Content
+	if err := json.Unmarshal([]byte(data), &content); err != nil {
+		return nil, fmt.Errorf("failed to read content: %v", err)
+	}
+	return &content, nil
+}
+
+func (i GuestStore) SaveContent(content models.Content) error {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+
+	_, err = i.db.Exec(`INSERT INTO content (id, data, updated_at)
+	VALUES (1, ?, datetime('now'))
+	ON CONFLICT(id) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at`, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save content: %v", err)
+	}
+
+	return nil
+}
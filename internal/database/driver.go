@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/metrics"
+)
+
+// DriverName is the sqlite3 driver wrapped to time every statement, including
+// those run inside transactions, for the query latency metrics.
+const DriverName = "sqlite3_metrics"
+
+func init() {
+	sql.Register(DriverName, timedDriver{&sqlite3.SQLiteDriver{}})
+}
+
+type timedDriver struct {
+	driver.Driver
+}
+
+func (d timedDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return timedConn{conn}, nil
+}
+
+type timedConn struct {
+	driver.Conn
+}
+
+func (c timedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c timedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	metrics.ObserveQuery(query, start)
+	return result, err
+}
+
+func (c timedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		metrics.ObserveQuery(query, start)
+		return nil, err
+	}
+	return &timedRows{Rows: rows, query: query, start: start}, nil
+}
+
+// timedRows stops the clock once the rows are closed, as sqlite does most of
+// the work of a query while the rows are read.
+type timedRows struct {
+	driver.Rows
+	query string
+	start time.Time
+}
+
+func (r *timedRows) Close() error {
+	err := r.Rows.Close()
+	metrics.ObserveQuery(r.query, r.start)
+	return err
+}
@@ -0,0 +1,309 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/guestlist"
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+var (
+	ErrGuestNotFound   = errors.New("guest not found")
+	ErrGuestMerged     = errors.New("guest was merged into another guest")
+	ErrGuestNotDeleted = errors.New("guest is not deleted")
+)
+
+func getGuestForUpdate(tx *sql.Tx, code string) (*models.Guest, error) {
+	guest, err := scanGuest(tx.QueryRow(selectGuestColumns+` WHERE code = ?`, code))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no guest found with code %s: %w", code, ErrGuestNotFound)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return guest, nil
+}
+
+func (i GuestStore) RenameGuest(code, name, actor string) error {
+	tx, err := i.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	guest, err := getGuestForUpdate(tx, code)
+	if err != nil {
+		return err
+	}
+	if guest.Deleted {
+		return fmt.Errorf("guest %s is deleted: %w", code, ErrGuestNotFound)
+	}
+
+	_, err = tx.Exec(`UPDATE guests SET name = ? WHERE id = ?`, name, guest.ID)
+	if err != nil {
+		return fmt.Errorf("failed to rename guest %v: %v", code, err)
+	}
+
+	err = recordGuestEvent(tx, models.GuestEvent{
+		GuestID:  guest.ID,
+		Type:     models.GuestEventRenamed,
+		Field:    "name",
+		OldValue: guest.Name,
+		NewValue: name,
+		Actor:    actor,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteGuest soft deletes a guest. They can no longer use their code and are
+// left out of exports, but can be brought back with RestoreGuest.
+func (i GuestStore) DeleteGuest(code, actor string) error {
+	tx, err := i.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	guest, err := getGuestForUpdate(tx, code)
+	if err != nil {
+		return err
+	}
+	if guest.Deleted {
+		return fmt.Errorf("guest %s is already deleted: %w", code, ErrGuestNotFound)
+	}
+
+	_, err = tx.Exec(`UPDATE guests SET deleted_at = datetime('now') WHERE id = ?`, guest.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete guest %v: %v", code, err)
+	}
+
+	if err := unassignSeat(tx, guest.ID, actor); err != nil {
+		return err
+	}
+
+	err = recordGuestEvent(tx, models.GuestEvent{
+		GuestID: guest.ID,
+		Type:    models.GuestEventDeleted,
+		Actor:   actor,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (i GuestStore) RestoreGuest(code, actor string) error {
+	tx, err := i.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	guest, err := getGuestForUpdate(tx, code)
+	if err != nil {
+		return err
+	}
+	if !guest.Deleted {
+		return fmt.Errorf("guest %s: %w", code, ErrGuestNotDeleted)
+	}
+
+	var mergedInto sql.NullInt64
+	err = tx.QueryRow(`SELECT merged_into FROM guests WHERE id = ?`, guest.ID).Scan(&mergedInto)
+	if err != nil {
+		return err
+	}
+	if mergedInto.Valid {
+		return fmt.Errorf("guest %s: %w", code, ErrGuestMerged)
+	}
+
+	_, err = tx.Exec(`UPDATE guests SET deleted_at = NULL WHERE id = ?`, guest.ID)
+	if err != nil {
+		return fmt.Errorf("failed to restore guest %v: %v", code, err)
+	}
+
+	err = recordGuestEvent(tx, models.GuestEvent{
+		GuestID: guest.ID,
+		Type:    models.GuestEventRestored,
+		Actor:   actor,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MergeGuests folds an accidental duplicate into the guest that is being kept.
+// Details missing from the kept guest are taken from the duplicate, as is the
+// duplicate's RSVP if the kept guest never responded. The duplicate is soft
+// deleted so its code stops working and its seat is given up. Its page visits
+// and history are left as they were recorded and are read as the kept
+// guest's through merged_into.
+func (i GuestStore) MergeGuests(keepCode, duplicateCode, actor string) error {
+	if keepCode == duplicateCode {
+		return fmt.Errorf("cannot merge guest %s into itself", keepCode)
+	}
+
+	tx, err := i.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	keep, err := getGuestForUpdate(tx, keepCode)
+	if err != nil {
+		return err
+	}
+	duplicate, err := getGuestForUpdate(tx, duplicateCode)
+	if err != nil {
+		return err
+	}
+	if keep.Deleted || duplicate.Deleted {
+		return fmt.Errorf("cannot merge deleted guests: %w", ErrGuestNotFound)
+	}
+
+	merged, changes := mergeGuest(*keep, *duplicate)
+
+	updateQuery := `UPDATE guests
+              SET
+				email = ?,
+				phone_number = ?,
+				meal_choice = ?,
+				dietary_requirements = ?,
+				attendance = ?,
+				invalid_details = ?,
+				details_provided = ?,
+				form_started = ?,
+				form_completed = ?,
+				household = ?,
+				plus_ones = ?,
+				events = ?,
+				tags = ?,
+				address_line_1 = ?,
+				address_line_2 = ?,
+				address_city = ?,
+				address_county = ?,
+				address_postcode = ?,
+				address_country = ?
+              WHERE id = ?`
+
+	_, err = tx.Exec(updateQuery,
+		nullIfEmpty(merged.Email), nullIfEmpty(merged.PhoneNumber), nullIfEmpty(merged.MealChoice),
+		nullIfEmpty(merged.DietaryRequirements), merged.Attendance, merged.InvalidDetails, merged.DetailsProvided,
+		merged.FormStarted, merged.FormCompleted, merged.Household, merged.PlusOnes, guestlist.JoinList(merged.Events),
+		guestlist.JoinList(merged.Tags), merged.Address.Line1, merged.Address.Line2, merged.Address.City,
+		merged.Address.County, merged.Address.Postcode, merged.Address.Country, keep.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update guest %v: %v", keepCode, err)
+	}
+
+	// guests merged into the duplicate earlier now belong to the kept guest
+	_, err = tx.Exec(`UPDATE guests SET merged_into = ? WHERE merged_into = ?`, keep.ID, duplicate.ID)
+	if err != nil {
+		return fmt.Errorf("failed to move guests merged into %v: %v", duplicateCode, err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM session_data WHERE code = ?`, duplicate.Code)
+	if err != nil {
+		return fmt.Errorf("failed to remove merged session data: %v", err)
+	}
+
+	_, err = tx.Exec(`UPDATE guests SET deleted_at = datetime('now'), merged_into = ? WHERE id = ?`, keep.ID, duplicate.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete merged guest %v: %v", duplicateCode, err)
+	}
+
+	if err := unassignSeat(tx, duplicate.ID, actor); err != nil {
+		return err
+	}
+
+	events := []models.GuestEvent{
+		{GuestID: keep.ID, Type: models.GuestEventMerged, Field: "code", NewValue: duplicate.Code, Actor: actor},
+		{GuestID: duplicate.ID, Type: models.GuestEventMergedInto, Field: "code", NewValue: keep.Code, Actor: actor},
+	}
+	for _, change := range changes {
+		change.GuestID = keep.ID
+		change.Type = models.GuestEventMerged
+		change.Actor = actor
+		events = append(events, change)
+	}
+	for _, event := range events {
+		if err := recordGuestEvent(tx, event); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// mergeGuest fills in keep from duplicate and returns the fields that changed.
+func mergeGuest(keep, duplicate models.Guest) (models.Guest, []models.GuestEvent) {
+	var changes []models.GuestEvent
+	change := func(field, oldValue, newValue string) {
+		changes = append(changes, models.GuestEvent{Field: field, OldValue: oldValue, NewValue: newValue})
+	}
+	fill := func(field string, current *string, value string) {
+		if *current == "" && value != "" {
+			change(field, "", value)
+			*current = value
+		}
+	}
+
+	if !keep.FormStarted && duplicate.FormStarted {
+		change("attendance", "", strconv.FormatBool(duplicate.Attendance))
+		keep.Attendance = duplicate.Attendance
+		keep.InvalidDetails = duplicate.InvalidDetails
+		keep.DetailsProvided = duplicate.DetailsProvided
+		keep.FormStarted = duplicate.FormStarted
+		keep.FormCompleted = duplicate.FormCompleted
+	}
+
+	fill("email", &keep.Email, duplicate.Email)
+	fill("phone_number", &keep.PhoneNumber, duplicate.PhoneNumber)
+	fill("meal_choice", &keep.MealChoice, duplicate.MealChoice)
+	fill("dietary_requirements", &keep.DietaryRequirements, duplicate.DietaryRequirements)
+	fill("household", &keep.Household, duplicate.Household)
+
+	if keep.Address.IsEmpty() && !duplicate.Address.IsEmpty() {
+		change("address", "", strings.Join(duplicate.Address.Lines(), ", "))
+		keep.Address = duplicate.Address
+	}
+
+	if duplicate.PlusOnes > keep.PlusOnes {
+		change("plus_ones", strconv.Itoa(keep.PlusOnes), strconv.Itoa(duplicate.PlusOnes))
+		keep.PlusOnes = duplicate.PlusOnes
+	}
+
+	union := func(field string, current *[]string, values []string) {
+		seen := make(map[string]bool)
+		for _, v := range *current {
+			seen[v] = true
+		}
+		oldValue := guestlist.JoinList(*current)
+		added := false
+		for _, v := range values {
+			if !seen[v] {
+				*current = append(*current, v)
+				seen[v] = true
+				added = true
+			}
+		}
+		if added {
+			change(field, oldValue, guestlist.JoinList(*current))
+		}
+	}
+	union("events", &keep.Events, duplicate.Events)
+	union("tags", &keep.Tags, duplicate.Tags)
+
+	return keep, changes
+}
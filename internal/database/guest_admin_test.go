@@ -0,0 +1,276 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+// newTestStore returns a store backed by an in-memory database holding a
+// guest for each of names.
+func newTestStore(t *testing.T, names ...string) GuestStore {
+	t.Helper()
+
+	db, err := sql.Open(DriverName, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to :memory: is a separate database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	var rows [][]string
+	for _, name := range names {
+		rows = append(rows, []string{name})
+	}
+	store := NewGuestStore(db)
+	if err := store.SetupDatabase(rows); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func guestCode(t *testing.T, store GuestStore, name string) string {
+	t.Helper()
+	code, err := store.GetGuestCode(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func mustGetGuest(t *testing.T, store GuestStore, code string) *models.Guest {
+	t.Helper()
+	guest, err := store.GetGuest(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if guest == nil {
+		t.Fatalf("guest %s not found", code)
+	}
+	return guest
+}
+
+// lastEvent returns the newest event in the guest's history.
+func lastEvent(t *testing.T, store GuestStore, guestID int) models.GuestEvent {
+	t.Helper()
+	events, err := store.GetGuestEvents(guestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) == 0 {
+		t.Fatalf("guest %d has no events", guestID)
+	}
+	latest := events[0]
+	for _, event := range events {
+		if event.ID > latest.ID {
+			latest = event
+		}
+	}
+	return latest
+}
+
+func TestRenameGuest(t *testing.T) {
+	store := newTestStore(t, "Alice Smith")
+	code := guestCode(t, store, "Alice Smith")
+
+	if err := store.RenameGuest(code, "Alice Jones", models.ActorAPI); err != nil {
+		t.Fatal(err)
+	}
+
+	guest := mustGetGuest(t, store, code)
+	if guest.Name != "Alice Jones" {
+		t.Errorf("name = %q, want Alice Jones", guest.Name)
+	}
+
+	event := lastEvent(t, store, guest.ID)
+	want := models.GuestEvent{Type: models.GuestEventRenamed, Field: "name", OldValue: "Alice Smith", NewValue: "Alice Jones", Actor: models.ActorAPI}
+	if event.Type != want.Type || event.Field != want.Field || event.OldValue != want.OldValue || event.NewValue != want.NewValue || event.Actor != want.Actor {
+		t.Errorf("event = %+v, want %+v", event, want)
+	}
+
+	if err := store.RenameGuest("Nobody-xxxxxx", "x", models.ActorAPI); !errors.Is(err, ErrGuestNotFound) {
+		t.Errorf("renaming a missing guest returned %v, want ErrGuestNotFound", err)
+	}
+}
+
+func TestDeleteAndRestoreGuest(t *testing.T) {
+	store := newTestStore(t, "Alice Smith")
+	code := guestCode(t, store, "Alice Smith")
+	id := mustGetGuest(t, store, code).ID
+
+	if err := store.RestoreGuest(code, models.ActorAPI); !errors.Is(err, ErrGuestNotDeleted) {
+		t.Errorf("restoring a guest who isn't deleted returned %v, want ErrGuestNotDeleted", err)
+	}
+
+	if err := store.DeleteGuest(code, models.ActorAPI); err != nil {
+		t.Fatal(err)
+	}
+	if guest, err := store.GetGuest(code); err != nil || guest != nil {
+		t.Fatalf("deleted guest's code still works: %v, %v", guest, err)
+	}
+	if event := lastEvent(t, store, id); event.Type != models.GuestEventDeleted || event.Actor != models.ActorAPI {
+		t.Errorf("event = %+v, want a deleted event by %s", event, models.ActorAPI)
+	}
+	if err := store.DeleteGuest(code, models.ActorAPI); !errors.Is(err, ErrGuestNotFound) {
+		t.Errorf("deleting twice returned %v, want ErrGuestNotFound", err)
+	}
+
+	if err := store.RestoreGuest(code, models.ActorAPI); err != nil {
+		t.Fatal(err)
+	}
+	if guest := mustGetGuest(t, store, code); guest.Deleted {
+		t.Error("restored guest is still deleted")
+	}
+	if event := lastEvent(t, store, id); event.Type != models.GuestEventRestored || event.Actor != models.ActorAPI {
+		t.Errorf("event = %+v, want a restored event by %s", event, models.ActorAPI)
+	}
+}
+
+func TestMergeGuests(t *testing.T) {
+	store := newTestStore(t, "Alice Smith", "Alice Smyth")
+	keepCode := guestCode(t, store, "Alice Smith")
+	duplicateCode := guestCode(t, store, "Alice Smyth")
+	keep := mustGetGuest(t, store, keepCode)
+	duplicate := mustGetGuest(t, store, duplicateCode)
+
+	// only the duplicate responded, and both visited the site
+	if err := store.UpdateGuestAttendance(duplicateCode, true, false, models.ActorGuest); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpdateGuestEmail(duplicateCode, "alice@example.com", models.ActorGuest); err != nil {
+		t.Fatal(err)
+	}
+	for _, visit := range []models.Visit{{GuestID: keep.ID, Page: "index"}, {GuestID: duplicate.ID, Page: "rsvp"}, {GuestID: duplicate.ID, Page: "guest-details"}} {
+		if err := store.RecordVisit(visit); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.CreateTable(models.Table{Name: "Oak", Capacity: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AssignSeat(duplicateCode, "Oak", 0, models.ActorAdmin); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.MergeGuests(keepCode, duplicateCode, models.ActorAPI); err != nil {
+		t.Fatal(err)
+	}
+
+	merged := mustGetGuest(t, store, keepCode)
+	if !merged.Attendance || !merged.FormStarted {
+		t.Errorf("merged guest didn't take the duplicate's RSVP: %+v", merged)
+	}
+	if merged.Email != "alice@example.com" {
+		t.Errorf("email = %q, want the duplicate's", merged.Email)
+	}
+	if guest, err := store.GetGuest(duplicateCode); err != nil || guest != nil {
+		t.Errorf("duplicate's code still works: %v, %v", guest, err)
+	}
+
+	visits, err := store.GetVisitEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(visits) != 3 {
+		t.Fatalf("got %d visits, want 3", len(visits))
+	}
+	for _, visit := range visits {
+		if visit.GuestID != keep.ID {
+			t.Errorf("visit to %s belongs to guest %d, want %d", visit.Page, visit.GuestID, keep.ID)
+		}
+	}
+
+	// the visit log itself is append only
+	var stored int
+	err = store.db.QueryRow(`SELECT COUNT(*) FROM visit_events WHERE guest_id = ?`, duplicate.ID).Scan(&stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != 2 {
+		t.Errorf("%d of the duplicate's 2 visits are still recorded against it", stored)
+	}
+
+	if assignment, err := store.GetSeatAssignment(duplicate.ID); err != nil || assignment != nil {
+		t.Errorf("duplicate is still seated: %+v, %v", assignment, err)
+	}
+
+	events, err := store.GetGuestEvents(keep.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]bool{}
+	var duplicateAttendance bool
+	for _, event := range events {
+		if event.GuestID != keep.ID {
+			t.Errorf("event %+v isn't given the kept guest's id", event)
+		}
+		if event.Type == models.GuestEventMerged && event.Actor == models.ActorAPI {
+			found[event.Field] = true
+		}
+		if event.Type == models.GuestEventUpdated && event.Field == "attendance" && event.Actor == models.ActorGuest {
+			duplicateAttendance = true
+		}
+	}
+	for _, field := range []string{"code", "attendance", "email"} {
+		if !found[field] {
+			t.Errorf("no merged event for %s in %+v", field, events)
+		}
+	}
+	if !duplicateAttendance {
+		t.Errorf("the duplicate's RSVP isn't in the kept guest's history: %+v", events)
+	}
+
+	attendance, err := store.GetFieldEvents("attendance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range attendance {
+		if event.GuestID != keep.ID {
+			t.Errorf("attendance event %+v isn't given the kept guest's id", event)
+		}
+	}
+
+	var visitsData int
+	err = store.db.QueryRow(`SELECT COALESCE(SUM(visit_count), 0) FROM page_visits WHERE id = ?`, keep.ID).Scan(&visitsData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if visitsData != 3 {
+		t.Errorf("page_visits counts %d visits for the kept guest, want 3", visitsData)
+	}
+	if event := lastEvent(t, store, duplicate.ID); event.Type != models.GuestEventMergedInto || event.NewValue != keepCode {
+		t.Errorf("duplicate's event = %+v, want merged_into %s", event, keepCode)
+	}
+
+	if err := store.RestoreGuest(duplicateCode, models.ActorAPI); !errors.Is(err, ErrGuestMerged) {
+		t.Errorf("restoring a merged guest returned %v, want ErrGuestMerged", err)
+	}
+}
+
+func TestMergeGuestsIntoMergedGuest(t *testing.T) {
+	store := newTestStore(t, "Alice Smith", "Alice Smyth", "A Smith")
+	first := mustGetGuest(t, store, guestCode(t, store, "A Smith"))
+	if err := store.RecordVisit(models.Visit{GuestID: first.ID, Page: "rsvp"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A Smith is merged into Alice Smyth, who is then found to be Alice Smith
+	if err := store.MergeGuests(guestCode(t, store, "Alice Smyth"), first.Code, models.ActorAPI); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.MergeGuests(guestCode(t, store, "Alice Smith"), guestCode(t, store, "Alice Smyth"), models.ActorAPI); err != nil {
+		t.Fatal(err)
+	}
+
+	keep := mustGetGuest(t, store, guestCode(t, store, "Alice Smith"))
+	visits, err := store.GetVisitEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(visits) != 1 || visits[0].GuestID != keep.ID {
+		t.Errorf("visits = %+v, want A Smith's visit given to guest %d", visits, keep.ID)
+	}
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+// execer is satisfied by both *sql.DB and *sql.Tx so events can be recorded
+// in the same transaction as the change they describe.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func (i GuestStore) createGuestEventsTable() error {
+	createTableQuery := `CREATE TABLE IF NOT EXISTS guest_events (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        guest_id INTEGER NOT NULL,
+		event_type TEXT NOT NULL,
+		field TEXT,
+		old_value TEXT,
+		new_value TEXT,
+		actor TEXT NOT NULL,
+		created_at TEXT NOT NULL
+    );`
+
+	_, err := i.db.Exec(createTableQuery)
+	if err != nil {
+		return err
+	}
+
+	_, err = i.db.Exec(`CREATE INDEX IF NOT EXISTS guest_events_guest_id ON guest_events (guest_id)`)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("guest_events table set up successfully!")
+	return nil
+}
+
+func recordGuestEvent(e execer, event models.GuestEvent) error {
+	query := `INSERT INTO
+	    guest_events (guest_id, event_type, field, old_value, new_value, actor, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, datetime('now'))`
+
+	_, err := e.Exec(query, event.GuestID, event.Type, event.Field, event.OldValue, event.NewValue, event.Actor)
+	if err != nil {
+		return fmt.Errorf("failed to record %s event for guest %v: %v", event.Type, event.GuestID, err)
+	}
+
+	return nil
+}
+
+// GetGuestEvents returns the guest's history, including that of any duplicates
+// merged into them.
+func (i GuestStore) GetGuestEvents(guestID int) ([]models.GuestEvent, error) {
+	return i.queryGuestEvents(`WHERE guest_events.guest_id = ? OR guests.merged_into = ?`, guestID, guestID)
+}
+
+// GetFieldEvents returns every guest's changes to field, oldest first.
+func (i GuestStore) GetFieldEvents(field string) ([]models.GuestEvent, error) {
+	return i.queryGuestEvents(`WHERE guest_events.field = ?`, field)
+}
+
+// queryGuestEvents returns the events matching where. Events are never moved
+// when guests are merged, so those recorded against a duplicate are given the
+// id of the guest it was merged into.
+
+func (i GuestStore) queryGuestEvents(where string, args ...any) ([]models.GuestEvent, error) {
+	query := `SELECT
+		guest_events.id,
+		COALESCE(guests.merged_into, guest_events.guest_id),
+		guest_events.event_type,
+		guest_events.field,
+		guest_events.old_value,
+		guest_events.new_value,
+		guest_events.actor,
+		guest_events.created_at
+	FROM guest_events
+	LEFT JOIN guests ON guests.id = guest_events.guest_id
+	` + where + `
+	ORDER BY guest_events.id`
+
+	rows, err := i.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.GuestEvent{}
+	for rows.Next() {
+		var event models.GuestEvent
+		var field, oldValue, newValue sql.NullString
+		if err := rows.Scan(
+			&event.ID,
+			&event.GuestID,
+			&event.Type,
+			&field,
+			&oldValue,
+			&newValue,
+			&event.Actor,
+			&event.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		event.Field = field.String
+		event.OldValue = oldValue.String
+		event.NewValue = newValue.String
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// GetGuestTimeline returns the history of the guest with code, including
+// guests that have since been deleted or merged.
+func (i GuestStore) GetGuestTimeline(code string) ([]models.GuestEvent, error) {
+	var id int
+	err := i.db.QueryRow(`SELECT id FROM guests WHERE code = ?`, code).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no guest found with code %s: %w", code, ErrGuestNotFound)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return i.GetGuestEvents(id)
+}
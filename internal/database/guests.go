@@ -3,12 +3,14 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"math/rand"
+	"strconv"
 	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 
+	"github.com/nesquikmike/wedding-rsvps/internal/guestlist"
 	"github.com/nesquikmike/wedding-rsvps/internal/models"
 )
 
@@ -31,7 +33,7 @@ func (i GuestStore) SetupDatabase(guestNames [][]string) error {
 		return err
 	}
 
-	err = i.createPageVisitsTable()
+	err = i.createVisitEventsTable()
 	if err != nil {
 		return err
 	}
@@ -41,7 +43,28 @@ func (i GuestStore) SetupDatabase(guestNames [][]string) error {
 		return err
 	}
 
-	log.Println("Database and tables set up and populated successfully!")
+	err = i.createGuestEventsTable()
+	if err != nil {
+		return err
+	}
+
+	err = i.createSeatingTables()
+	if err != nil {
+		return err
+	}
+
+	err = i.createContentTable()
+	if err != nil {
+		return err
+	}
+
+	// must come last so every table is logged
+	err = i.createChangeLogTable()
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Database and tables set up and populated successfully!")
 	return nil
 }
 
@@ -58,7 +81,22 @@ func (i GuestStore) createGuestsTable(guestNames [][]string) error {
         invalid_details BOOLEAN,
         details_provided BOOLEAN,
         form_started BOOLEAN NOT NULL,
-        form_completed BOOLEAN
+        form_completed BOOLEAN,
+		address_line_1 TEXT,
+		address_line_2 TEXT,
+		address_city TEXT,
+		address_county TEXT,
+		address_postcode TEXT,
+		address_country TEXT,
+		external_id TEXT,
+		household TEXT,
+		plus_ones INTEGER NOT NULL DEFAULT 0,
+		events TEXT,
+		tags TEXT,
+		deleted_at TEXT,
+		merged_into INTEGER,
+		arrived_at TEXT,
+		language TEXT
     );`
 
 	_, err := i.db.Exec(createTableQuery)
@@ -66,6 +104,33 @@ func (i GuestStore) createGuestsTable(guestNames [][]string) error {
 		return err
 	}
 
+	for _, column := range addressColumns {
+		if err := i.addColumnIfNotExists("guests", column, "TEXT"); err != nil {
+			return err
+		}
+	}
+
+	for _, column := range [][2]string{
+		{"external_id", "TEXT"},
+		{"household", "TEXT"},
+		{"plus_ones", "INTEGER NOT NULL DEFAULT 0"},
+		{"events", "TEXT"},
+		{"tags", "TEXT"},
+		{"deleted_at", "TEXT"},
+		{"merged_into", "INTEGER"},
+		{"arrived_at", "TEXT"},
+		{"language", "TEXT"},
+	} {
+		if err := i.addColumnIfNotExists("guests", column[0], column[1]); err != nil {
+			return err
+		}
+	}
+
+	_, err = i.db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS guests_external_id ON guests (external_id)`)
+	if err != nil {
+		return err
+	}
+
 	tableCount, err := i.getTableCount()
 	if err != nil {
 		return err
@@ -82,7 +147,64 @@ func (i GuestStore) createGuestsTable(guestNames [][]string) error {
 		}
 	}
 
-	log.Println("guests table set up and populated successfully!")
+	err = i.importAddresses(guestNames)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("guests table set up and populated successfully!")
+	return nil
+}
+
+var addressColumns = []string{
+	"address_line_1",
+	"address_line_2",
+	"address_city",
+	"address_county",
+	"address_postcode",
+	"address_country",
+}
+
+// importAddresses fills in the postal address of guests from the optional
+// columns following the name in the csv. Addresses guests have already
+// entered themselves are never overwritten.
+func (i GuestStore) importAddresses(guestRows [][]string) error {
+	query := `UPDATE guests
+              SET
+				address_line_1 = ?,
+				address_line_2 = ?,
+				address_city = ?,
+				address_county = ?,
+				address_postcode = ?,
+				address_country = ?
+              WHERE name = ? AND (address_line_1 IS NULL OR address_line_1 = '')`
+
+	for _, row := range guestRows {
+		if len(row) < 2 {
+			continue
+		}
+
+		fields := make([]string, len(addressColumns))
+		copy(fields, row[1:])
+
+		address := models.Address{
+			Line1:    strings.TrimSpace(fields[0]),
+			Line2:    strings.TrimSpace(fields[1]),
+			City:     strings.TrimSpace(fields[2]),
+			County:   strings.TrimSpace(fields[3]),
+			Postcode: strings.TrimSpace(fields[4]),
+			Country:  strings.TrimSpace(fields[5]),
+		}
+		if address.IsEmpty() {
+			continue
+		}
+
+		_, err := i.db.Exec(query, address.Line1, address.Line2, address.City, address.County, address.Postcode, address.Country, row[0])
+		if err != nil {
+			return fmt.Errorf("failed to import address for guest %v: %v", row[0], err)
+		}
+	}
+
 	return nil
 }
 
@@ -99,111 +221,225 @@ func (i GuestStore) getTableCount() (int, error) {
 func (i GuestStore) InsertGuest(name string) error {
 	tableCount, err := i.getTableCount()
 
-	guestKey := tableCount + 1
+	code := generateGuestCode(name, tableCount+1)
+
+	insertQuery := `INSERT INTO guests (name, code, form_started) VALUES (?, ?, false)`
+	_, err = i.db.Exec(insertQuery, name, code)
+	return err
+}
 
+func generateGuestCode(name string, guestKey int) string {
 	firstName := name
 	if strings.Contains(name, " ") {
 		firstName = strings.Split(name, " ")[0]
 	}
 
 	randCharsLen := codeLen - len(firstName) - 1
-	code := firstName + "-" + generatePseudorandomString(guestKey, randCharsLen)
-
-	insertQuery := `INSERT INTO guests (name, code, form_started) VALUES (?, ?, false)`
-	_, err = i.db.Exec(insertQuery, name, code)
-	return err
+	return firstName + "-" + generatePseudorandomString(guestKey, randCharsLen)
 }
 
-func (i GuestStore) UpdateGuestEmail(code, email string) error {
-	query := `UPDATE guests
-              SET
-				email = ?
-              WHERE code = ?`
-
-	result, err := i.db.Exec(query, email, code)
+// ApplyGuestImport inserts the new guests and overwrites the imported fields
+// of the updated guests in a single transaction, so a failed import leaves
+// the guest list untouched. changes are recorded in the guests' history.
+func (i GuestStore) ApplyGuestImport(newGuests, updatedGuests []models.Guest, changes []models.GuestEvent, actor string) error {
+	tx, err := i.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to update guest %v email %v: %v", code, email, err)
+		return err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	var tableCount int
+	err = tx.QueryRow("SELECT COUNT(*) FROM guests").Scan(&tableCount)
 	if err != nil {
-		return fmt.Errorf("failed to retrieve affected rows: %v", err)
+		return err
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("no guest found with code %s", code)
-	}
+	insertQuery := `INSERT INTO guests (
+		name, code, form_started, external_id, email, phone_number, household, plus_ones, events, tags,
+		address_line_1, address_line_2, address_city, address_county, address_postcode, address_country
+	) VALUES (?, ?, false, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	for idx, g := range newGuests {
+		code := generateGuestCode(g.Name, tableCount+idx+1)
+		result, err := tx.Exec(insertQuery,
+			g.Name, code, g.ExternalID, nullIfEmpty(g.Email), nullIfEmpty(g.PhoneNumber), g.Household, g.PlusOnes,
+			guestlist.JoinList(g.Events), guestlist.JoinList(g.Tags), g.Address.Line1, g.Address.Line2, g.Address.City,
+			g.Address.County, g.Address.Postcode, g.Address.Country,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert guest %v: %v", g.ExternalID, err)
+		}
 
-	return nil
-}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to retrieve inserted id: %v", err)
+		}
 
-func (i GuestStore) UpdateGuestPhoneNumber(code, phoneNumber string) error {
-	query := `UPDATE guests
+		err = recordGuestEvent(tx, models.GuestEvent{
+			GuestID:  int(id),
+			Type:     models.GuestEventImported,
+			Field:    "external_id",
+			NewValue: g.ExternalID,
+			Actor:    actor,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	updateQuery := `UPDATE guests
               SET
-				phone_number = ? 
-              WHERE code = ?`
+				name = ?,
+				external_id = ?,
+				email = ?,
+				phone_number = ?,
+				household = ?,
+				plus_ones = ?,
+				events = ?,
+				tags = ?,
+				address_line_1 = ?,
+				address_line_2 = ?,
+				address_city = ?,
+				address_county = ?,
+				address_postcode = ?,
+				address_country = ?
+              WHERE id = ?`
+
+	for _, g := range updatedGuests {
+		result, err := tx.Exec(updateQuery,
+			g.Name, g.ExternalID, nullIfEmpty(g.Email), nullIfEmpty(g.PhoneNumber), g.Household, g.PlusOnes,
+			guestlist.JoinList(g.Events), guestlist.JoinList(g.Tags), g.Address.Line1, g.Address.Line2, g.Address.City,
+			g.Address.County, g.Address.Postcode, g.Address.Country, g.ID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update guest %v: %v", g.ExternalID, err)
+		}
 
-	result, err := i.db.Exec(query, phoneNumber, code)
-	if err != nil {
-		return fmt.Errorf("failed to update guest %v phone number %v: %v", code, phoneNumber, err)
-	}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to retrieve affected rows: %v", err)
+		}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to retrieve affected rows: %v", err)
+		if rowsAffected == 0 {
+			return fmt.Errorf("no guest found with id %v", g.ID)
+		}
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("no guest found with code %s", code)
+	for _, change := range changes {
+		change.Type = models.GuestEventImported
+		change.Actor = actor
+		if err := recordGuestEvent(tx, change); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return tx.Commit()
 }
 
-func (i GuestStore) UpdateGuestMealChoice(code, mealChoice string) error {
-	query := `UPDATE guests
-              SET
-				meal_choice = ? 
-              WHERE code = ?`
+func (i GuestStore) UpdateGuestEmail(code, email, actor string) error {
+	return i.updateGuestField(code, "email", email, actor)
+}
 
-	result, err := i.db.Exec(query, mealChoice, code)
-	if err != nil {
-		return fmt.Errorf("failed to update guest %v meal choice %v: %v", code, mealChoice, err)
-	}
+func (i GuestStore) UpdateGuestPhoneNumber(code, phoneNumber, actor string) error {
+	return i.updateGuestField(code, "phone_number", phoneNumber, actor)
+}
 
-	rowsAffected, err := result.RowsAffected()
+func (i GuestStore) UpdateGuestMealChoice(code, mealChoice, actor string) error {
+	return i.updateGuestField(code, "meal_choice", mealChoice, actor)
+}
+
+func (i GuestStore) UpdateGuestDietaryRequirements(code, dietaryRequirements, actor string) error {
+	return i.updateGuestField(code, "dietary_requirements", dietaryRequirements, actor)
+}
+
+// UpdateGuestLanguage saves the language the guest chose to see the site in.
+func (i GuestStore) UpdateGuestLanguage(code, language, actor string) error {
+	return i.updateGuestField(code, "language", language, actor)
+}
+
+// updateGuestField sets a single column for the guest with code and records
+// the change in the guest's history when the value is different.
+func (i GuestStore) updateGuestField(code, column string, value any, actor string) error {
+	tx, err := i.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to retrieve affected rows: %v", err)
+		return err
 	}
+	defer tx.Rollback()
 
-	if rowsAffected == 0 {
+	var id int
+	var oldValue sql.NullString
+	err = tx.QueryRow(fmt.Sprintf(`SELECT id, %s FROM guests WHERE code = ?`, column), code).Scan(&id, &oldValue)
+	if err == sql.ErrNoRows {
 		return fmt.Errorf("no guest found with code %s", code)
+	} else if err != nil {
+		return err
 	}
 
-	return nil
-}
+	_, err = tx.Exec(fmt.Sprintf(`UPDATE guests SET %s = ? WHERE id = ?`, column), value, id)
+	if err != nil {
+		return fmt.Errorf("failed to update guest %v %s: %v", code, column, err)
+	}
+
+	newValue := fmt.Sprint(value)
+	if oldValue.String != newValue {
+		err = recordGuestEvent(tx, models.GuestEvent{
+			GuestID:  id,
+			Type:     models.GuestEventUpdated,
+			Field:    column,
+			OldValue: oldValue.String,
+			NewValue: newValue,
+			Actor:    actor,
+		})
+		if err != nil {
+			return err
+		}
+	}
 
-func (i GuestStore) UpdateGuestDietaryRequirements(code, dietaryRequirements string) error {
-	query := `UPDATE guests
-              SET
-				dietary_requirements = ? 
-              WHERE code = ?`
+	return tx.Commit()
+}
 
-	result, err := i.db.Exec(query, dietaryRequirements, code)
+func (i GuestStore) UpdateGuestAddress(code string, address models.Address, actor string) error {
+	tx, err := i.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to update guest %v dietary requirements %v: %v", code, dietaryRequirements, err)
+		return err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	guest, err := getGuestForUpdate(tx, code)
 	if err != nil {
-		return fmt.Errorf("failed to retrieve affected rows: %v", err)
+		return err
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("no guest found with code %s", code)
+	query := `UPDATE guests
+              SET
+				address_line_1 = ?,
+				address_line_2 = ?,
+				address_city = ?,
+				address_county = ?,
+				address_postcode = ?,
+				address_country = ?
+              WHERE id = ?`
+
+	_, err = tx.Exec(query, address.Line1, address.Line2, address.City, address.County, address.Postcode, address.Country, guest.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update guest %v address: %v", code, err)
+	}
+
+	if guest.Address != address {
+		err = recordGuestEvent(tx, models.GuestEvent{
+			GuestID:  guest.ID,
+			Type:     models.GuestEventUpdated,
+			Field:    "address",
+			OldValue: strings.Join(guest.Address.Lines(), ", "),
+			NewValue: strings.Join(address.Lines(), ", "),
+			Actor:    actor,
+		})
+		if err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return tx.Commit()
 }
 
 func (i GuestStore) UpdateGuestDetailsProvidedSuccessfully(code string) error {
@@ -231,26 +467,57 @@ func (i GuestStore) UpdateGuestDetailsProvidedSuccessfully(code string) error {
 	return nil
 }
 
-func (i GuestStore) UpdateGuestAttendance(code string, attendance, formCompleted bool) error {
+func (i GuestStore) UpdateGuestAttendance(code string, attendance, formCompleted bool, actor string) error {
+	tx, err := i.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int
+	var oldAttendance sql.NullBool
+	err = tx.QueryRow(`SELECT id, attendance FROM guests WHERE code = ?`, code).Scan(&id, &oldAttendance)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no guest found with code %s", code)
+	} else if err != nil {
+		return err
+	}
+
 	query := `UPDATE guests
               SET attendance = ?, form_started = true, form_completed = ?
-              WHERE code = ?`
+              WHERE id = ?`
 
-	result, err := i.db.Exec(query, attendance, formCompleted, code)
+	_, err = tx.Exec(query, attendance, formCompleted, id)
 	if err != nil {
 		return fmt.Errorf("failed to update guest: %v", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to retrieve affected rows: %v", err)
+	// Guests who can no longer come give up their seat
+	if !attendance {
+		if err := unassignSeat(tx, id, actor); err != nil {
+			return err
+		}
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("no guest found with code %s", code)
+	if !oldAttendance.Valid || oldAttendance.Bool != attendance {
+		var oldValue string
+		if oldAttendance.Valid {
+			oldValue = strconv.FormatBool(oldAttendance.Bool)
+		}
+		err = recordGuestEvent(tx, models.GuestEvent{
+			GuestID:  id,
+			Type:     models.GuestEventUpdated,
+			Field:    "attendance",
+			OldValue: oldValue,
+			NewValue: strconv.FormatBool(attendance),
+			Actor:    actor,
+		})
+		if err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return tx.Commit()
 }
 
 func (i GuestStore) UpdateGuestInvalidDetails(code string, invalidDetails bool) error {
@@ -275,6 +542,10 @@ func (i GuestStore) UpdateGuestInvalidDetails(code string, invalidDetails bool)
 	return nil
 }
 
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
 func generatePseudorandomString(seed int, length int) string {
 	source := rand.New(rand.NewSource(int64(seed)))
 
@@ -288,8 +559,7 @@ func generatePseudorandomString(seed int, length int) string {
 	return string(result)
 }
 
-func (i GuestStore) GetGuest(code string) (*models.Guest, error) {
-	query := `SELECT
+const selectGuestColumns = `SELECT
 		id, 
 		name, 
 		code, 
@@ -301,11 +571,28 @@ func (i GuestStore) GetGuest(code string) (*models.Guest, error) {
 		invalid_details,
 		details_provided,
 		form_started,
-		form_completed 
-	FROM guests WHERE code = ?`
+		form_completed,
+		address_line_1,
+		address_line_2,
+		address_city,
+		address_county,
+		address_postcode,
+		address_country,
+		external_id,
+		household,
+		plus_ones,
+		events,
+		tags,
+		deleted_at IS NOT NULL,
+		arrived_at,
+		language
+	FROM guests`
 
-	row := i.db.QueryRow(query, code)
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
+func scanGuest(row rowScanner) (*models.Guest, error) {
 	var guest models.Guest
 
 	var email sql.NullString
@@ -316,6 +603,8 @@ func (i GuestStore) GetGuest(code string) (*models.Guest, error) {
 	var invalidDetails sql.NullBool
 	var detailsProvided sql.NullBool
 	var formCompleted sql.NullBool
+	var addressLine1, addressLine2, addressCity, addressCounty, addressPostcode, addressCountry sql.NullString
+	var externalID, household, events, tags, arrivedAt, language sql.NullString
 
 	// Scan the result into the guest struct
 	err := row.Scan(
@@ -331,7 +620,24 @@ func (i GuestStore) GetGuest(code string) (*models.Guest, error) {
 		&detailsProvided,
 		&guest.FormStarted,
 		&formCompleted,
+		&addressLine1,
+		&addressLine2,
+		&addressCity,
+		&addressCounty,
+		&addressPostcode,
+		&addressCountry,
+		&externalID,
+		&household,
+		&guest.PlusOnes,
+		&events,
+		&tags,
+		&guest.Deleted,
+		&arrivedAt,
+		&language,
 	)
+	if err != nil {
+		return nil, err
+	}
 
 	if email.Valid {
 		guest.Email = email.String
@@ -358,19 +664,64 @@ func (i GuestStore) GetGuest(code string) (*models.Guest, error) {
 		guest.FormCompleted = formCompleted.Bool
 	}
 
+	guest.Address = models.Address{
+		Line1:    addressLine1.String,
+		Line2:    addressLine2.String,
+		City:     addressCity.String,
+		County:   addressCounty.String,
+		Postcode: addressPostcode.String,
+		Country:  addressCountry.String,
+	}
+	guest.ExternalID = externalID.String
+	guest.Household = household.String
+	guest.Events = guestlist.SplitList(events.String)
+	guest.Tags = guestlist.SplitList(tags.String)
+	guest.ArrivedAt = arrivedAt.String
+	guest.Language = language.String
+
+	return &guest, nil
+}
+
+func (i GuestStore) GetGuest(code string) (*models.Guest, error) {
+	query := selectGuestColumns + ` WHERE code = ? AND deleted_at IS NULL`
+
+	row := i.db.QueryRow(query, code)
+
+	guest, err := scanGuest(row)
 	if err == sql.ErrNoRows {
 		return nil, nil // No guest found with the given ID
 	} else if err != nil {
 		return nil, err // Return error for other scan errors
 	}
 
-	return &guest, nil // Return the guest struct
+	return guest, nil // Return the guest struct
+}
+
+func (i GuestStore) GetAllGuests(includeDeleted bool) ([]models.Guest, error) {
+	query := selectGuestColumns + ` WHERE ? OR deleted_at IS NULL ORDER BY id`
+
+	rows, err := i.db.Query(query, includeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var guests []models.Guest
+	for rows.Next() {
+		guest, err := scanGuest(rows)
+		if err != nil {
+			return nil, err
+		}
+		guests = append(guests, *guest)
+	}
+
+	return guests, rows.Err()
 }
 
 func (i GuestStore) GetGuestCode(name string) (string, error) {
 	query := `SELECT
 		code 
-	FROM guests WHERE name = ?`
+	FROM guests WHERE name = ? AND deleted_at IS NULL`
 
 	// Execute the query
 	row := i.db.QueryRow(query, name)
@@ -407,7 +758,8 @@ func (i GuestStore) GetRSVPs() (*sql.Rows, error) {
 		details_provided,
 		form_started,
 		form_completed 
-	FROM guests`
+	FROM guests
+	WHERE deleted_at IS NULL`
 
 	rows, err := i.db.Query(query)
 	if err != nil {
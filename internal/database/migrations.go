@@ -0,0 +1,61 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+type column struct {
+	name string
+	// pk is the column's position in the primary key, or 0 if it isn't part
+	// of it
+	pk int
+}
+
+func tableColumns(q queryer, table string) ([]column, error) {
+	rows, err := q.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []column
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue any
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column{name: name, pk: pk})
+	}
+
+	return columns, rows.Err()
+}
+
+// addColumnIfNotExists lets tables created by an older version of the app pick
+// up new columns, as CREATE TABLE IF NOT EXISTS leaves existing tables alone.
+func (i GuestStore) addColumnIfNotExists(table, column, definition string) error {
+	columns, err := tableColumns(i.db, table)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range columns {
+		if c.name == column {
+			return nil
+		}
+	}
+
+	_, err = i.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return fmt.Errorf("failed to add column %s to %s: %v", column, table, err)
+	}
+
+	return nil
+}
@@ -3,17 +3,25 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
 )
 
-func (i GuestStore) createPageVisitsTable() error {
-	createTableQuery := `CREATE TABLE IF NOT EXISTS page_visits (
-        id INTEGER NOT NULL,
-        page_name TEXT NOT NULL,
-		visit_count INTEGER DEFAULT 1,
-		first_visit_time TEXT,
-		latest_visit_time TEXT,
-		PRIMARY KEY (id, page_name)
+// Every page view is appended to visit_events. page_visits used to be a table
+// of counters and is now a view over the events, so the visits csv and the
+// stats keep working unchanged. Visits are never changed once recorded, so
+// those of a guest merged into another are counted for the kept guest when
+// they're read.
+func (i GuestStore) createVisitEventsTable() error {
+	createTableQuery := `CREATE TABLE IF NOT EXISTS visit_events (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        guest_id INTEGER,
+		visitor_id TEXT,
+		page_name TEXT NOT NULL,
+		referrer TEXT,
+		user_agent TEXT,
+		created_at TEXT NOT NULL
     );`
 
 	_, err := i.db.Exec(createTableQuery)
@@ -21,32 +29,107 @@ func (i GuestStore) createPageVisitsTable() error {
 		return err
 	}
 
-	log.Println("page_visit table set up and populated successfully!")
+	_, err = i.db.Exec(`CREATE INDEX IF NOT EXISTS visit_events_guest_id ON visit_events (guest_id, created_at)`)
+	if err != nil {
+		return err
+	}
+
+	if err := i.migratePageVisits(); err != nil {
+		return err
+	}
+
+	// the view is replaced in case it was created before merged guests were
+	// counted for the kept guest
+	_, err = i.db.Exec(`DROP VIEW IF EXISTS page_visits`)
+	if err != nil {
+		return err
+	}
+
+	createViewQuery := `CREATE VIEW page_visits AS
+	SELECT
+		COALESCE(guests.merged_into, visit_events.guest_id) AS id,
+		visit_events.page_name,
+		COUNT(*) AS visit_count,
+		MIN(visit_events.created_at) AS first_visit_time,
+		MAX(visit_events.created_at) AS latest_visit_time
+	FROM visit_events
+	LEFT JOIN guests ON guests.id = visit_events.guest_id
+	WHERE visit_events.guest_id IS NOT NULL
+	GROUP BY 1, 2`
+
+	_, err = i.db.Exec(createViewQuery)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("visit_events table set up successfully!")
 	return nil
 }
 
-func (i GuestStore) UpdatePageVisit(id int, page string) error {
-	query := `INSERT INTO 
-	    page_visits (id, page_name, visit_count, first_visit_time, latest_visit_time)
-	VALUES (?, ?, 1, datetime('now'), datetime('now'))
-	ON CONFLICT(id, page_name)
-	DO UPDATE SET
-		visit_count = visit_count + 1,
-		latest_visit_time = datetime('now');
-	`
-
-	result, err := i.db.Exec(query, id, page)
+// migratePageVisits replaces the old page_visits counters with events. Only
+// the first and latest visit times were kept, so the first counted visit is
+// given the first time and the rest the latest time, which keeps the derived
+// counts and times the same as before.
+func (i GuestStore) migratePageVisits() error {
+	var tableType string
+	err := i.db.QueryRow(`SELECT type FROM sqlite_master WHERE name = 'page_visits'`).Scan(&tableType)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if tableType != "table" {
+		return nil
+	}
+
+	tx, err := i.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to save page visit: %v", err)
+		return err
 	}
+	defer tx.Rollback()
+
+	migrateQuery := `WITH RECURSIVE visits(id, page_name, n, visit_count, first_visit_time, latest_visit_time) AS (
+		SELECT id, page_name, 1, visit_count, first_visit_time, latest_visit_time FROM page_visits
+		UNION ALL
+		SELECT id, page_name, n + 1, visit_count, first_visit_time, latest_visit_time FROM visits WHERE n < visit_count
+	)
+	INSERT INTO visit_events (guest_id, page_name, created_at)
+	SELECT id, page_name, CASE WHEN n = 1 THEN first_visit_time ELSE latest_visit_time END
+	FROM visits
+	ORDER BY 3`
 
-	rowsAffected, err := result.RowsAffected()
+	result, err := tx.Exec(migrateQuery)
 	if err != nil {
-		return fmt.Errorf("failed to retrieve affected rows: %v", err)
+		return fmt.Errorf("failed to migrate page visits: %v", err)
 	}
 
-	if rowsAffected != 1 {
-		return fmt.Errorf("rowsAffected %v for id %v and page %s was not 1", rowsAffected, id, page)
+	_, err = tx.Exec(`DROP TABLE page_visits`)
+	if err != nil {
+		return fmt.Errorf("failed to drop page_visits table: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	migrated, _ := result.RowsAffected()
+	slog.Info("migrated page visits to visit_events", "visits", migrated)
+	return nil
+}
+
+func (i GuestStore) RecordVisit(visit models.Visit) error {
+	query := `INSERT INTO
+	    visit_events (guest_id, visitor_id, page_name, referrer, user_agent, created_at)
+	VALUES (?, ?, ?, ?, ?, datetime('now'))`
+
+	var guestID sql.NullInt64
+	if visit.GuestID != 0 {
+		guestID = sql.NullInt64{Int64: int64(visit.GuestID), Valid: true}
+	}
+
+	_, err := i.db.Exec(query, guestID, nullIfEmpty(visit.VisitorID), visit.Page, nullIfEmpty(visit.Referrer), nullIfEmpty(visit.UserAgent))
+	if err != nil {
+		return fmt.Errorf("failed to save page visit: %v", err)
 	}
 
 	return nil
@@ -68,3 +151,42 @@ func (i GuestStore) GetVisitsData() (*sql.Rows, error) {
 
 	return rows, nil
 }
+
+// GetVisitEvents returns every page view in the order they happened, with
+// visits by a guest merged into another given the kept guest's id.
+func (i GuestStore) GetVisitEvents() ([]models.Visit, error) {
+	query := `SELECT
+		visit_events.id,
+		COALESCE(guests.merged_into, visit_events.guest_id),
+		visit_events.visitor_id,
+		visit_events.page_name,
+		visit_events.referrer,
+		visit_events.user_agent,
+		visit_events.created_at
+	FROM visit_events
+	LEFT JOIN guests ON guests.id = visit_events.guest_id
+	ORDER BY visit_events.created_at, visit_events.id`
+
+	rows, err := i.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var visits []models.Visit
+	for rows.Next() {
+		var visit models.Visit
+		var guestID sql.NullInt64
+		var visitorID, referrer, userAgent sql.NullString
+		if err := rows.Scan(&visit.ID, &guestID, &visitorID, &visit.Page, &referrer, &userAgent, &visit.CreatedAt); err != nil {
+			return nil, err
+		}
+		visit.GuestID = int(guestID.Int64)
+		visit.VisitorID = visitorID.String
+		visit.Referrer = referrer.String
+		visit.UserAgent = userAgent.String
+		visits = append(visits, visit)
+	}
+
+	return visits, rows.Err()
+}
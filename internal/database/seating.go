@@ -0,0 +1,501 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+var (
+	ErrTableNotFound     = errors.New("table not found")
+	ErrTableExists       = errors.New("table already exists")
+	ErrTableFull         = errors.New("table is full")
+	ErrSeatTaken         = errors.New("seat is already taken")
+	ErrInvalidSeat       = errors.New("seat does not exist")
+	ErrGuestNotAttending = errors.New("guest has not accepted")
+)
+
+func (i GuestStore) createSeatingTables() error {
+	createTablesQuery := `CREATE TABLE IF NOT EXISTS seating_tables (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        name TEXT NOT NULL UNIQUE,
+		capacity INTEGER NOT NULL,
+		shape TEXT NOT NULL
+    );`
+
+	_, err := i.db.Exec(createTablesQuery)
+	if err != nil {
+		return err
+	}
+
+	createAssignmentsQuery := `CREATE TABLE IF NOT EXISTS seat_assignments (
+        guest_id INTEGER PRIMARY KEY,
+        table_id INTEGER NOT NULL,
+		seat_number INTEGER,
+		UNIQUE (table_id, seat_number)
+    );`
+
+	_, err = i.db.Exec(createAssignmentsQuery)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("seating tables set up successfully!")
+	return nil
+}
+
+func (i GuestStore) CreateTable(table models.Table) error {
+	_, err := i.db.Exec(`INSERT INTO seating_tables (name, capacity, shape) VALUES (?, ?, ?)`, table.Name, table.Capacity, table.Shape)
+	if err != nil {
+		var exists int
+		if i.db.QueryRow(`SELECT COUNT(*) FROM seating_tables WHERE name = ?`, table.Name).Scan(&exists) == nil && exists > 0 {
+			return fmt.Errorf("table %s: %w", table.Name, ErrTableExists)
+		}
+		return fmt.Errorf("failed to create table %v: %v", table.Name, err)
+	}
+
+	return nil
+}
+
+// UpdateTable changes the capacity and shape of the table called name. The
+// capacity can't be reduced below the number of guests already seated there.
+func (i GuestStore) UpdateTable(name string, capacity int, shape string) error {
+	tx, err := i.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	table, err := getTable(tx, name)
+	if err != nil {
+		return err
+	}
+
+	var seated, highestSeat int
+	err = tx.QueryRow(`SELECT COUNT(*), COALESCE(MAX(seat_number), 0) FROM seat_assignments WHERE table_id = ?`, table.ID).Scan(&seated, &highestSeat)
+	if err != nil {
+		return err
+	}
+	if seated > capacity || highestSeat > capacity {
+		return fmt.Errorf("table %s has %d guests seated up to seat %d: %w", name, seated, highestSeat, ErrTableFull)
+	}
+
+	_, err = tx.Exec(`UPDATE seating_tables SET capacity = ?, shape = ? WHERE id = ?`, capacity, shape, table.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update table %v: %v", name, err)
+	}
+
+	return tx.Commit()
+}
+
+// DeleteTable removes a table and unseats everyone who was sat at it.
+func (i GuestStore) DeleteTable(name string) error {
+	tx, err := i.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	table, err := getTable(tx, name)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM seat_assignments WHERE table_id = ?`, table.ID)
+	if err != nil {
+		return fmt.Errorf("failed to unseat guests from table %v: %v", name, err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM seating_tables WHERE id = ?`, table.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete table %v: %v", name, err)
+	}
+
+	return tx.Commit()
+}
+
+func getTable(tx *sql.Tx, name string) (*models.Table, error) {
+	var table models.Table
+	err := tx.QueryRow(`SELECT id, name, capacity, shape FROM seating_tables WHERE name = ?`, name).Scan(
+		&table.ID,
+		&table.Name,
+		&table.Capacity,
+		&table.Shape,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("table %s: %w", name, ErrTableNotFound)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &table, nil
+}
+
+// AssignSeat seats the guest with code at the table called tableName,
+// replacing any seat they already had. Only guests who have accepted can be
+// seated and tables can't be filled beyond their capacity. A seatNumber of 0
+// leaves the guest free to sit anywhere at the table.
+func (i GuestStore) AssignSeat(code, tableName string, seatNumber int, actor string) error {
+	tx, err := i.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	guest, err := getGuestForUpdate(tx, code)
+	if err != nil {
+		return err
+	}
+	if guest.Deleted {
+		return fmt.Errorf("guest %s is deleted: %w", code, ErrGuestNotFound)
+	}
+	if !guest.FormStarted || !guest.Attendance {
+		return fmt.Errorf("guest %s: %w", code, ErrGuestNotAttending)
+	}
+
+	table, err := getTable(tx, tableName)
+	if err != nil {
+		return err
+	}
+
+	if seatNumber < 0 || seatNumber > table.Capacity {
+		return fmt.Errorf("seat %d is not between 1 and %d at table %s: %w", seatNumber, table.Capacity, tableName, ErrInvalidSeat)
+	}
+
+	var seated int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM seat_assignments WHERE table_id = ? AND guest_id != ?`, table.ID, guest.ID).Scan(&seated)
+	if err != nil {
+		return err
+	}
+	if seated >= table.Capacity {
+		return fmt.Errorf("table %s seats %d: %w", tableName, table.Capacity, ErrTableFull)
+	}
+
+	if seatNumber > 0 {
+		var taken int
+		err = tx.QueryRow(`SELECT COUNT(*) FROM seat_assignments WHERE table_id = ? AND seat_number = ? AND guest_id != ?`, table.ID, seatNumber, guest.ID).Scan(&taken)
+		if err != nil {
+			return err
+		}
+		if taken > 0 {
+			return fmt.Errorf("seat %d at table %s: %w", seatNumber, tableName, ErrSeatTaken)
+		}
+	}
+
+	if err := seatGuest(tx, guest.ID, *table, seatNumber, actor); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// seatGuest writes the guest's seat without any checks, recording the move in
+// their history.
+func seatGuest(tx *sql.Tx, guestID int, table models.Table, seatNumber int, actor string) error {
+	previous, err := getSeatAssignmentName(tx, guestID)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO seat_assignments (guest_id, table_id, seat_number)
+	VALUES (?, ?, ?)
+	ON CONFLICT(guest_id)
+	DO UPDATE SET table_id = excluded.table_id, seat_number = excluded.seat_number`
+
+	_, err = tx.Exec(query, guestID, table.ID, sql.NullInt64{Int64: int64(seatNumber), Valid: seatNumber > 0})
+	if err != nil {
+		return fmt.Errorf("failed to seat guest %v: %v", guestID, err)
+	}
+
+	current := seatName(table.Name, seatNumber)
+	if previous == current {
+		return nil
+	}
+
+	return recordGuestEvent(tx, models.GuestEvent{
+		GuestID:  guestID,
+		Type:     models.GuestEventUpdated,
+		Field:    "table",
+		OldValue: previous,
+		NewValue: current,
+		Actor:    actor,
+	})
+}
+
+// ReplaceSeatingPlan swaps the current seating plan for seats, a map of guest
+// id to table id, in one go. Guests missing from seats lose their seat and
+// everyone in the new plan is free to sit anywhere at their table.
+// Capacities are checked here but whether guests have accepted is left to the
+// caller, as the plan is built from the guests who have.
+func (i GuestStore) ReplaceSeatingPlan(seats map[int]int, actor string) error {
+	tx, err := i.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	tables := make(map[int]models.Table)
+	rows, err := tx.Query(`SELECT id, name, capacity, shape FROM seating_tables`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var table models.Table
+		if err := rows.Scan(&table.ID, &table.Name, &table.Capacity, &table.Shape); err != nil {
+			rows.Close()
+			return err
+		}
+		tables[table.ID] = table
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	seated := make(map[int]int)
+	for _, tableID := range seats {
+		table, ok := tables[tableID]
+		if !ok {
+			return fmt.Errorf("table id %d: %w", tableID, ErrTableNotFound)
+		}
+		seated[tableID]++
+		if seated[tableID] > table.Capacity {
+			return fmt.Errorf("table %s seats %d: %w", table.Name, table.Capacity, ErrTableFull)
+		}
+	}
+
+	var currentGuestIDs []int
+	rows, err = tx.Query(`SELECT guest_id FROM seat_assignments`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var guestID int
+		if err := rows.Scan(&guestID); err != nil {
+			rows.Close()
+			return err
+		}
+		currentGuestIDs = append(currentGuestIDs, guestID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, guestID := range currentGuestIDs {
+		if _, ok := seats[guestID]; !ok {
+			if err := unassignSeat(tx, guestID, actor); err != nil {
+				return err
+			}
+		}
+	}
+
+	guestIDs := make([]int, 0, len(seats))
+	for guestID := range seats {
+		guestIDs = append(guestIDs, guestID)
+	}
+	slices.Sort(guestIDs)
+
+	for _, guestID := range guestIDs {
+		if err := seatGuest(tx, guestID, tables[seats[guestID]], 0, actor); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (i GuestStore) UnassignSeat(code, actor string) error {
+	tx, err := i.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	guest, err := getGuestForUpdate(tx, code)
+	if err != nil {
+		return err
+	}
+
+	if err := unassignSeat(tx, guest.ID, actor); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// unassignSeat removes the guest's seat, if they have one, as part of a
+// larger change such as the guest declining.
+func unassignSeat(tx *sql.Tx, guestID int, actor string) error {
+	previous, err := getSeatAssignmentName(tx, guestID)
+	if err != nil {
+		return err
+	}
+	if previous == "" {
+		return nil
+	}
+
+	_, err = tx.Exec(`DELETE FROM seat_assignments WHERE guest_id = ?`, guestID)
+	if err != nil {
+		return fmt.Errorf("failed to unseat guest %v: %v", guestID, err)
+	}
+
+	return recordGuestEvent(tx, models.GuestEvent{
+		GuestID:  guestID,
+		Type:     models.GuestEventUpdated,
+		Field:    "table",
+		OldValue: previous,
+		Actor:    actor,
+	})
+}
+
+func getSeatAssignmentName(tx *sql.Tx, guestID int) (string, error) {
+	var tableName string
+	var seatNumber sql.NullInt64
+	err := tx.QueryRow(`SELECT t.name, a.seat_number
+	FROM seat_assignments a
+	JOIN seating_tables t ON t.id = a.table_id
+	WHERE a.guest_id = ?`, guestID).Scan(&tableName, &seatNumber)
+	if err == sql.ErrNoRows {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return seatName(tableName, int(seatNumber.Int64)), nil
+}
+
+func seatName(tableName string, seatNumber int) string {
+	if seatNumber == 0 {
+		return tableName
+	}
+	return tableName + " seat " + strconv.Itoa(seatNumber)
+}
+
+const selectSeatAssignmentColumns = `SELECT
+		g.id,
+		g.code,
+		g.name,
+		g.meal_choice,
+		t.id,
+		t.name,
+		a.seat_number
+	FROM seat_assignments a
+	JOIN guests g ON g.id = a.guest_id
+	JOIN seating_tables t ON t.id = a.table_id`
+
+func scanSeatAssignment(row rowScanner) (*models.SeatAssignment, error) {
+	var assignment models.SeatAssignment
+	var mealChoice sql.NullString
+	var seatNumber sql.NullInt64
+
+	err := row.Scan(
+		&assignment.GuestID,
+		&assignment.GuestCode,
+		&assignment.GuestName,
+		&mealChoice,
+		&assignment.TableID,
+		&assignment.TableName,
+		&seatNumber,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	assignment.MealChoice = mealChoice.String
+	assignment.SeatNumber = int(seatNumber.Int64)
+
+	return &assignment, nil
+}
+
+// GetSeatAssignment returns nil if the guest hasn't been given a seat.
+func (i GuestStore) GetSeatAssignment(guestID int) (*models.SeatAssignment, error) {
+	row := i.db.QueryRow(selectSeatAssignmentColumns+` WHERE a.guest_id = ?`, guestID)
+
+	assignment, err := scanSeatAssignment(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return assignment, nil
+}
+
+func (i GuestStore) GetTables() ([]models.Table, error) {
+	rows, err := i.db.Query(`SELECT id, name, capacity, shape FROM seating_tables ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := []models.Table{}
+	for rows.Next() {
+		var table models.Table
+		if err := rows.Scan(&table.ID, &table.Name, &table.Capacity, &table.Shape); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+
+	return tables, rows.Err()
+}
+
+// GetSeatingPlan returns every table with the guests seated at it, along
+// with the guests who have accepted but don't have a seat yet.
+func (i GuestStore) GetSeatingPlan() (models.SeatingPlan, error) {
+	plan := models.SeatingPlan{Tables: []models.SeatedTable{}, Unassigned: []models.GuestRef{}}
+
+	tables, err := i.GetTables()
+	if err != nil {
+		return plan, err
+	}
+
+	tableIdx := make(map[int]int)
+	for idx, table := range tables {
+		tableIdx[table.ID] = idx
+		plan.Tables = append(plan.Tables, models.SeatedTable{Table: table, Guests: []models.SeatAssignment{}})
+	}
+
+	rows, err := i.db.Query(selectSeatAssignmentColumns + ` ORDER BY t.id, a.seat_number IS NULL, a.seat_number, g.name`)
+	if err != nil {
+		return plan, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		assignment, err := scanSeatAssignment(rows)
+		if err != nil {
+			return plan, err
+		}
+		idx := tableIdx[assignment.TableID]
+		plan.Tables[idx].Guests = append(plan.Tables[idx].Guests, *assignment)
+	}
+	if err := rows.Err(); err != nil {
+		return plan, err
+	}
+
+	unassignedRows, err := i.db.Query(`SELECT code, name FROM guests
+	WHERE attendance = true AND deleted_at IS NULL
+	AND id NOT IN (SELECT guest_id FROM seat_assignments)
+	ORDER BY name`)
+	if err != nil {
+		return plan, err
+	}
+	defer unassignedRows.Close()
+
+	for unassignedRows.Next() {
+		var ref models.GuestRef
+		if err := unassignedRows.Scan(&ref.Code, &ref.Name); err != nil {
+			return plan, err
+		}
+		plan.Unassigned = append(plan.Unassigned, ref)
+	}
+
+	return plan, unassignedRows.Err()
+}
@@ -3,7 +3,7 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/nesquikmike/wedding-rsvps/internal/models"
 )
@@ -13,7 +13,8 @@ func (i GuestStore) createSessionDataTable() error {
         code TEXT PRIMARY KEY,
 		invalid_email BOOLEAN,
 		invalid_phone_number BOOLEAN,
-		invalid_dietary_requirements BOOLEAN
+		invalid_dietary_requirements BOOLEAN,
+		invalid_address BOOLEAN
     );`
 
 	_, err := i.db.Exec(createTableQuery)
@@ -21,7 +22,12 @@ func (i GuestStore) createSessionDataTable() error {
 		return err
 	}
 
-	log.Println("session_data table set up successfully!")
+	err = i.addColumnIfNotExists("session_data", "invalid_address", "BOOLEAN")
+	if err != nil {
+		return err
+	}
+
+	slog.Info("session_data table set up successfully!")
 	return nil
 }
 
@@ -100,9 +106,34 @@ func (i GuestStore) UpdateSessionInvalidDietaryRequirements(code string, invalid
 	return nil
 }
 
+func (i GuestStore) UpdateSessionInvalidAddress(code string, invalid bool) error {
+	query := `
+	INSERT INTO session_data (code, invalid_address) 
+	VALUES (?, ?)
+	ON CONFLICT(code)
+	DO UPDATE SET invalid_address = excluded.invalid_address;
+	`
+
+	result, err := i.db.Exec(query, code, invalid)
+	if err != nil {
+		return fmt.Errorf("failed to save session data: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve affected rows: %v", err)
+	}
+
+	if rowsAffected != 1 {
+		return fmt.Errorf("rowsAffected %v for code %v with invalid address was not 1", rowsAffected, code)
+	}
+
+	return nil
+}
+
 func (i GuestStore) GetSessionData(code string) (*models.SessionData, error) {
 	query := `SELECT
-		invalid_email, invalid_phone_number, invalid_dietary_requirements 
+		invalid_email, invalid_phone_number, invalid_dietary_requirements, invalid_address 
 	FROM session_data WHERE code = ?`
 
 	row := i.db.QueryRow(query, code)
@@ -110,11 +141,13 @@ func (i GuestStore) GetSessionData(code string) (*models.SessionData, error) {
 	var invalidEmail sql.NullBool
 	var invalidPhoneNumber sql.NullBool
 	var invalidDietaryRequirements sql.NullBool
+	var invalidAddress sql.NullBool
 
 	err := row.Scan(
 		&invalidEmail,
 		&invalidPhoneNumber,
 		&invalidDietaryRequirements,
+		&invalidAddress,
 	)
 	if err != nil {
 		return nil, err
@@ -123,6 +156,7 @@ func (i GuestStore) GetSessionData(code string) (*models.SessionData, error) {
 	var invalidEmailBool bool
 	var invalidPhoneNumberBool bool
 	var invalidDietaryRequirementsBool bool
+	var invalidAddressBool bool
 
 	if invalidEmail.Valid {
 		invalidEmailBool = invalidEmail.Bool
@@ -133,12 +167,16 @@ func (i GuestStore) GetSessionData(code string) (*models.SessionData, error) {
 	if invalidDietaryRequirements.Valid {
 		invalidDietaryRequirementsBool = invalidDietaryRequirements.Bool
 	}
+	if invalidAddress.Valid {
+		invalidAddressBool = invalidAddress.Bool
+	}
 
 	sessionData := &models.SessionData{
 		Code:                       code,
 		InvalidEmail:               invalidEmailBool,
 		InvalidPhoneNumber:         invalidPhoneNumberBool,
 		InvalidDietaryRequirements: invalidDietaryRequirementsBool,
+		InvalidAddress:             invalidAddressBool,
 	}
 
 	return sessionData, nil
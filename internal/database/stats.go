@@ -0,0 +1,164 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+const noMealChoice = "not chosen"
+
+func (i GuestStore) GetStats() (models.Stats, error) {
+	var stats models.Stats
+
+	// A guest has opened their invitation once they have visited any page
+	// with their code
+	query := `SELECT
+		COUNT(*),
+		COUNT(CASE WHEN form_started THEN 1 END),
+		COUNT(CASE WHEN form_started AND attendance THEN 1 END),
+		COUNT(CASE WHEN form_started AND NOT attendance THEN 1 END),
+		COUNT(CASE WHEN NOT form_started AND NOT EXISTS (SELECT 1 FROM page_visits WHERE page_visits.id = guests.id) THEN 1 END),
+		COUNT(CASE WHEN (form_started OR EXISTS (SELECT 1 FROM page_visits WHERE page_visits.id = guests.id)) AND NOT COALESCE(form_completed, false) THEN 1 END),
+		COUNT(CASE WHEN form_started AND attendance AND invalid_details THEN 1 END)
+	FROM guests
+	WHERE deleted_at IS NULL`
+
+	err := i.db.QueryRow(query).Scan(
+		&stats.Invited,
+		&stats.Responded,
+		&stats.Attending,
+		&stats.Declined,
+		&stats.NotOpened,
+		&stats.OpenedNotCompleted,
+		&stats.InvalidDetails,
+	)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count guests: %v", err)
+	}
+
+	if stats.Invited > 0 {
+		stats.ResponseRate = float64(stats.Responded) / float64(stats.Invited)
+	}
+
+	stats.MealChoices, err = i.getMealChoiceCounts()
+	if err != nil {
+		return stats, err
+	}
+
+	stats.Responses, err = i.getDailyResponses()
+	if err != nil {
+		return stats, err
+	}
+
+	stats.DailyVisitors, err = i.getDailyVisitors()
+	if err != nil {
+		return stats, err
+	}
+	for _, day := range stats.DailyVisitors {
+		stats.LandingVisitors += day.Landing
+		stats.InvalidCodeVisitors += day.InvalidCode
+	}
+
+	return stats, nil
+}
+
+func (i GuestStore) getMealChoiceCounts() ([]models.Count, error) {
+	query := `SELECT
+		COALESCE(NULLIF(meal_choice, ''), ?),
+		COUNT(*)
+	FROM guests
+	WHERE deleted_at IS NULL AND form_started AND attendance
+	GROUP BY 1
+	ORDER BY 2 DESC, 1`
+
+	rows, err := i.db.Query(query, noMealChoice)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count meal choices: %v", err)
+	}
+	defer rows.Close()
+
+	var counts []models.Count
+	for rows.Next() {
+		var count models.Count
+		if err := rows.Scan(&count.Label, &count.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, rows.Err()
+}
+
+// getDailyResponses dates each guest's response by the first of their
+// attendance events or visits to the accepted and declined pages, as guests
+// who responded before events were recorded only have the latter.
+func (i GuestStore) getDailyResponses() ([]models.DailyCount, error) {
+	query := `WITH responses AS (
+		SELECT guest_id, MIN(responded_at) AS responded_at FROM (
+			SELECT COALESCE(guests.merged_into, guest_events.guest_id), guest_events.created_at AS responded_at
+			FROM guest_events
+			LEFT JOIN guests ON guests.id = guest_events.guest_id
+			WHERE guest_events.event_type = ? AND guest_events.field = 'attendance'
+			UNION ALL
+			SELECT id, first_visit_time
+			FROM page_visits
+			WHERE page_name IN ('guest-accepted', 'guest-declined')
+		)
+		GROUP BY guest_id
+	)
+	SELECT date(responses.responded_at), COUNT(*)
+	FROM responses
+	JOIN guests ON guests.id = responses.guest_id
+	WHERE guests.deleted_at IS NULL AND guests.form_started
+	GROUP BY 1
+	ORDER BY 1`
+
+	rows, err := i.db.Query(query, models.GuestEventUpdated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count daily responses: %v", err)
+	}
+	defer rows.Close()
+
+	var counts []models.DailyCount
+	total := 0
+	for rows.Next() {
+		var count models.DailyCount
+		if err := rows.Scan(&count.Date, &count.Count); err != nil {
+			return nil, err
+		}
+		total += count.Count
+		count.Count = total
+		counts = append(counts, count)
+	}
+
+	return counts, rows.Err()
+}
+
+func (i GuestStore) getDailyVisitors() ([]models.DailyVisitors, error) {
+	query := `SELECT
+		date(created_at),
+		COUNT(DISTINCT CASE WHEN page_name = 'index' THEN visitor_id END),
+		COUNT(DISTINCT CASE WHEN page_name = 'invalid-guest' THEN visitor_id END)
+	FROM visit_events
+	WHERE visitor_id IS NOT NULL
+	GROUP BY 1
+	ORDER BY 1`
+
+	rows, err := i.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count daily visitors: %v", err)
+	}
+	defer rows.Close()
+
+	var days []models.DailyVisitors
+	for rows.Next() {
+		var day models.DailyVisitors
+		if err := rows.Scan(&day.Date, &day.Landing, &day.InvalidCode); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+
+	return days, rows.Err()
+}
@@ -0,0 +1,197 @@
+// Package funnel works out how far each guest has got through the RSVP flow
+// from their visits and their current state.
+package funnel
+
+import (
+	"slices"
+	"time"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+// The steps of the RSVP flow in order. Guests who decline finish after
+// submitting their attendance and skip the details steps, and only guests
+// whose details were rejected pass through stepInvalidDetails.
+const (
+	stepInvited = iota
+	stepLanding
+	stepCodeEntered
+	stepAttendance
+	stepDetailsViewed
+	stepInvalidDetails
+	stepCompleted
+	stepCount
+)
+
+// Steps names each step in the report.
+var Steps = [stepCount]string{
+	"invited",
+	"landing page",
+	"code entered",
+	"attendance submitted",
+	"details form viewed",
+	"invalid details",
+	"completed",
+}
+
+type Step struct {
+	Name    string `json:"name"`
+	Reached int    `json:"reached"`
+	// MedianSeconds is the median time guests took to get here from the
+	// previous step they reached, when both times are known
+	MedianSeconds *int64            `json:"median_seconds,omitempty"`
+	Stuck         []models.GuestRef `json:"stuck"`
+}
+
+type Report struct {
+	Steps []Step `json:"steps"`
+}
+
+// progress records which steps a guest reached and, where it is known, when.
+// Visits from before the visit log existed have no times, so a step can be
+// reached without a time.
+type progress struct {
+	reached [stepCount]bool
+	times   [stepCount]time.Time
+}
+
+func (p *progress) reach(step int, at time.Time) {
+	p.reached[step] = true
+	if !at.IsZero() && (p.times[step].IsZero() || at.Before(p.times[step])) {
+		p.times[step] = at
+	}
+}
+
+func parseTime(s string) time.Time {
+	t, err := time.ParseInLocation(time.DateTime, s, time.UTC)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// Build reports how many of guests reached each step, how long it took them
+// and who is stuck where. visits and attendance are every recorded page view
+// and attendance change.
+func Build(guests []models.Guest, visits []models.Visit, attendance []models.GuestEvent) Report {
+	progresses := make(map[int]*progress, len(guests))
+	for _, guest := range guests {
+		p := &progress{}
+		p.reach(stepInvited, time.Time{})
+		progresses[guest.ID] = p
+	}
+
+	for _, visit := range visits {
+		p, ok := progresses[visit.GuestID]
+		if !ok {
+			continue
+		}
+		at := parseTime(visit.CreatedAt)
+
+		p.reach(stepLanding, at)
+		// Every page but the landing page needs the guest's code
+		if visit.Page != "index" {
+			p.reach(stepCodeEntered, at)
+		}
+
+		switch visit.Page {
+		case "guest-details", "change-details":
+			p.reach(stepDetailsViewed, at)
+		case "invalid-details":
+			p.reach(stepDetailsViewed, at)
+			p.reach(stepInvalidDetails, at)
+		}
+	}
+
+	for _, event := range attendance {
+		if p, ok := progresses[event.GuestID]; ok {
+			p.reach(stepAttendance, parseTime(event.CreatedAt))
+		}
+	}
+
+	for _, guest := range guests {
+		p := progresses[guest.ID]
+		if !guest.FormStarted {
+			continue
+		}
+
+		p.reach(stepLanding, time.Time{})
+		p.reach(stepCodeEntered, time.Time{})
+		p.reach(stepAttendance, time.Time{})
+
+		if !guest.Attendance {
+			p.reached[stepCompleted] = guest.FormCompleted
+			continue
+		}
+
+		if guest.DetailsProvided || guest.InvalidDetails {
+			p.reach(stepDetailsViewed, time.Time{})
+		}
+		if guest.InvalidDetails {
+			p.reach(stepInvalidDetails, time.Time{})
+		}
+		if guest.FormCompleted && guest.DetailsProvided {
+			p.reach(stepCompleted, completedAt(guest.ID, visits))
+		}
+	}
+
+	report := Report{Steps: make([]Step, len(Steps))}
+	var durations [stepCount][]time.Duration
+	for idx, name := range Steps {
+		report.Steps[idx] = Step{Name: name, Stuck: []models.GuestRef{}}
+	}
+
+	for _, guest := range guests {
+		p := progresses[guest.ID]
+
+		furthest, previous := 0, 0
+		for step := range Steps {
+			if !p.reached[step] {
+				continue
+			}
+			report.Steps[step].Reached++
+			furthest = step
+
+			if step > 0 && !p.times[step].IsZero() && !p.times[previous].IsZero() {
+				durations[step] = append(durations[step], p.times[step].Sub(p.times[previous]))
+			}
+			previous = step
+		}
+
+		if !p.reached[stepCompleted] {
+			report.Steps[furthest].Stuck = append(report.Steps[furthest].Stuck, models.GuestRef{Code: guest.Code, Name: guest.Name})
+		}
+	}
+
+	for step, d := range durations {
+		if len(d) == 0 {
+			continue
+		}
+		median := int64(median(d).Seconds())
+		report.Steps[step].MedianSeconds = &median
+	}
+
+	return report
+}
+
+// completedAt is when the guest first saw the accepted page, which is shown
+// once their details are saved.
+func completedAt(guestID int, visits []models.Visit) time.Time {
+	for _, visit := range visits {
+		if visit.GuestID == guestID && visit.Page == "guest-accepted" {
+			return parseTime(visit.CreatedAt)
+		}
+	}
+	return time.Time{}
+}
+
+func median(durations []time.Duration) time.Duration {
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
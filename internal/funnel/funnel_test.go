@@ -0,0 +1,150 @@
+package funnel
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+var start = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func at(seconds int) string {
+	return start.Add(time.Duration(seconds) * time.Second).Format(time.DateTime)
+}
+
+func visit(guestID int, page string, seconds int) models.Visit {
+	return models.Visit{GuestID: guestID, Page: page, CreatedAt: at(seconds)}
+}
+
+func seconds(s int64) *int64 {
+	return &s
+}
+
+func TestBuild(t *testing.T) {
+	guests := []models.Guest{
+		// accepted and gave their details
+		{ID: 1, Code: "Alice-000001", Name: "Alice", FormStarted: true, Attendance: true, DetailsProvided: true, FormCompleted: true},
+		// declined
+		{ID: 2, Code: "Bob-000002", Name: "Bob", FormStarted: true, FormCompleted: true},
+		// accepted but their details were rejected
+		{ID: 3, Code: "Carol-000003", Name: "Carol", FormStarted: true, Attendance: true, InvalidDetails: true},
+		// completed before the visit log existed, so has no visits or times
+		{ID: 4, Code: "Dan-000004", Name: "Dan", FormStarted: true, Attendance: true, DetailsProvided: true, FormCompleted: true},
+		{ID: 5, Code: "Eve-000005", Name: "Eve"},
+		{ID: 6, Code: "Fay-000006", Name: "Fay"},
+		// responded before attendance changes were recorded
+		{ID: 7, Code: "Gus-000007", Name: "Gus", FormStarted: true, Attendance: true},
+	}
+	visits := []models.Visit{
+		visit(1, "index", 0),
+		visit(0, "index", 5),
+		visit(1, "rsvp", 10),
+		visit(1, "guest-details", 40),
+		visit(1, "guest-accepted", 100),
+		visit(1, "guest-accepted", 500),
+
+		visit(2, "index", 0),
+		visit(2, "rsvp", 30),
+		visit(2, "guest-declined", 61),
+
+		visit(3, "index", 0),
+		visit(3, "rsvp", 20),
+		visit(3, "invalid-details", 100),
+
+		visit(6, "index", 0),
+
+		visit(7, "index", 0),
+		visit(7, "rsvp", 10),
+		visit(7, "guest-details", 30),
+
+		// a guest who has since been deleted
+		visit(99, "rsvp", 0),
+	}
+	attendance := []models.GuestEvent{
+		{GuestID: 1, Field: "attendance", CreatedAt: at(20)},
+		{GuestID: 2, Field: "attendance", CreatedAt: at(60)},
+		{GuestID: 3, Field: "attendance", CreatedAt: at(40)},
+		{GuestID: 99, Field: "attendance", CreatedAt: at(10)},
+	}
+
+	report := Build(guests, visits, attendance)
+
+	for _, tt := range []struct {
+		step    int
+		reached int
+		// the median time from the previous step each guest reached, only
+		// counting guests with times for both
+		median *int64
+		stuck  []string
+	}{
+		{step: stepInvited, reached: 7, stuck: []string{"Eve-000005"}},
+		// nobody has a time for being invited
+		{step: stepLanding, reached: 6, stuck: []string{"Fay-000006"}},
+		// 10s, 30s, 20s and 10s
+		{step: stepCodeEntered, reached: 5, median: seconds(15)},
+		// 10s, 30s and 20s, as Gus has no time and Dan no times at all
+		{step: stepAttendance, reached: 5, median: seconds(20)},
+		// 20s and 60s, as Gus has no time for attendance and Bob declined
+		{step: stepDetailsViewed, reached: 4, median: seconds(40), stuck: []string{"Gus-000007"}},
+		{step: stepInvalidDetails, reached: 1, median: seconds(0), stuck: []string{"Carol-000003"}},
+		// Alice's first accepted page at 100s, 60s after the details form.
+		// Bob declined and Dan has no visits, so neither is timed
+		{step: stepCompleted, reached: 3, median: seconds(60)},
+	} {
+		t.Run(Steps[tt.step], func(t *testing.T) {
+			got := report.Steps[tt.step]
+			if got.Name != Steps[tt.step] {
+				t.Errorf("name = %q", got.Name)
+			}
+			if got.Reached != tt.reached {
+				t.Errorf("reached = %d, want %d", got.Reached, tt.reached)
+			}
+			switch {
+			case tt.median == nil && got.MedianSeconds != nil:
+				t.Errorf("median = %ds, want none", *got.MedianSeconds)
+			case tt.median != nil && got.MedianSeconds == nil:
+				t.Errorf("median = none, want %ds", *tt.median)
+			case tt.median != nil && *got.MedianSeconds != *tt.median:
+				t.Errorf("median = %ds, want %ds", *got.MedianSeconds, *tt.median)
+			}
+
+			var stuck []string
+			for _, guest := range got.Stuck {
+				stuck = append(stuck, guest.Code)
+			}
+			if !slices.Equal(stuck, tt.stuck) {
+				t.Errorf("stuck = %q, want %q", stuck, tt.stuck)
+			}
+		})
+	}
+}
+
+func TestBuildWithNoGuests(t *testing.T) {
+	report := Build(nil, []models.Visit{visit(1, "index", 0)}, nil)
+	if len(report.Steps) != len(Steps) {
+		t.Fatalf("got %d steps, want %d", len(report.Steps), len(Steps))
+	}
+	for _, step := range report.Steps {
+		if step.Reached != 0 || step.MedianSeconds != nil || step.Stuck == nil || len(step.Stuck) != 0 {
+			t.Errorf("step %+v, want it empty with an empty stuck list", step)
+		}
+	}
+}
+
+func TestMedian(t *testing.T) {
+	for _, tt := range []struct {
+		durations []time.Duration
+		want      time.Duration
+	}{
+		{[]time.Duration{5}, 5},
+		{[]time.Duration{9, 1, 5}, 5},
+		{[]time.Duration{9, 1, 5, 3}, 4},
+		{[]time.Duration{2, 2}, 2},
+	} {
+		if got := median(tt.durations); got != tt.want {
+			t.Errorf("median(%v) = %v, want %v", tt.durations, got, tt.want)
+		}
+	}
+}
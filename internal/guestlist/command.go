@@ -0,0 +1,87 @@
+package guestlist
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+// RunCommand imports records, the guest list csv, printing what changed.
+// With -dry-run the changes are only printed.
+func RunCommand(store Store, records [][]string, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("import-guests", flag.ContinueOnError)
+	flags.SetOutput(out)
+	dryRun := flags.Bool("dry-run", false, "print the changes without making them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	plan, err := Import(store, records, *dryRun, models.ActorAdmin)
+	var validationErrs ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fmt.Fprintf(out, "Found %d problems:\n", len(validationErrs))
+		for _, e := range validationErrs {
+			fmt.Fprintf(out, "  %s\n", e.Error())
+		}
+		return fmt.Errorf("guest list is invalid")
+	}
+	if err != nil {
+		return err
+	}
+
+	PrintPlan(out, plan)
+
+	if *dryRun {
+		fmt.Fprintln(out, "\nNothing has been changed. Run again without -dry-run to import the guest list.")
+		return nil
+	}
+	fmt.Fprintln(out, "\nGuest list imported.")
+	return nil
+}
+
+// PrintPlan writes out every new, changed, missing and deleted guest in plan.
+func PrintPlan(out io.Writer, plan Plan) {
+	fmt.Fprintf(out, "%d new, %d changed, %d unchanged, %d missing, %d deleted\n",
+		len(plan.New), len(plan.Changed), plan.Unchanged, len(plan.Missing), len(plan.Deleted))
+
+	printRefs := func(title string, refs []GuestRef) {
+		if len(refs) == 0 {
+			return
+		}
+		fmt.Fprintf(out, "\n%s:\n", title)
+		for _, ref := range refs {
+			fmt.Fprintf(out, "  %s\n", describeRef(ref))
+		}
+	}
+
+	printRefs("New", plan.New)
+	if len(plan.Changed) > 0 {
+		fmt.Fprintf(out, "\nChanged:\n")
+		for _, c := range plan.Changed {
+			fmt.Fprintf(out, "  %s\n", describeRef(c.GuestRef))
+			for _, f := range c.Fields {
+				fmt.Fprintf(out, "    %s: %q -> %q\n", f.Field, f.Old, f.New)
+			}
+		}
+	}
+	printRefs("Missing from the csv, left as they are", plan.Missing)
+	printRefs("Deleted, left deleted", plan.Deleted)
+}
+
+func describeRef(ref GuestRef) string {
+	var details []string
+	if ref.ExternalID != "" {
+		details = append(details, "id "+ref.ExternalID)
+	}
+	if ref.Code != "" {
+		details = append(details, ref.Code)
+	}
+	if len(details) == 0 {
+		return ref.Name
+	}
+	return fmt.Sprintf("%s (%s)", ref.Name, strings.Join(details, ", "))
+}
@@ -0,0 +1,181 @@
+package guestlist
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+type GuestRef struct {
+	ExternalID string `json:"external_id,omitempty"`
+	Name       string `json:"name"`
+	Code       string `json:"code,omitempty"`
+}
+
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+type Change struct {
+	GuestRef
+	Fields []FieldChange `json:"fields"`
+
+	guest models.Guest
+}
+
+// Plan describes what importing a guest list would do to the database.
+// Missing guests are only reported, they are never removed by an import.
+// Rows matching guests that have been deleted are listed in Deleted and left
+// alone, so an import never brings a deleted guest back.
+type Plan struct {
+	New       []GuestRef `json:"new"`
+	Changed   []Change   `json:"changed"`
+	Missing   []GuestRef `json:"missing"`
+	Deleted   []GuestRef `json:"deleted"`
+	Unchanged int        `json:"unchanged"`
+
+	newGuests []models.Guest
+}
+
+func (p Plan) NewGuests() []models.Guest {
+	return p.newGuests
+}
+
+// Changes lists every changed field of the updated guests so the import can
+// be recorded in their history.
+func (p Plan) Changes() []models.GuestEvent {
+	var changes []models.GuestEvent
+	for _, c := range p.Changed {
+		for _, f := range c.Fields {
+			changes = append(changes, models.GuestEvent{
+				GuestID:  c.guest.ID,
+				Field:    f.Field,
+				OldValue: f.Old,
+				NewValue: f.New,
+			})
+		}
+	}
+	return changes
+}
+
+func (p Plan) UpdatedGuests() []models.Guest {
+	guests := make([]models.Guest, len(p.Changed))
+	for idx, c := range p.Changed {
+		guests[idx] = c.guest
+	}
+	return guests
+}
+
+// Diff compares the imported rows against the existing guests. Rows are
+// matched on their external id. Guests created before external ids existed
+// are matched on their name instead and adopt the id from the csv.
+//
+// Blank cells never clear a value that is already stored, and the email,
+// phone and address, which guests can enter themselves, are only filled in
+// where they are blank. Details guests have given survive re-importing a list,
+// even one holding older details for them.
+func Diff(rows []Row, existing []models.Guest) Plan {
+	plan := Plan{New: []GuestRef{}, Changed: []Change{}, Missing: []GuestRef{}, Deleted: []GuestRef{}}
+
+	byExternalID := make(map[string]int)
+	byName := make(map[string][]int)
+	for idx, g := range existing {
+		if g.ExternalID != "" {
+			byExternalID[g.ExternalID] = idx
+		} else {
+			byName[g.Name] = append(byName[g.Name], idx)
+		}
+	}
+
+	matched := make(map[int]bool)
+	for _, row := range rows {
+		idx, ok := byExternalID[row.Guest.ExternalID]
+		if !ok {
+			// Only adopt a legacy guest when the name is unambiguous
+			if candidates := byName[row.Guest.Name]; len(candidates) == 1 && !matched[candidates[0]] {
+				idx, ok = candidates[0], true
+			}
+		}
+
+		if !ok {
+			plan.New = append(plan.New, GuestRef{ExternalID: row.Guest.ExternalID, Name: row.Guest.Name})
+			plan.newGuests = append(plan.newGuests, row.Guest)
+			continue
+		}
+
+		matched[idx] = true
+		if existing[idx].Deleted {
+			plan.Deleted = append(plan.Deleted, GuestRef{ExternalID: existing[idx].ExternalID, Name: existing[idx].Name, Code: existing[idx].Code})
+			continue
+		}
+
+		merged, fields := merge(existing[idx], row)
+		if len(fields) == 0 {
+			plan.Unchanged++
+			continue
+		}
+		plan.Changed = append(plan.Changed, Change{
+			GuestRef: GuestRef{ExternalID: merged.ExternalID, Name: merged.Name, Code: merged.Code},
+			Fields:   fields,
+			guest:    merged,
+		})
+	}
+
+	for idx, g := range existing {
+		if !matched[idx] && !g.Deleted {
+			plan.Missing = append(plan.Missing, GuestRef{ExternalID: g.ExternalID, Name: g.Name, Code: g.Code})
+		}
+	}
+
+	return plan
+}
+
+// merge applies the non-blank values of row onto g and lists what changed.
+// Details guests can enter themselves are only filled in, never replaced.
+func merge(g models.Guest, row Row) (models.Guest, []FieldChange) {
+	var fields []FieldChange
+	set := func(field string, current *string, value string) {
+		if value != "" && value != *current {
+			fields = append(fields, FieldChange{Field: field, Old: *current, New: value})
+			*current = value
+		}
+	}
+	fill := func(field string, current *string, value string) {
+		if *current == "" {
+			set(field, current, value)
+		}
+	}
+	setList := func(field string, current *[]string, value []string) {
+		oldValue, newValue := JoinList(*current), JoinList(value)
+		if len(value) > 0 && oldValue != newValue {
+			fields = append(fields, FieldChange{Field: field, Old: oldValue, New: newValue})
+			*current = value
+		}
+	}
+
+	imported := row.Guest
+	set("external_id", &g.ExternalID, imported.ExternalID)
+	set("name", &g.Name, imported.Name)
+	fill("email", &g.Email, imported.Email)
+	fill("phone", &g.PhoneNumber, imported.PhoneNumber)
+	set("household", &g.Household, imported.Household)
+	if row.plusOnesSet && imported.PlusOnes != g.PlusOnes {
+		fields = append(fields, FieldChange{Field: "plus_ones", Old: strconv.Itoa(g.PlusOnes), New: strconv.Itoa(imported.PlusOnes)})
+		g.PlusOnes = imported.PlusOnes
+	}
+	setList("events", &g.Events, imported.Events)
+	setList("tags", &g.Tags, imported.Tags)
+	if !imported.Address.IsEmpty() && g.Address.IsEmpty() {
+		fields = append(fields, FieldChange{
+			Field: "address",
+			Old:   strings.Join(g.Address.Lines(), ", "),
+			New:   strings.Join(imported.Address.Lines(), ", "),
+		})
+		g.Address = imported.Address
+	}
+
+	return g, fields
+}
@@ -0,0 +1,33 @@
+package guestlist
+
+import (
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+type Store interface {
+	GetAllGuests(includeDeleted bool) ([]models.Guest, error)
+	ApplyGuestImport(newGuests, updatedGuests []models.Guest, changes []models.GuestEvent, actor string) error
+}
+
+// Import validates records and works out the changes needed to bring the
+// store in line with them. Unless dryRun is set the changes are then applied
+// and recorded against actor. Importing the same records twice makes no
+// further changes.
+func Import(store Store, records [][]string, dryRun bool, actor string) (Plan, error) {
+	rows, err := Parse(records)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	existing, err := store.GetAllGuests(true)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	plan := Diff(rows, existing)
+	if dryRun || (len(plan.New) == 0 && len(plan.Changed) == 0) {
+		return plan, nil
+	}
+
+	return plan, store.ApplyGuestImport(plan.NewGuests(), plan.UpdatedGuests(), plan.Changes(), actor)
+}
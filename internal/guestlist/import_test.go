@@ -0,0 +1,258 @@
+package guestlist_test
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/database"
+	"github.com/nesquikmike/wedding-rsvps/internal/guestlist"
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+var header = []string{"id", "name", "email", "phone", "household", "plus_ones", "events", "tags", "address_line_1", "address_line_2", "city", "county", "postcode", "country"}
+
+// newTestStore returns a store backed by an empty in-memory database.
+func newTestStore(t *testing.T) database.GuestStore {
+	t.Helper()
+
+	db, err := sql.Open(database.DriverName, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to :memory: is a separate database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	store := database.NewGuestStore(db)
+	if err := store.SetupDatabase(nil); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func mustImport(t *testing.T, store database.GuestStore, records [][]string, dryRun bool) guestlist.Plan {
+	t.Helper()
+	plan, err := guestlist.Import(store, records, dryRun, models.ActorAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plan
+}
+
+func guestsByID(t *testing.T, store database.GuestStore) map[string]models.Guest {
+	t.Helper()
+	guests, err := store.GetAllGuests(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := make(map[string]models.Guest)
+	for _, g := range guests {
+		byID[g.ExternalID] = g
+	}
+	return byID
+}
+
+func TestParseHeader(t *testing.T) {
+	rows, err := guestlist.Parse([][]string{
+		{"\ufeffExternal ID", "Name", "Phone Number", "plus-one-allowance"},
+		{"g1", "  Alice   Smith ", "07700 900000", "2"},
+		{"", "", "", ""},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows, want the blank row skipped", len(rows))
+	}
+	g := rows[0].Guest
+	if g.ExternalID != "g1" || g.Name != "Alice Smith" || g.PhoneNumber != "07700900000" || g.PlusOnes != 2 {
+		t.Errorf("parsed %+v", g)
+	}
+}
+
+func TestParseValidationErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		records [][]string
+		want    []guestlist.ValidationError
+	}{
+		{
+			name:    "no header",
+			records: [][]string{{"Alice Smith"}},
+			want:    []guestlist.ValidationError{{Line: 1, Message: "missing header row"}},
+		},
+		{
+			name:    "bad header",
+			records: [][]string{{"id", "name", "nickname", "phone", "Phone Number"}},
+			want: []guestlist.ValidationError{
+				{Line: 1, Column: "nickname", Message: "unknown column"},
+				{Line: 1, Column: "Phone Number", Message: "duplicate column"},
+			},
+		},
+		{
+			name:    "missing required column",
+			records: [][]string{{"name", "email"}},
+			want:    []guestlist.ValidationError{{Line: 1, Column: "id", Message: "required column is missing"}},
+		},
+		{
+			name: "bad rows",
+			records: [][]string{
+				header,
+				{"g1", "Alice Smith", "not an email", "call me", "", "-1"},
+				{"g1", "", "", "", "", "lots"},
+				{"", "Carol Jones", "", "", "", "", "", "", "1 Rose Lane", "", "", "", "nowhere"},
+			},
+			want: []guestlist.ValidationError{
+				{Line: 2, Column: "email", Message: "is not a valid email address"},
+				{Line: 2, Column: "phone", Message: "is not a valid phone number"},
+				{Line: 2, Column: "plus_ones", Message: "must be a whole number of 0 or more"},
+				{Line: 3, Column: "id", Message: "duplicates line 2"},
+				{Line: 3, Column: "name", Message: "is required"},
+				{Line: 3, Column: "plus_ones", Message: "must be a whole number of 0 or more"},
+				{Line: 4, Column: "id", Message: "is required"},
+				{Line: 4, Column: "address", Message: "needs at least address line 1, city and a valid postcode"},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := guestlist.Parse(tt.records)
+			var errs guestlist.ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("got %v, want ValidationErrors", err)
+			}
+			if !slices.Equal(errs, tt.want) {
+				t.Errorf("got  %v\nwant %v", errs, tt.want)
+			}
+		})
+	}
+}
+
+func TestListsRoundTrip(t *testing.T) {
+	for _, tt := range []struct {
+		cell string
+		want []string
+	}{
+		{"", nil},
+		{"ceremony", []string{"ceremony"}},
+		{" ceremony ; reception;;evening ", []string{"ceremony", "reception", "evening"}},
+	} {
+		items := guestlist.SplitList(tt.cell)
+		if !slices.Equal(items, tt.want) {
+			t.Errorf("SplitList(%q) = %q, want %q", tt.cell, items, tt.want)
+		}
+		if again := guestlist.SplitList(guestlist.JoinList(items)); !slices.Equal(again, items) {
+			t.Errorf("%q came back from JoinList as %q", items, again)
+		}
+	}
+
+	// and through the database
+	store := newTestStore(t)
+	mustImport(t, store, [][]string{
+		{"id", "name", "events", "tags"},
+		{"g1", "Alice Smith", "ceremony; reception", "university friends;family"},
+	}, false)
+	g := guestsByID(t, store)["g1"]
+	if !slices.Equal(g.Events, []string{"ceremony", "reception"}) || !slices.Equal(g.Tags, []string{"university friends", "family"}) {
+		t.Errorf("stored events %q and tags %q", g.Events, g.Tags)
+	}
+}
+
+func TestImportTwiceMakesNoChanges(t *testing.T) {
+	store := newTestStore(t)
+	records := [][]string{
+		header,
+		{"g1", "Alice Smith", "alice@example.com", "07700900000", "smiths", "1", "ceremony;reception", "family", "1 Rose Lane", "", "Bath", "", "BA1 1AA", "UK"},
+		{"g2", "Bob Smith", "", "", "smiths", "0"},
+	}
+
+	plan := mustImport(t, store, records, false)
+	if len(plan.New) != 2 {
+		t.Fatalf("first import added %d guests, want 2", len(plan.New))
+	}
+
+	plan = mustImport(t, store, records, false)
+	if len(plan.New) != 0 || len(plan.Changed) != 0 || plan.Unchanged != 2 {
+		t.Errorf("second import planned %+v, want nothing to change", plan)
+	}
+	if guests := guestsByID(t, store); len(guests) != 2 {
+		t.Errorf("%d guests after importing twice, want 2", len(guests))
+	}
+}
+
+func TestImportDryRunWritesNothing(t *testing.T) {
+	store := newTestStore(t)
+	plan := mustImport(t, store, [][]string{{"id", "name"}, {"g1", "Alice Smith"}}, true)
+	if len(plan.New) != 1 {
+		t.Errorf("dry run planned %d new guests, want 1", len(plan.New))
+	}
+	if guests := guestsByID(t, store); len(guests) != 0 {
+		t.Fatalf("dry run added %d guests", len(guests))
+	}
+
+	mustImport(t, store, [][]string{{"id", "name"}, {"g1", "Alice Smith"}}, false)
+	plan = mustImport(t, store, [][]string{{"id", "name", "household"}, {"g1", "Alice Jones", "joneses"}}, true)
+	if len(plan.Changed) != 1 {
+		t.Errorf("dry run planned %d changes, want 1", len(plan.Changed))
+	}
+	if g := guestsByID(t, store)["g1"]; g.Name != "Alice Smith" || g.Household != "" {
+		t.Errorf("dry run changed the guest to %+v", g)
+	}
+}
+
+func TestImportNeverReplacesGuestDetails(t *testing.T) {
+	store := newTestStore(t)
+	mustImport(t, store, [][]string{
+		header,
+		{"g1", "Alice Smith", "alice@example.com", "07700900000", "", "", "", "", "1 Rose Lane", "", "Bath", "", "BA1 1AA", "UK"},
+		{"g2", "Bob Smith"},
+	}, false)
+
+	// Alice gives new details herself, Bob gives none
+	alice := guestsByID(t, store)["g1"]
+	newAddress := models.Address{Line1: "2 Oak Road", City: "Bristol", Postcode: "BS1 1AA", Country: "UK"}
+	if err := store.UpdateGuestEmail(alice.Code, "alice@new.example.com", models.ActorGuest); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpdateGuestPhoneNumber(alice.Code, "07700900111", models.ActorGuest); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpdateGuestAddress(alice.Code, newAddress, models.ActorGuest); err != nil {
+		t.Fatal(err)
+	}
+
+	// the old csv is imported again with a new household for each
+	plan := mustImport(t, store, [][]string{
+		header,
+		{"g1", "Alice Smith", "alice@example.com", "07700900000", "smiths", "", "", "", "1 Rose Lane", "", "Bath", "", "BA1 1AA", "UK"},
+		{"g2", "Bob Smith", "bob@example.com", "07700900222", "smiths", "", "", "", "3 Elm Street", "", "Leeds", "", "LS1 1AA", "UK"},
+	}, false)
+	if len(plan.Changed) != 2 {
+		t.Fatalf("planned %d changes, want 2", len(plan.Changed))
+	}
+
+	guests := guestsByID(t, store)
+	alice = guests["g1"]
+	if alice.Email != "alice@new.example.com" || alice.PhoneNumber != "07700900111" || alice.Address != newAddress {
+		t.Errorf("import replaced details Alice gave: %+v", alice)
+	}
+	if alice.Household != "smiths" {
+		t.Errorf("Alice's household = %q, want it updated", alice.Household)
+	}
+
+	bob := guests["g2"]
+	if bob.Email != "bob@example.com" || bob.PhoneNumber != "07700900222" || bob.Address.Line1 != "3 Elm Street" {
+		t.Errorf("import didn't fill in Bob's blank details: %+v", bob)
+	}
+
+	events, err := store.GetGuestEvents(alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range events {
+		if event.Actor == models.ActorAdmin && (event.Field == "email" || event.Field == "phone" || event.Field == "address") && event.OldValue != "" {
+			t.Errorf("import recorded replacing Alice's %s: %+v", event.Field, event)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package guestlist
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+	"github.com/nesquikmike/wedding-rsvps/internal/validate"
+)
+
+// ListSeparator separates multiple events or tags within a single csv cell,
+// and in the single column each is stored in.
+const ListSeparator = ";"
+
+// columnAliases maps the accepted header spellings onto a column name.
+var columnAliases = map[string]string{
+	"id":                 "id",
+	"external_id":        "id",
+	"name":               "name",
+	"email":              "email",
+	"phone":              "phone",
+	"phone_number":       "phone",
+	"household":          "household",
+	"plus_ones":          "plus_ones",
+	"plus_one_allowance": "plus_ones",
+	"events":             "events",
+	"tags":               "tags",
+	"address_line_1":     "address_line_1",
+	"address_line_2":     "address_line_2",
+	"city":               "city",
+	"county":             "county",
+	"postcode":           "postcode",
+	"country":            "country",
+}
+
+// Row is a single validated guest from the csv.
+type Row struct {
+	Line  int
+	Guest models.Guest
+
+	plusOnesSet bool
+}
+
+type ValidationError struct {
+	Line    int    `json:"line"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+	return fmt.Sprintf("line %d, column %s: %s", e.Line, e.Column, e.Message)
+}
+
+// ValidationErrors holds every problem found in the csv so that they can all
+// be fixed at once rather than one import attempt at a time.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for idx, err := range e {
+		msgs[idx] = err.Error()
+	}
+	return fmt.Sprintf("%d invalid rows in guest list: %s", len(e), strings.Join(msgs, "; "))
+}
+
+func normaliseHeader(h string) string {
+	h = strings.ToLower(strings.TrimSpace(h))
+	h = strings.TrimPrefix(h, "\ufeff")
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(h)
+}
+
+// HasHeader reports whether records start with a header row. Guest lists
+// without one are treated as the original format of one name per row.
+func HasHeader(records [][]string) bool {
+	if len(records) == 0 || len(records[0]) == 0 {
+		return false
+	}
+	column, ok := columnAliases[normaliseHeader(records[0][0])]
+	return ok && (column == "id" || column == "name")
+}
+
+// Parse validates records, including the header row, and returns the guests
+// in them. All validation problems are returned together as ValidationErrors.
+func Parse(records [][]string) ([]Row, error) {
+	if !HasHeader(records) {
+		return nil, ValidationErrors{{Line: 1, Message: "missing header row"}}
+	}
+
+	var errs ValidationErrors
+
+	columns := make(map[string]int)
+	for idx, h := range records[0] {
+		column, ok := columnAliases[normaliseHeader(h)]
+		if !ok {
+			errs = append(errs, ValidationError{Line: 1, Column: h, Message: "unknown column"})
+			continue
+		}
+		if _, ok := columns[column]; ok {
+			errs = append(errs, ValidationError{Line: 1, Column: h, Message: "duplicate column"})
+			continue
+		}
+		columns[column] = idx
+	}
+	for _, required := range []string{"id", "name"} {
+		if _, ok := columns[required]; !ok {
+			errs = append(errs, ValidationError{Line: 1, Column: required, Message: "required column is missing"})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	var rows []Row
+	seenIDs := make(map[string]int)
+
+	for idx, record := range records[1:] {
+		line := idx + 2
+		value := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		if strings.Join(record, "") == "" {
+			continue
+		}
+
+		row := Row{Line: line}
+		g := &row.Guest
+
+		g.ExternalID = value("id")
+		if g.ExternalID == "" {
+			errs = append(errs, ValidationError{Line: line, Column: "id", Message: "is required"})
+		} else if firstLine, ok := seenIDs[g.ExternalID]; ok {
+			errs = append(errs, ValidationError{Line: line, Column: "id", Message: fmt.Sprintf("duplicates line %d", firstLine)})
+		} else {
+			seenIDs[g.ExternalID] = line
+		}
+
+		g.Name = strings.Join(strings.Fields(value("name")), " ")
+		if g.Name == "" {
+			errs = append(errs, ValidationError{Line: line, Column: "name", Message: "is required"})
+		}
+
+		g.Email = value("email")
+		if g.Email != "" && !validate.Email(g.Email) {
+			errs = append(errs, ValidationError{Line: line, Column: "email", Message: "is not a valid email address"})
+		}
+
+		g.PhoneNumber = strings.ReplaceAll(value("phone"), " ", "")
+		if g.PhoneNumber != "" && !validate.PhoneNumber(g.PhoneNumber) {
+			errs = append(errs, ValidationError{Line: line, Column: "phone", Message: "is not a valid phone number"})
+		}
+
+		g.Household = value("household")
+
+		if plusOnes := value("plus_ones"); plusOnes != "" {
+			n, err := strconv.Atoi(plusOnes)
+			if err != nil || n < 0 {
+				errs = append(errs, ValidationError{Line: line, Column: "plus_ones", Message: "must be a whole number of 0 or more"})
+			}
+			g.PlusOnes = n
+			row.plusOnesSet = true
+		}
+
+		g.Events = SplitList(value("events"))
+		g.Tags = SplitList(value("tags"))
+
+		g.Address = models.Address{
+			Line1:    value("address_line_1"),
+			Line2:    value("address_line_2"),
+			City:     value("city"),
+			County:   value("county"),
+			Postcode: strings.ToUpper(value("postcode")),
+			Country:  value("country"),
+		}
+		if !validate.Address(g.Address) {
+			errs = append(errs, ValidationError{Line: line, Column: "address", Message: "needs at least address line 1, city and a valid postcode"})
+		}
+
+		rows = append(rows, row)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return rows, nil
+}
+
+// SplitList reads a list of events or tags written as one value, either in the
+// csv or where the list is stored in the database.
+func SplitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ListSeparator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// JoinList writes items as one value which SplitList reads back.
+func JoinList(items []string) string {
+	return strings.Join(items, ListSeparator)
+}
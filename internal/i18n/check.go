@@ -0,0 +1,132 @@
+package i18n
+
+import (
+	"fmt"
+	"html/template"
+	"slices"
+	"sort"
+	"text/template/parse"
+)
+
+// Check compares every catalogue with the default one and returns a problem
+// for each message missing from a catalogue, each one it has which the default
+// doesn't, and each translation whose {placeholders} differ from the
+// default's, so nobody sees a key where a message should be.
+func Check() []string {
+	var problems []string
+	reference := catalogues[DefaultLanguage]
+	if reference == nil {
+		return []string{fmt.Sprintf("there is no catalogue for the default language %s", DefaultLanguage)}
+	}
+
+	for _, c := range Languages() {
+		if c.Name == "" || c.Locale == "" {
+			problems = append(problems, fmt.Sprintf("%s: name and locale must be set", c.Language))
+		}
+		if c.Date.Long == "" || len(c.Date.Weekdays) != 7 || len(c.Date.Months) != 12 {
+			problems = append(problems, fmt.Sprintf("%s: date needs a long format, 7 weekdays starting on Sunday and 12 months", c.Language))
+		}
+		if c == reference {
+			continue
+		}
+
+		for _, key := range sortedKeys(reference.Messages) {
+			message, ok := c.Messages[key]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: %s is missing", c.Language, key))
+				continue
+			}
+			want := placeholders(reference.Messages[key])
+			if got := placeholders(message); !slices.Equal(got, want) {
+				problems = append(problems, fmt.Sprintf("%s: %s uses %v when it should use %v", c.Language, key, got, want))
+			}
+		}
+		for _, key := range sortedKeys(c.Messages) {
+			if _, ok := reference.Messages[key]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: %s isn't in the %s catalogue", c.Language, key, DefaultLanguage))
+			}
+		}
+	}
+
+	return problems
+}
+
+// CheckTemplates returns a problem for every {{ t .Lang "key" }} in the
+// templates whose key isn't in the default catalogue.
+func CheckTemplates(tpl *template.Template) []string {
+	var problems []string
+	reference := catalogue(DefaultLanguage)
+	for _, t := range tpl.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		for _, key := range templateKeys(t.Tree.Root) {
+			if _, ok := reference.Messages[key]; !ok {
+				problems = append(problems, fmt.Sprintf("template %s: %s isn't in the %s catalogue", t.Name(), key, DefaultLanguage))
+			}
+		}
+	}
+	return problems
+}
+
+// templateKeys finds the keys passed to t anywhere under node.
+func templateKeys(node parse.Node) []string {
+	var keys []string
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			keys = append(keys, templateKeys(child)...)
+		}
+	case *parse.ActionNode:
+		keys = templateKeys(n.Pipe)
+	case *parse.IfNode:
+		keys = branchKeys(&n.BranchNode)
+	case *parse.RangeNode:
+		keys = branchKeys(&n.BranchNode)
+	case *parse.WithNode:
+		keys = branchKeys(&n.BranchNode)
+	case *parse.TemplateNode:
+		keys = templateKeys(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			if len(cmd.Args) >= 3 {
+				if ident, ok := cmd.Args[0].(*parse.IdentifierNode); ok && ident.Ident == "t" {
+					if key, ok := cmd.Args[2].(*parse.StringNode); ok {
+						keys = append(keys, key.Text)
+					}
+				}
+			}
+			for _, arg := range cmd.Args {
+				keys = append(keys, templateKeys(arg)...)
+			}
+		}
+	}
+	return keys
+}
+
+func branchKeys(n *parse.BranchNode) []string {
+	keys := templateKeys(n.Pipe)
+	keys = append(keys, templateKeys(n.List)...)
+	return append(keys, templateKeys(n.ElseList)...)
+}
+
+func placeholders(message string) []string {
+	found := placeholder.FindAllString(message, -1)
+	sort.Strings(found)
+	return slices.Compact(found)
+}
+
+func sortedKeys(messages map[string]string) []string {
+	var keys []string
+	for key := range messages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,262 @@
+// Package i18n holds the catalogues of text guests see, one per language, and
+// picks the language each guest is shown.
+package i18n
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// DefaultLanguage is shown when nothing better is known about a visitor, and
+// its catalogue is the one every other is checked against.
+const DefaultLanguage = "en"
+
+//go:embed catalogues/*.toml
+var catalogueFiles embed.FS
+
+// Catalogue is every message in one language, read from
+// catalogues/<language>.toml.
+type Catalogue struct {
+	// Language is the code the catalogue is chosen by, e.g. fr
+	Language string
+	// Name is the language's name in itself, shown in the switcher
+	Name string
+	// Locale is the language and region, e.g. fr_FR
+	Locale   string
+	Date     DateFormat
+	Messages map[string]string
+}
+
+// DateFormat writes dates in a language. Long uses {weekday}, {day},
+// {ordinal}, {month} and {year}.
+type DateFormat struct {
+	Long     string   `toml:"long"`
+	Weekdays []string `toml:"weekdays"`
+	Months   []string `toml:"months"`
+}
+
+type catalogueFile struct {
+	Name     string         `toml:"name"`
+	Locale   string         `toml:"locale"`
+	Date     DateFormat     `toml:"date"`
+	Messages map[string]any `toml:"messages"`
+}
+
+// ordinals write the day of the month as it is said, for languages which do
+// more than write the number.
+var ordinals = map[string]func(day int) string{
+	"en": englishOrdinal,
+	"fr": frenchOrdinal,
+}
+
+var catalogues = mustLoad()
+
+func mustLoad() map[string]*Catalogue {
+	c, err := load()
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func load() (map[string]*Catalogue, error) {
+	files, err := catalogueFiles.ReadDir("catalogues")
+	if err != nil {
+		return nil, err
+	}
+
+	c := make(map[string]*Catalogue)
+	for _, file := range files {
+		var f catalogueFile
+		if _, err := toml.DecodeFS(catalogueFiles, "catalogues/"+file.Name(), &f); err != nil {
+			return nil, fmt.Errorf("failed to read catalogue %s: %v", file.Name(), err)
+		}
+
+		language := strings.TrimSuffix(file.Name(), path.Ext(file.Name()))
+		messages := make(map[string]string)
+		if err := flatten("", f.Messages, messages); err != nil {
+			return nil, fmt.Errorf("catalogue %s: %v", file.Name(), err)
+		}
+		c[language] = &Catalogue{
+			Language: language,
+			Name:     f.Name,
+			Locale:   f.Locale,
+			Date:     f.Date,
+			Messages: messages,
+		}
+	}
+	return c, nil
+}
+
+// flatten turns the catalogue's tables into keys like details.email_label.
+func flatten(prefix string, table map[string]any, messages map[string]string) error {
+	for key, value := range table {
+		switch v := value.(type) {
+		case string:
+			messages[prefix+key] = v
+		case map[string]any:
+			if err := flatten(prefix+key+".", v, messages); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s%s must be text", prefix, key)
+		}
+	}
+	return nil
+}
+
+// Languages returns every catalogue, sorted by language code.
+func Languages() []*Catalogue {
+	var list []*Catalogue
+	for _, c := range catalogues {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Language < list[j].Language
+	})
+	return list
+}
+
+// Supported reports whether there is a catalogue for language.
+func Supported(language string) bool {
+	_, ok := catalogues[language]
+	return ok
+}
+
+func catalogue(language string) *Catalogue {
+	if c, ok := catalogues[language]; ok {
+		return c
+	}
+	return catalogues[DefaultLanguage]
+}
+
+// Match picks the language to show from an Accept-Language header, taking
+// the one the browser prefers most which there is a catalogue for, matched on
+// the language alone so en-GB picks en.
+func Match(acceptLanguage string) string {
+	best, bestQuality := DefaultLanguage, 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		language, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+
+		if Supported(language) && quality > bestQuality {
+			best, bestQuality = language, quality
+		}
+	}
+	return best
+}
+
+// T returns the message key in language, with each {name} in it replaced by
+// the value following name in args. Messages may hold HTML, so the values
+// are escaped unless they are already template.HTML.
+func T(language, key string, args ...any) template.HTML {
+	message, ok := catalogue(language).Messages[key]
+	if !ok {
+		// every key used by the templates is checked for on start up, so
+		// this is only reached by a key added without a message
+		return template.HTML(template.HTMLEscapeString(key))
+	}
+
+	for i := 0; i+1 < len(args); i += 2 {
+		var value string
+		switch v := args[i+1].(type) {
+		case template.HTML:
+			value = string(v)
+		default:
+			value = template.HTMLEscapeString(fmt.Sprint(v))
+		}
+		message = strings.ReplaceAll(message, "{"+fmt.Sprint(args[i])+"}", value)
+	}
+	return template.HTML(message)
+}
+
+// FormatDate writes t as a date in language, e.g. Saturday 1st March 2025.
+func FormatDate(language string, t time.Time) string {
+	c := catalogue(language)
+	ordinal := strconv.Itoa(t.Day())
+	if write, ok := ordinals[c.Language]; ok {
+		ordinal = write(t.Day())
+	}
+
+	return strings.NewReplacer(
+		"{weekday}", c.Date.Weekdays[t.Weekday()],
+		"{day}", strconv.Itoa(t.Day()),
+		"{ordinal}", ordinal,
+		"{month}", c.Date.Months[t.Month()-1],
+		"{year}", strconv.Itoa(t.Year()),
+	).Replace(c.Date.Long)
+}
+
+// Date is FormatDate for templates. It takes a time.Time, or a string which is
+// formatted if it is a date written as YYYY-MM-DD and otherwise shown as it
+// is, so settings can be written either way.
+func Date(language string, value any) string {
+	switch v := value.(type) {
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return FormatDate(language, v)
+	case string:
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			return v
+		}
+		return FormatDate(language, t)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func englishOrdinal(day int) string {
+	suffix := "th"
+	switch {
+	case day%100 >= 11 && day%100 <= 13:
+	case day%10 == 1:
+		suffix = "st"
+	case day%10 == 2:
+		suffix = "nd"
+	case day%10 == 3:
+		suffix = "rd"
+	}
+	return strconv.Itoa(day) + suffix
+}
+
+func frenchOrdinal(day int) string {
+	if day == 1 {
+		return "1er"
+	}
+	return strconv.Itoa(day)
+}
+
+// FuncMap is added to the templates: {{ t .Lang "key" "name" value }} shows a
+// message, {{ date .Lang .Date }} a date and {{ languages }} lists the
+// catalogues for the switcher.
+var FuncMap = template.FuncMap{
+	"t":         T,
+	"date":      Date,
+	"languages": Languages,
+	"locale": func(language string) string {
+		return catalogue(language).Locale
+	},
+}
+
+var placeholder = regexp.MustCompile(`\{[a-z_]+\}`)
@@ -0,0 +1,97 @@
+package i18n
+
+import (
+	"html/template"
+	"testing"
+	"time"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/content"
+)
+
+func TestCataloguesComplete(t *testing.T) {
+	if problems := Check(); len(problems) != 0 {
+		t.Errorf("the catalogues are incomplete:\n%v", problems)
+	}
+}
+
+func TestTemplatesUseKnownKeys(t *testing.T) {
+	tpl := template.Must(template.New("").Funcs(content.FuncMap).Funcs(FuncMap).ParseGlob("../../templates/*.gohtml"))
+	template.Must(tpl.ParseGlob("../../templates/form/*.gohtml"))
+
+	if problems := CheckTemplates(tpl); len(problems) != 0 {
+		t.Errorf("the templates use keys missing from the catalogues:\n%v", problems)
+	}
+
+	unknown := template.Must(template.New("page").Funcs(FuncMap).Parse(`{{ t .Lang "no.such.key" }}`))
+	if problems := CheckTemplates(unknown); len(problems) == 0 {
+		t.Error("a key missing from the catalogues wasn't reported")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                        DefaultLanguage,
+		"fr":                      "fr",
+		"fr-FR,fr;q=0.9":          "fr",
+		"en-GB,en;q=0.9,fr;q=0.8": "en",
+		"de-DE,fr;q=0.7,en;q=0.5": "fr",
+		"de,es":                   DefaultLanguage,
+		"en;q=0.4, FR-ca;q=0.6":   "fr",
+		"fr;q=nonsense,en;q=0.1":  "en",
+		"fr;q=0.5,en-US;q=0.5,de": "fr",
+	} {
+		if got := Match(header); got != want {
+			t.Errorf("Match(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	for _, tt := range []struct {
+		language string
+		date     time.Time
+		want     string
+	}{
+		{"en", time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), "Saturday 1st March 2025"},
+		{"en", time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC), "Sunday 2nd March 2025"},
+		{"en", time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC), "Monday 3rd March 2025"},
+		{"en", time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC), "Tuesday 11th March 2025"},
+		{"en", time.Date(2025, 3, 12, 0, 0, 0, 0, time.UTC), "Wednesday 12th March 2025"},
+		{"en", time.Date(2025, 3, 13, 0, 0, 0, 0, time.UTC), "Thursday 13th March 2025"},
+		{"en", time.Date(2025, 3, 22, 0, 0, 0, 0, time.UTC), "Saturday 22nd March 2025"},
+		{"en", time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC), "Monday 31st March 2025"},
+		{"fr", time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), "samedi 1er mars 2025"},
+		{"fr", time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC), "dimanche 2 mars 2025"},
+		{"fr", time.Date(2025, 8, 21, 0, 0, 0, 0, time.UTC), "jeudi 21 août 2025"},
+		{"de", time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), "Saturday 1st March 2025"},
+	} {
+		if got := FormatDate(tt.language, tt.date); got != tt.want {
+			t.Errorf("FormatDate(%q, %s) = %q, want %q", tt.language, tt.date.Format(time.DateOnly), got, tt.want)
+		}
+	}
+
+	if got := Date("fr", "2025-03-01"); got != "samedi 1er mars 2025" {
+		t.Errorf("Date of a YYYY-MM-DD string = %q", got)
+	}
+	if got := Date("en", "Spring 2025"); got != "Spring 2025" {
+		t.Errorf("Date of free text = %q, want it as it is", got)
+	}
+}
+
+func TestTEscapesValues(t *testing.T) {
+	got := T("en", "accepted.table", "table", `<script>alert("hi")</script>`)
+	want := template.HTML(`You'll be sitting at <span class="light-bold">&lt;script&gt;alert(&#34;hi&#34;)&lt;/script&gt;</span>.`)
+	if got != want {
+		t.Errorf("T escaped a string value to %q, want %q", got, want)
+	}
+
+	got = T("en", "accepted.table", "table", template.HTML("<em>Oak</em>"))
+	want = template.HTML(`You'll be sitting at <span class="light-bold"><em>Oak</em></span>.`)
+	if got != want {
+		t.Errorf("T with a template.HTML value = %q, want %q", got, want)
+	}
+
+	if got := T("en", "<no.such.key>"); got != "&lt;no.such.key&gt;" {
+		t.Errorf("T of a missing key = %q, want the key escaped", got)
+	}
+}
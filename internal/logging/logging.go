@@ -0,0 +1,146 @@
+// Package logging sets up the server's structured JSON logs. Emails and phone
+// numbers are redacted before anything is written, as the daily log files
+// are uploaded to S3.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Output lets the log file be swapped at midnight without recreating the
+// logger.
+type Output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewOutput(w io.Writer) *Output {
+	return &Output{w: w}
+}
+
+func (o *Output) Set(w io.Writer) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.w = w
+}
+
+func (o *Output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.w.Write(p)
+}
+
+// ParseLevel reads LOG_LEVEL, which is one of debug, info, warn or error and
+// defaults to info.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return level, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// New returns a JSON logger writing to w that redacts personal details and
+// adds the request id and guest id of the request being handled.
+func New(w io.Writer, level slog.Level) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+	return slog.New(redactHandler{next: handler})
+}
+
+var (
+	reEmail = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// UK style numbers, starting with 0 or an international prefix, so dates
+	// and ids aren't mistaken for phone numbers. Neither is followed by
+	// another 0, which keeps zero padded ids such as backup keys readable
+	rePhoneNumber = regexp.MustCompile(`(?:\+|\b0)[1-9][\d \-]{7,14}\d`)
+
+	// Attributes with these keys are always redacted whatever they hold.
+	// A guest code is all it takes to answer as that guest, so log guest_id
+	// instead
+	sensitiveKeys = map[string]bool{
+		"email":          true,
+		"phone":          true,
+		"phone_number":   true,
+		"code":           true,
+		"guest_code":     true,
+		"duplicate_code": true,
+	}
+)
+
+// Redact replaces anything that looks like an email address or phone number.
+func Redact(s string) string {
+	s = reEmail.ReplaceAllString(s, "[email]")
+	return rePhoneNumber.ReplaceAllString(s, "[phone]")
+}
+
+type redactHandler struct {
+	next slog.Handler
+}
+
+func (h redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, Redact(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(a))
+		return true
+	})
+
+	if info := requestInfoFrom(ctx); info != nil {
+		redacted.AddAttrs(slog.String("request_id", info.id))
+		if guestID := info.guestID(); guestID != 0 {
+			redacted.AddAttrs(slog.Int("guest_id", guestID))
+		}
+	}
+
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redactAttr(a)
+	}
+	return redactHandler{next: h.next.WithAttrs(redacted)}
+}
+
+func (h redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{next: h.next.WithGroup(name)}
+}
+
+func redactAttr(a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, "[redacted]")
+	}
+
+	value := a.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, len(group))
+		for i, ga := range group {
+			redacted[i] = redactAttr(ga)
+		}
+		return slog.Group(a.Key, redacted...)
+	case slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			return slog.String(a.Key, Redact(err.Error()))
+		}
+		return slog.String(a.Key, Redact(fmt.Sprint(value.Any())))
+	default:
+		return slog.Attr{Key: a.Key, Value: value}
+	}
+}
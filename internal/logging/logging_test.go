@@ -0,0 +1,148 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	for in, want := range map[string]string{
+		"alice@example.com":                         "[email]",
+		"from Alice.Smith+rsvp@mail.example.co.uk.": "from [email].",
+		"07700900000":                               "[phone]",
+		"call 07700 900 000 today":                  "call [phone] today",
+		"+44 7700 900000":                           "[phone]",
+		"+447700-900-000":                           "[phone]",
+		"020 7946 0000 or bob@example.com":          "[phone] or [email]",
+		// dates, times, ids and counts are left alone
+		"2025-03-01":                           "2025-03-01",
+		"2025-03-01 14:30:00.000":              "2025-03-01 14:30:00.000",
+		"guest 1234567890 changed":             "guest 1234567890 changed",
+		"request 9f86d081884c7d65":             "request 9f86d081884c7d65",
+		"changes_000000000001-000000000042":    "changes_000000000001-000000000042",
+		"attempt 3 of 5":                       "attempt 3 of 5",
+		"not an email: @example.com or alice@": "not an email: @example.com or alice@",
+	} {
+		if got := Redact(in); got != want {
+			t.Errorf("Redact(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// logLine logs with a logger built by New and returns the line it wrote.
+func logLine(t *testing.T, log func(logger *slog.Logger)) (map[string]any, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	log(New(&buf, slog.LevelDebug))
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("invalid log line %q: %v", buf.String(), err)
+	}
+	return line, buf.String()
+}
+
+func assertRedacted(t *testing.T, raw string) {
+	t.Helper()
+	for _, secret := range []string{"alice@example.com", "07700900000", "Alice-BpLnfg"} {
+		if strings.Contains(raw, secret) {
+			t.Errorf("log line contains %q: %s", secret, raw)
+		}
+	}
+}
+
+func TestSensitiveKeys(t *testing.T) {
+	for key := range sensitiveKeys {
+		for _, written := range []string{key, strings.ToUpper(key)} {
+			line, raw := logLine(t, func(logger *slog.Logger) {
+				logger.Info("update", written, "Alice-BpLnfg")
+			})
+			if line[written] != "[redacted]" {
+				t.Errorf("%s = %v, want [redacted]", written, line[written])
+			}
+			assertRedacted(t, raw)
+		}
+	}
+
+	line, _ := logLine(t, func(logger *slog.Logger) {
+		logger.Info("update", "table", "Oak", "guest_id", 12)
+	})
+	if line["table"] != "Oak" || line["guest_id"] != float64(12) {
+		t.Errorf("other attributes were changed: %v", line)
+	}
+}
+
+func TestRedactsMessagesAndValues(t *testing.T) {
+	line, raw := logLine(t, func(logger *slog.Logger) {
+		logger.Info("alice@example.com sent 07700900000", "body", "email=alice@example.com&phone=07700900000")
+	})
+	if line["msg"] != "[email] sent [phone]" {
+		t.Errorf("msg = %v", line["msg"])
+	}
+	if line["body"] != "email=[email]&phone=[phone]" {
+		t.Errorf("body = %v", line["body"])
+	}
+	assertRedacted(t, raw)
+}
+
+func TestRedactsGroups(t *testing.T) {
+	line, raw := logLine(t, func(logger *slog.Logger) {
+		logger.Info("update", slog.Group("guest",
+			slog.String("email", "alice@example.com"),
+			slog.String("note", "ring 07700900000"),
+			slog.Group("session", slog.String("code", "Alice-BpLnfg")),
+			slog.Int("plus_ones", 1),
+		))
+	})
+	assertRedacted(t, raw)
+
+	guest, ok := line["guest"].(map[string]any)
+	if !ok {
+		t.Fatalf("guest = %v, want a group", line["guest"])
+	}
+	if guest["email"] != "[redacted]" || guest["note"] != "ring [phone]" || guest["plus_ones"] != float64(1) {
+		t.Errorf("guest = %v", guest)
+	}
+	if session, ok := guest["session"].(map[string]any); !ok || session["code"] != "[redacted]" {
+		t.Errorf("session = %v", guest["session"])
+	}
+}
+
+type guestDetails struct {
+	Email string
+	Phone string
+}
+
+func TestRedactsErrorsAndOtherValues(t *testing.T) {
+	line, raw := logLine(t, func(logger *slog.Logger) {
+		logger.Error("could not send",
+			"error", errors.New("smtp: rejected alice@example.com"),
+			"wrapped", errors.Join(errors.New("sms failed"), errors.New("invalid number 07700900000")),
+			"details", guestDetails{Email: "alice@example.com", Phone: "07700900000"},
+		)
+	})
+	assertRedacted(t, raw)
+	if line["error"] != "smtp: rejected [email]" {
+		t.Errorf("error = %v", line["error"])
+	}
+}
+
+func TestRedactsWithAttrs(t *testing.T) {
+	line, raw := logLine(t, func(logger *slog.Logger) {
+		logger.With("email", "alice@example.com", "contact", "07700900000").
+			WithGroup("request").
+			With("guest_code", "Alice-BpLnfg").
+			Info("update", "note", "from alice@example.com")
+	})
+	assertRedacted(t, raw)
+	if line["email"] != "[redacted]" || line["contact"] != "[phone]" {
+		t.Errorf("attributes added with With weren't redacted: %v", line)
+	}
+	if request, ok := line["request"].(map[string]any); !ok || request["guest_code"] != "[redacted]" || request["note"] != "from [email]" {
+		t.Errorf("request = %v", line["request"])
+	}
+}
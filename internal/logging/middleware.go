@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+type contextKey struct{}
+
+// requestInfo is shared by everything handling a request, so the guest id
+// found by a handler can be added to the request's log lines.
+type requestInfo struct {
+	id string
+
+	mu    sync.Mutex
+	guest int
+}
+
+func (i *requestInfo) guestID() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.guest
+}
+
+func requestInfoFrom(ctx context.Context) *requestInfo {
+	if ctx == nil {
+		return nil
+	}
+	info, _ := ctx.Value(contextKey{}).(*requestInfo)
+	return info
+}
+
+// SetGuestID records which guest the request is for.
+func SetGuestID(ctx context.Context, guestID int) {
+	if info := requestInfoFrom(ctx); info != nil {
+		info.mu.Lock()
+		info.guest = guestID
+		info.mu.Unlock()
+	}
+}
+
+// RequestID returns the id of the request being handled.
+func RequestID(ctx context.Context) string {
+	if info := requestInfoFrom(ctx); info != nil {
+		return info.id
+	}
+	return ""
+}
+
+// Incoming request ids are only trusted if they look like one
+var reRequestID = regexp.MustCompile(`^[A-Za-z0-9\-_.]{1,64}$`)
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Middleware gives every request an id, returned in the X-Request-ID header,
+// and logs a line for each request once it has been handled. The route is
+// the pattern mux matched rather than the path.
+func Middleware(logger *slog.Logger, mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id := req.Header.Get("X-Request-ID")
+		if !reRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		info := &requestInfo{id: id}
+		ctx := context.WithValue(req.Context(), contextKey{}, info)
+		req = req.WithContext(ctx)
+		w.Header().Set("X-Request-ID", id)
+
+		_, route := mux.Handler(req)
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, req)
+
+		level := slog.LevelInfo
+		if recorder.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logger.LogAttrs(ctx, level, "request",
+			slog.String("route", route),
+			slog.String("method", req.Method),
+			slog.Int("status", recorder.status),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+		)
+	})
+}
@@ -0,0 +1,154 @@
+// Package metrics exposes the server's Prometheus metrics.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "wedding_rsvps"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route"})
+
+	rsvps = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rsvp_submissions_total",
+		Help:      "RSVP form submissions by outcome.",
+	}, []string{"outcome"})
+
+	invalidCodes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "invalid_code_attempts_total",
+		Help:      "Attempts to RSVP with a guest code that doesn't exist.",
+	})
+
+	validationFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "validation_failures_total",
+		Help:      "Guest details rejected by validation, by field.",
+	}, []string{"field"})
+
+	dbDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Database query latency by kind of statement.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"statement"})
+
+	backupLastSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "backup_last_success_timestamp_seconds",
+		Help:      "When the nightly backup last succeeded.",
+	})
+
+	backupLastFailure = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "backup_last_failure_timestamp_seconds",
+		Help:      "When the nightly backup last failed.",
+	})
+)
+
+// RSVP outcomes
+const (
+	OutcomeAccepted = "accepted"
+	OutcomeDeclined = "declined"
+)
+
+func init() {
+	// Start the outcomes at zero so rates can be graphed before the first RSVP
+	rsvps.WithLabelValues(OutcomeAccepted)
+	rsvps.WithLabelValues(OutcomeDeclined)
+}
+
+func RSVPSubmitted(outcome string) {
+	rsvps.WithLabelValues(outcome).Inc()
+}
+
+func InvalidCodeAttempted() {
+	invalidCodes.Inc()
+}
+
+func ValidationFailed(field string) {
+	validationFailures.WithLabelValues(field).Inc()
+}
+
+// ObserveQuery records how long a statement took, labelled by its first
+// keyword so the number of series stays small.
+func ObserveQuery(query string, start time.Time) {
+	var statement string
+	if fields := strings.Fields(query); len(fields) > 0 {
+		statement = strings.ToLower(fields[0])
+	}
+	switch statement {
+	case "select", "insert", "update", "delete", "with", "create", "pragma":
+	default:
+		statement = "other"
+	}
+	dbDuration.WithLabelValues(statement).Observe(time.Since(start).Seconds())
+}
+
+func BackupFinished(succeeded bool) {
+	if succeeded {
+		backupLastSuccess.SetToCurrentTime()
+	} else {
+		backupLastFailure.SetToCurrentTime()
+	}
+}
+
+// Handler serves the metrics for Prometheus to scrape.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Middleware counts and times every request by the pattern it was routed
+// to, rather than its path, so unknown paths don't each get their own series.
+func Middleware(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, route := mux.Handler(req)
+		if route == "" {
+			route = "unmatched"
+		}
+
+		method := req.Method
+		switch method {
+		case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		default:
+			method = "other"
+		}
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		mux.ServeHTTP(recorder, req)
+
+		httpDuration.WithLabelValues(route).Observe(time.Since(start).Seconds())
+		httpRequests.WithLabelValues(route, method, strconv.Itoa(recorder.status)).Inc()
+	})
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"strings"
+)
+
+type Address struct {
+	Line1    string
+	Line2    string
+	City     string
+	County   string
+	Postcode string
+	Country  string
+}
+
+func (a Address) IsEmpty() bool {
+	return a.Line1 == "" && a.Line2 == "" && a.City == "" && a.County == "" && a.Postcode == "" && a.Country == ""
+}
+
+// Lines returns the non-empty parts of the address in the order they are
+// written on an envelope.
+func (a Address) Lines() []string {
+	var lines []string
+	for _, l := range []string{a.Line1, a.Line2, a.City, a.County, a.Postcode, a.Country} {
+		if l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// key identifies guests living at the same address regardless of how the
+// address was capitalised or spaced when it was entered.
+func (a Address) key() string {
+	normalise := func(s string) string {
+		return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+	}
+	return normalise(a.Line1) + "|" + strings.ReplaceAll(normalise(a.Postcode), " ", "")
+}
+
+// Recipient is a single envelope: everyone invited at one address.
+type Recipient struct {
+	Names   []string
+	Address Address
+}
+
+// Addressee joins the names of everyone at the address, e.g. "Anna, Ben & Cat".
+func (r Recipient) Addressee() string {
+	switch len(r.Names) {
+	case 0:
+		return ""
+	case 1:
+		return r.Names[0]
+	default:
+		return strings.Join(r.Names[:len(r.Names)-1], ", ") + " & " + r.Names[len(r.Names)-1]
+	}
+}
+
+// Recipients groups guests by household, or by address for guests without a
+// household, so each household gets a single envelope. Households where
+// nobody has an address are left out.
+func Recipients(guests []Guest) []Recipient {
+	var recipients []Recipient
+	indexByKey := make(map[string]int)
+
+	for _, g := range guests {
+		k := "household:" + g.Household
+		if g.Household == "" {
+			if g.Address.IsEmpty() {
+				continue
+			}
+			k = "address:" + g.Address.key()
+		}
+
+		if idx, ok := indexByKey[k]; ok {
+			recipients[idx].Names = append(recipients[idx].Names, g.Name)
+			if recipients[idx].Address.IsEmpty() {
+				recipients[idx].Address = g.Address
+			}
+			continue
+		}
+		indexByKey[k] = len(recipients)
+		recipients = append(recipients, Recipient{Names: []string{g.Name}, Address: g.Address})
+	}
+
+	withAddress := recipients[:0]
+	for _, r := range recipients {
+		if !r.Address.IsEmpty() {
+			withAddress = append(withAddress, r)
+		}
+	}
+
+	return withAddress
+}
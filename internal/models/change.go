@@ -0,0 +1,22 @@
+package models
+
+import "encoding/json"
+
+const (
+	ChangeUpsert = "upsert"
+	ChangeDelete = "delete"
+	// ChangeRestore marks the database being restored from a backup. Changes
+	// logged before it may have been undone by the restore.
+	ChangeRestore = "restore"
+)
+
+// Change is a row written to or deleted from a table, as recorded in the
+// change log. Upserts hold the whole row and deletes only its primary key.
+// Restores hold the id of the last change the restored database had.
+type Change struct {
+	ID        int64           `json:"id"`
+	Table     string          `json:"table"`
+	Operation string          `json:"operation"`
+	Row       json.RawMessage `json:"row"`
+	CreatedAt string          `json:"created_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+// CheckInCounts is the running total shown to ushers on the day.
+type CheckInCounts struct {
+	Arrived  int `json:"arrived"`
+	Expected int `json:"expected"`
+}
+
+// CheckInData is what the check-in page shows after a code is scanned.
+type CheckInData struct {
+	PartnerOne     string
+	PartnerTwo     string
+	Code           string
+	Guest          *Guest
+	SeatAssignment *SeatAssignment
+	ArrivedAt      string
+	AlreadyArrived bool
+	NotFound       bool
+	Counts         CheckInCounts
+}
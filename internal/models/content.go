@@ -0,0 +1,71 @@
+package models
+
+// Content is the wedding information shown to guests, edited from the admin
+// area. Fields described as Markdown are rendered and sanitised before they
+// reach a page.
+type Content struct {
+	Venue     Venue           `json:"venue" toml:"venue"`
+	Itinerary []ItineraryItem `json:"itinerary" toml:"itinerary"`
+	FAQ       []FAQSection    `json:"faq" toml:"faq"`
+	Travel    []TravelSection `json:"travel" toml:"travel"`
+	// Accommodation is where guests can stay nearby
+	Accommodation []Accommodation `json:"accommodation" toml:"accommodation"`
+	// Footer is Markdown shown at the bottom of every page
+	Footer string `json:"footer" toml:"footer"`
+}
+
+type Venue struct {
+	Name         string   `json:"name" toml:"name"`
+	AddressLines []string `json:"address_lines" toml:"address_lines"`
+	MapURL       string   `json:"map_url" toml:"map_url"`
+	// Directions is Markdown shown under the address
+	Directions string `json:"directions" toml:"directions"`
+}
+
+// ItineraryItem is something happening on the day after the ceremony starts.
+type ItineraryItem struct {
+	Time  string `json:"time" toml:"time"`
+	Title string `json:"title" toml:"title"`
+	// Description is Markdown
+	Description string `json:"description" toml:"description"`
+}
+
+type FAQSection struct {
+	Title     string     `json:"title" toml:"title"`
+	Questions []FAQEntry `json:"questions" toml:"questions"`
+}
+
+type FAQEntry struct {
+	Question string `json:"question" toml:"question"`
+	// Answer is Markdown
+	Answer string `json:"answer" toml:"answer"`
+}
+
+type TravelSection struct {
+	Title string `json:"title" toml:"title"`
+	// Body is Markdown
+	Body string `json:"body" toml:"body"`
+}
+
+type Accommodation struct {
+	Name    string `json:"name" toml:"name"`
+	Address string `json:"address" toml:"address"`
+	// BookingURL is where guests book, and GroupCode the code which gets them
+	// the rate held for the wedding, which may only last until BookBy
+	BookingURL string `json:"booking_url" toml:"booking_url"`
+	GroupCode  string `json:"group_code" toml:"group_code"`
+	BookBy     string `json:"book_by" toml:"book_by"`
+	// Description is Markdown
+	Description string `json:"description" toml:"description"`
+}
+
+// ContentData is what the admin content editor shows.
+type ContentData struct {
+	PartnerOne string
+	PartnerTwo string
+	// Source is the content as TOML, as it is edited
+	Source  string
+	Error   string
+	Saved   bool
+	Preview *Content
+}
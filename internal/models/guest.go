@@ -1,23 +1,45 @@
 package models
 
+import (
+	"net/url"
+	"strings"
+)
+
 const InvalidGuestKey = "invalid_guest"
 
 type Guest struct {
 	ID                  int
+	ExternalID          string
 	Name                string
 	Code                string
 	Email               string
 	PhoneNumber         string
 	MealChoice          string
 	DietaryRequirements string
+	Address             Address
+	Household           string
+	PlusOnes            int
+	Events              []string
+	Tags                []string
 	Attendance          bool
 	InvalidDetails      bool
 	DetailsProvided     bool
 	FormStarted         bool
 	FormCompleted       bool
+	Deleted             bool
+	ArrivedAt           string
+	// Language is the language the guest chose for the site, or empty if they
+	// haven't
+	Language string
 }
 
 var InvalidGuest = Guest{
 	Code:           InvalidGuestKey,
 	InvalidDetails: true,
 }
+
+// LoginURL is the link printed on invitations which opens the RSVP page with
+// the guest's code already filled in.
+func (g Guest) LoginURL(baseURL string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/?code=" + url.QueryEscape(g.Code)
+}
@@ -0,0 +1,31 @@
+package models
+
+// Actors record who made a change to a guest.
+const (
+	ActorGuest = "guest"
+	ActorAdmin = "admin"
+	ActorAPI   = "api"
+)
+
+const (
+	GuestEventUpdated    = "updated"
+	GuestEventImported   = "imported"
+	GuestEventRenamed    = "renamed"
+	GuestEventDeleted    = "deleted"
+	GuestEventRestored   = "restored"
+	GuestEventMerged     = "merged"
+	GuestEventMergedInto = "merged_into"
+	GuestEventCheckedIn  = "checked_in"
+)
+
+// GuestEvent is a single entry in a guest's append-only history.
+type GuestEvent struct {
+	ID        int    `json:"id"`
+	GuestID   int    `json:"guest_id"`
+	Type      string `json:"type"`
+	Field     string `json:"field,omitempty"`
+	OldValue  string `json:"old_value,omitempty"`
+	NewValue  string `json:"new_value,omitempty"`
+	Actor     string `json:"actor"`
+	CreatedAt string `json:"created_at"`
+}
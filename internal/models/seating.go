@@ -0,0 +1,45 @@
+package models
+
+const (
+	TableShapeRound       = "round"
+	TableShapeRectangular = "rectangular"
+	TableShapeSquare      = "square"
+	TableShapeOval        = "oval"
+)
+
+var TableShapes = []string{TableShapeRound, TableShapeRectangular, TableShapeSquare, TableShapeOval}
+
+type Table struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Capacity int    `json:"capacity"`
+	Shape    string `json:"shape"`
+}
+
+// SeatAssignment places a guest at a table. SeatNumber is 0 when the guest
+// can sit anywhere at the table.
+type SeatAssignment struct {
+	GuestID    int    `json:"guest_id"`
+	GuestCode  string `json:"guest_code"`
+	GuestName  string `json:"guest_name"`
+	MealChoice string `json:"meal_choice,omitempty"`
+	TableID    int    `json:"table_id"`
+	TableName  string `json:"table_name"`
+	SeatNumber int    `json:"seat_number,omitempty"`
+}
+
+type SeatedTable struct {
+	Table
+	Guests []SeatAssignment `json:"guests"`
+}
+
+type SeatingPlan struct {
+	Tables     []SeatedTable `json:"tables"`
+	Unassigned []GuestRef    `json:"unassigned"`
+}
+
+// GuestRef is the minimum needed to identify a guest in admin responses.
+type GuestRef struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
@@ -5,4 +5,5 @@ type SessionData struct {
 	InvalidEmail               bool
 	InvalidPhoneNumber         bool
 	InvalidDietaryRequirements bool
+	InvalidAddress             bool
 }
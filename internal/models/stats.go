@@ -0,0 +1,53 @@
+package models
+
+import "html/template"
+
+// Stats summarises where every guest is with their RSVP.
+type Stats struct {
+	Invited            int          `json:"invited"`
+	Responded          int          `json:"responded"`
+	Attending          int          `json:"attending"`
+	Declined           int          `json:"declined"`
+	NotOpened          int          `json:"not_opened"`
+	OpenedNotCompleted int          `json:"opened_not_completed"`
+	InvalidDetails     int          `json:"invalid_details"`
+	ResponseRate       float64      `json:"response_rate"`
+	MealChoices        []Count      `json:"meal_choices"`
+	Responses          []DailyCount `json:"responses"`
+	// Anonymous visitors are counted once per day, as their ids change daily
+	LandingVisitors     int             `json:"landing_visitors"`
+	InvalidCodeVisitors int             `json:"invalid_code_visitors"`
+	DailyVisitors       []DailyVisitors `json:"daily_visitors"`
+}
+
+type Count struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// DailyCount is the running total of guests who had responded by the end of
+// the day.
+type DailyCount struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+// DailyVisitors counts the anonymous visitors to the landing page and those
+// who tried a code that didn't work on one day.
+type DailyVisitors struct {
+	Date        string `json:"date"`
+	Landing     int    `json:"landing"`
+	InvalidCode int    `json:"invalid_code"`
+}
+
+// StatsData is what the admin stats page shows.
+type StatsData struct {
+	PartnerOne      string
+	PartnerTwo      string
+	Stats           Stats
+	ResponsePercent int
+	StatusChart     template.HTML
+	MealChart       template.HTML
+	ResponsesChart  template.HTML
+	VisitorsChart   template.HTML
+}
@@ -1,26 +1,30 @@
 package models
 
-import (
-	"html/template"
-)
+import "time"
 
 type ViewData struct {
-	Url                   string
-	PartnerOne            string
-	PartnerTwo            string
-	Date                  string
-	VenueVague            string
-	VenueAddress          template.HTML
-	VenueTravelDetails    template.HTML
-	TimeStart             string
-	TimeArrival           string
-	MainPhotoFileName     string
-	PostCeremonyItinerary template.HTML
-	BankName              string
-	BankAccountName       string
-	BankSortCode          string
-	BankAccountNumber     string
-	FooterMessage         template.HTML
-	Guest                 *Guest
-	SessionData           *SessionData
+	Url        string
+	PartnerOne string
+	PartnerTwo string
+	// Date is shown in the guest's language when it is written as YYYY-MM-DD
+	Date              string
+	RSVPBy            time.Time
+	VenueVague        string
+	TimeStart         string
+	TimeArrival       string
+	MainPhotoFileName string
+	BankName          string
+	BankAccountName   string
+	BankSortCode      string
+	BankAccountNumber string
+	Content           *Content
+	// PagesPublic shows the FAQ, travel and accommodation pages to visitors
+	// who haven't entered a code
+	PagesPublic bool
+	// Lang is the language the page is shown in
+	Lang           string
+	GuestCode      string
+	Guest          *Guest
+	SessionData    *SessionData
+	SeatAssignment *SeatAssignment
 }
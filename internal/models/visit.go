@@ -0,0 +1,13 @@
+package models
+
+// Visit is a single page view. Visits by guests who haven't entered their
+// code yet have no GuestID.
+type Visit struct {
+	ID        int    `json:"id"`
+	GuestID   int    `json:"guest_id,omitempty"`
+	VisitorID string `json:"visitor_id,omitempty"`
+	Page      string `json:"page"`
+	Referrer  string `json:"referrer,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
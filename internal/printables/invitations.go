@@ -0,0 +1,114 @@
+package printables
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/skip2/go-qrcode"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+const (
+	LayoutCards = "cards"
+	LayoutPages = "pages"
+
+	cardColumns = 2
+	cardRows    = 4
+	qrPixels    = 512
+)
+
+// InvitationInserts writes a PDF containing an insert for every guest with
+// their name, their unique code and a QR code that opens the RSVP page with
+// the code filled in. LayoutCards fits several inserts on each sheet ready
+// to be cut out, LayoutPages prints one insert per page.
+//
+// Inserts are per guest rather than per household, as every guest RSVPs with
+// their own code, but a household's inserts are printed one after another so
+// they can go in the same envelope.
+func InvitationInserts(w io.Writer, guests []models.Guest, baseURL, layout string) error {
+	pdf, tr := newDocument()
+
+	for idx, guest := range byHousehold(guests) {
+		qrName := fmt.Sprintf("qr-%d", guest.ID)
+		qrPNG, err := qrcode.Encode(guest.LoginURL(baseURL), qrcode.Medium, qrPixels)
+		if err != nil {
+			return fmt.Errorf("failed to generate qr code for guest %v: %v", guest.ID, err)
+		}
+		pdf.RegisterImageOptionsReader(qrName, fpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(qrPNG))
+
+		switch layout {
+		case LayoutPages:
+			pdf.AddPage()
+			insert(pdf, tr, guest, baseURL, qrName, margin, margin, pageWidth-2*margin, pageHeight-2*margin, 2)
+		default:
+			perPage := cardColumns * cardRows
+			if idx%perPage == 0 {
+				pdf.AddPage()
+			}
+			cardWidth := (pageWidth - 2*margin) / cardColumns
+			cardHeight := (pageHeight - 2*margin) / cardRows
+			x := margin + float64(idx%cardColumns)*cardWidth
+			y := margin + float64((idx%perPage)/cardColumns)*cardHeight
+			cutLines(pdf, x, y, cardWidth, cardHeight)
+			insert(pdf, tr, guest, baseURL, qrName, x, y, cardWidth, cardHeight, 1)
+		}
+	}
+
+	if len(guests) == 0 {
+		pdf.AddPage()
+	}
+
+	return pdf.Output(w)
+}
+
+// byHousehold moves each household's guests up to the first of them, keeping
+// everyone else in order.
+func byHousehold(guests []models.Guest) []models.Guest {
+	members := make(map[string][]models.Guest)
+	for _, g := range guests {
+		if g.Household != "" {
+			members[g.Household] = append(members[g.Household], g)
+		}
+	}
+
+	ordered := make([]models.Guest, 0, len(guests))
+	for _, g := range guests {
+		if g.Household == "" {
+			ordered = append(ordered, g)
+			continue
+		}
+		ordered = append(ordered, members[g.Household]...)
+		delete(members, g.Household)
+	}
+	return ordered
+}
+
+// insert draws a single invitation insert inside the box at x, y. scale
+// grows the text and QR code for the one-per-page layout.
+func insert(pdf *fpdf.Fpdf, tr func(string) string, guest models.Guest, baseURL, qrName string, x, y, w, h, scale float64) {
+	padding := 5 * scale
+	qrSize := 40 * scale
+	if qrSize > h-4*padding {
+		qrSize = h - 4*padding
+	}
+	innerWidth := w - 2*padding
+
+	pdf.SetXY(x+padding, y+padding)
+	pdf.SetFont(fontFamily, "B", 14*scale)
+	pdf.CellFormat(innerWidth, 8*scale, tr(guest.Name), "", 2, "C", false, 0, "")
+
+	pdf.SetFont(fontFamily, "", 9*scale)
+	pdf.CellFormat(innerWidth, 5*scale, "Your unique guest code:", "", 2, "C", false, 0, "")
+	pdf.SetFont("Courier", "B", 12*scale)
+	pdf.CellFormat(innerWidth, 6*scale, guest.Code, "", 2, "C", false, 0, "")
+
+	qrY := pdf.GetY() + scale
+	pdf.ImageOptions(qrName, x+(w-qrSize)/2, qrY, qrSize, qrSize, false, fpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+
+	pdf.SetXY(x+padding, qrY+qrSize+scale)
+	pdf.SetFont(fontFamily, "", 7*scale)
+	pdf.CellFormat(innerWidth, 4*scale, tr("Scan to RSVP or visit "+baseURL), "", 2, "C", false, 0, "")
+}
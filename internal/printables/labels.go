@@ -0,0 +1,60 @@
+package printables
+
+import (
+	"io"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+// Avery L7160 / J8160 layout: 21 labels per A4 sheet in 3 columns and 7 rows.
+const (
+	labelColumns      = 3
+	labelRows         = 7
+	labelWidth        = 63.5
+	labelHeight       = 38.1
+	labelPitchX       = 66.04
+	labelPitchY       = 38.1
+	labelMarginLeft   = 7.21
+	labelMarginTop    = 15.15
+	labelPadding      = 4.0
+	labelLineHeight   = 4.6
+	labelMaxTextLines = 7
+)
+
+// AddressLabels writes a PDF of envelope labels, one per recipient, laid out
+// to print straight onto Avery L7160 compatible label sheets.
+func AddressLabels(w io.Writer, recipients []models.Recipient) error {
+	pdf, tr := newDocument()
+	perPage := labelColumns * labelRows
+
+	for idx, r := range recipients {
+		if idx%perPage == 0 {
+			pdf.AddPage()
+		}
+		x := labelMarginLeft + float64(idx%labelColumns)*labelPitchX
+		y := labelMarginTop + float64((idx%perPage)/labelColumns)*labelPitchY
+
+		lines := append([]string{r.Addressee()}, r.Address.Lines()...)
+		if len(lines) > labelMaxTextLines {
+			lines = lines[:labelMaxTextLines]
+		}
+
+		// Vertically centre the text block on the label
+		textHeight := float64(len(lines)) * labelLineHeight
+		pdf.SetXY(x+labelPadding, y+(labelHeight-textHeight)/2)
+		for lineIdx, line := range lines {
+			style := ""
+			if lineIdx == 0 {
+				style = "B"
+			}
+			pdf.SetFont(fontFamily, style, 9.5)
+			pdf.CellFormat(labelWidth-2*labelPadding, labelLineHeight, fitText(pdf, tr(line), labelWidth-2*labelPadding), "", 2, "L", false, 0, "")
+		}
+	}
+
+	if len(recipients) == 0 {
+		pdf.AddPage()
+	}
+
+	return pdf.Output(w)
+}
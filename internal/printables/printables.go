@@ -0,0 +1,51 @@
+package printables
+
+import (
+	"github.com/go-pdf/fpdf"
+)
+
+const (
+	pageWidth  = 210.0
+	pageHeight = 297.0
+	margin     = 10.0
+	fontFamily = "Helvetica"
+)
+
+// newDocument returns an A4 portrait document measured in millimetres along
+// with a translator that maps UTF-8 text onto the core font encoding, so that
+// names with accents print correctly.
+func newDocument() (*fpdf.Fpdf, func(string) string) {
+	return newSizedDocument("P", "A4")
+}
+
+func newSizedDocument(orientation, size string) (*fpdf.Fpdf, func(string) string) {
+	pdf := fpdf.New(orientation, "mm", size, "")
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(false, margin)
+
+	return pdf, pdf.UnicodeTranslatorFromDescriptor("")
+}
+
+// cutLines draws a light dashed rectangle that acts as a guide for cutting
+// cards out of a sheet.
+func cutLines(pdf *fpdf.Fpdf, x, y, w, h float64) {
+	pdf.SetDrawColor(200, 200, 200)
+	pdf.SetDashPattern([]float64{1, 1}, 0)
+	pdf.Rect(x, y, w, h, "D")
+	pdf.SetDashPattern([]float64{}, 0)
+	pdf.SetDrawColor(0, 0, 0)
+}
+
+// fitText shortens s with an ellipsis so that it fits within width at the
+// current font size. s must already be translated to the font encoding, where
+// every character is a single byte.
+func fitText(pdf *fpdf.Fpdf, s string, width float64) string {
+	if pdf.GetStringWidth(s) <= width {
+		return s
+	}
+
+	for len(s) > 0 && pdf.GetStringWidth(s+"...") > width {
+		s = s[:len(s)-1]
+	}
+	return s + "..."
+}
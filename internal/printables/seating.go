@@ -0,0 +1,152 @@
+package printables
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+// Place cards are tent cards, two to a row and three rows to an A4 sheet.
+// Each card is folded along its middle so the top half is printed upside
+// down and the name can be read from both sides of the table.
+const (
+	placeCardColumns = 2
+	placeCardRows    = 3
+	placeCardWidth   = (pageWidth - 2*margin) / placeCardColumns
+	placeCardHeight  = (pageHeight - 2*margin) / placeCardRows
+
+	tablePlanColumnWidth = 95.0
+	tablePlanLineHeight  = 7.0
+)
+
+// mealMarker is the letter the waiting staff look for on a place card.
+func mealMarker(mealChoice string) string {
+	if mealChoice == "" {
+		return ""
+	}
+	return strings.ToUpper(mealChoice[:1])
+}
+
+// PlaceCards writes a PDF of tent place cards for every seated guest, showing
+// their name, their table and a marker for their meal choice.
+func PlaceCards(w io.Writer, plan models.SeatingPlan) error {
+	pdf, tr := newDocument()
+	perPage := placeCardColumns * placeCardRows
+
+	idx := 0
+	for _, table := range plan.Tables {
+		for _, guest := range table.Guests {
+			if idx%perPage == 0 {
+				pdf.AddPage()
+			}
+			x := margin + float64(idx%placeCardColumns)*placeCardWidth
+			y := margin + float64((idx%perPage)/placeCardColumns)*placeCardHeight
+			cutLines(pdf, x, y, placeCardWidth, placeCardHeight)
+
+			// Fold line
+			pdf.SetDrawColor(220, 220, 220)
+			pdf.Line(x, y+placeCardHeight/2, x+placeCardWidth, y+placeCardHeight/2)
+			pdf.SetDrawColor(0, 0, 0)
+
+			halfHeight := placeCardHeight / 2
+			pdf.TransformBegin()
+			pdf.TransformRotate(180, x+placeCardWidth/2, y+halfHeight/2)
+			placeCardFace(pdf, tr, guest, x, y, placeCardWidth, halfHeight)
+			pdf.TransformEnd()
+			placeCardFace(pdf, tr, guest, x, y+halfHeight, placeCardWidth, halfHeight)
+
+			idx++
+		}
+	}
+
+	if idx == 0 {
+		pdf.AddPage()
+	}
+
+	return pdf.Output(w)
+}
+
+func placeCardFace(pdf *fpdf.Fpdf, tr func(string) string, guest models.SeatAssignment, x, y, w, h float64) {
+	pdf.SetFont(fontFamily, "B", 20)
+	pdf.SetXY(x, y+h/2-8)
+	pdf.CellFormat(w, 10, fitText(pdf, tr(guest.GuestName), w-10), "", 2, "C", false, 0, "")
+
+	pdf.SetFont(fontFamily, "", 11)
+	pdf.SetX(x)
+	pdf.CellFormat(w, 6, tr(tableLabel(guest)), "", 2, "C", false, 0, "")
+
+	if marker := mealMarker(guest.MealChoice); marker != "" {
+		r := 3.5
+		cx, cy := x+w-r-4, y+h-r-4
+		pdf.Circle(cx, cy, r, "D")
+		pdf.SetFont(fontFamily, "B", 9)
+		pdf.SetXY(cx-r, cy-r)
+		pdf.CellFormat(2*r, 2*r, marker, "", 0, "C", false, 0, "")
+	}
+}
+
+func tableLabel(guest models.SeatAssignment) string {
+	if guest.SeatNumber == 0 {
+		return guest.TableName
+	}
+	return fmt.Sprintf("%s, seat %d", guest.TableName, guest.SeatNumber)
+}
+
+// TablePlan writes the seating plan as a poster for the venue, listing the
+// guests at each table. size is a paper size such as "A1" or "A3".
+func TablePlan(w io.Writer, plan models.SeatingPlan, title, size string) error {
+	pdf, tr := newSizedDocument("L", size)
+	width, height := pdf.GetPageSize()
+
+	columns := int(math.Max(1, math.Floor((width-2*margin)/tablePlanColumnWidth)))
+	columnWidth := (width - 2*margin) / float64(columns)
+
+	titleHeight := 30.0
+	newPage := func() {
+		pdf.AddPage()
+		pdf.SetFont(fontFamily, "B", 32)
+		pdf.SetXY(margin, margin)
+		pdf.CellFormat(width-2*margin, titleHeight-10, tr(title), "", 0, "C", false, 0, "")
+	}
+	newPage()
+
+	// Tables flow down each column in turn, starting a new page once every
+	// column is full
+	column := 0
+	y := margin + titleHeight
+	for _, table := range plan.Tables {
+		blockHeight := float64(len(table.Guests)+2) * tablePlanLineHeight
+		if y+blockHeight > height-margin && y > margin+titleHeight {
+			column++
+			y = margin + titleHeight
+			if column == columns {
+				newPage()
+				column = 0
+			}
+		}
+		x := margin + float64(column)*columnWidth
+
+		pdf.SetXY(x, y)
+		pdf.SetFont(fontFamily, "B", 16)
+		pdf.CellFormat(columnWidth-5, tablePlanLineHeight+2, fitText(pdf, tr(table.Name), columnWidth-5), "B", 2, "L", false, 0, "")
+
+		pdf.SetFont(fontFamily, "", 13)
+		for _, guest := range table.Guests {
+			pdf.SetX(x)
+			name := guest.GuestName
+			if guest.SeatNumber != 0 {
+				name = fmt.Sprintf("%d. %s", guest.SeatNumber, name)
+			}
+			pdf.CellFormat(columnWidth-5, tablePlanLineHeight, fitText(pdf, tr(name), columnWidth-5), "", 2, "L", false, 0, "")
+		}
+
+		y = pdf.GetY() + tablePlanLineHeight
+	}
+
+	return pdf.Output(w)
+}
@@ -0,0 +1,209 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// The jobs the server runs on a schedule.
+const (
+	JobBackup            = "backup"
+	JobIncrementalBackup = "incremental-backup"
+	JobLogRotation       = "log-rotation"
+)
+
+const (
+	defaultRetries    = 5
+	defaultRetryDelay = time.Minute
+)
+
+var ErrUnknownJob = errors.New("unknown job")
+
+// Parse reads a standard five field cron schedule, e.g. "0 * * * *" for every
+// hour, or a descriptor such as "@daily". Schedules are in UTC.
+func Parse(spec string) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %v", spec, err)
+	}
+	return schedule, nil
+}
+
+type Job struct {
+	Name     string
+	Schedule cron.Schedule
+	Run      func() error
+}
+
+// Status is the outcome of the last time a job ran.
+type Status struct {
+	Running     bool       `json:"running"`
+	LastRun     *time.Time `json:"last_run"`
+	LastSuccess *time.Time `json:"last_success"`
+	LastError   string     `json:"last_error,omitempty"`
+	Attempts    int        `json:"attempts"`
+	NextRun     *time.Time `json:"next_run"`
+}
+
+// OK reports whether the last run succeeded, or the job hasn't run yet.
+func (s Status) OK() bool {
+	return s.LastError == ""
+}
+
+type job struct {
+	Job
+	// running stops a job started on demand overlapping a scheduled run
+	running sync.Mutex
+}
+
+// Scheduler runs jobs on their schedules, retrying failures with an
+// exponential backoff, and keeps the result of each job's last run.
+type Scheduler struct {
+	Retries    int
+	RetryDelay time.Duration
+
+	mu     sync.Mutex
+	jobs   map[string]*job
+	status map[string]*Status
+}
+
+func New() *Scheduler {
+	return &Scheduler{
+		Retries:    defaultRetries,
+		RetryDelay: defaultRetryDelay,
+		jobs:       make(map[string]*job),
+		status:     make(map[string]*Status),
+	}
+}
+
+func (s *Scheduler) Add(j Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs[j.Name] = &job{Job: j}
+	s.status[j.Name] = &Status{}
+}
+
+// Start runs every job on its schedule until ctx is cancelled.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.jobs {
+		go s.loop(ctx, j)
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	for {
+		next := j.Schedule.Next(time.Now().UTC())
+		s.update(j.Name, func(status *Status) {
+			status.NextRun = &next
+		})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+
+		s.runWithRetries(ctx, j)
+	}
+}
+
+// runWithRetries runs j, retrying after 1, 2, 4... times RetryDelay until it
+// succeeds, it has been retried Retries times or it is due to run again anyway.
+func (s *Scheduler) runWithRetries(ctx context.Context, j *job) {
+	delay := s.RetryDelay
+	for attempt := 1; ; attempt++ {
+		err := s.run(j, attempt)
+		if err == nil {
+			return
+		}
+
+		retryAt := time.Now().Add(delay)
+		if attempt > s.Retries || !retryAt.Before(j.Schedule.Next(time.Now().UTC())) {
+			slog.Error("scheduled job failed, giving up until its next run", "job", j.Name, "attempts", attempt, "error", err)
+			return
+		}
+		slog.Warn("scheduled job failed, retrying", "job", j.Name, "attempt", attempt, "retry_in", delay.String(), "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// RunNow runs the job straight away, once, waiting for any run already in
+// progress to finish first.
+func (s *Scheduler) RunNow(name string) error {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownJob, name)
+	}
+
+	return s.run(j, 1)
+}
+
+func (s *Scheduler) run(j *job, attempt int) error {
+	j.running.Lock()
+	defer j.running.Unlock()
+
+	start := time.Now().UTC()
+	s.update(j.Name, func(status *Status) {
+		status.Running = true
+		status.LastRun = &start
+		status.Attempts = attempt
+	})
+
+	err := j.Run()
+
+	s.update(j.Name, func(status *Status) {
+		status.Running = false
+		if err != nil {
+			status.LastError = err.Error()
+			return
+		}
+		finished := time.Now().UTC()
+		status.LastSuccess = &finished
+		status.LastError = ""
+	})
+	if err == nil {
+		slog.Info("scheduled job finished", "job", j.Name, "duration_ms", time.Since(start).Milliseconds())
+	}
+
+	return err
+}
+
+func (s *Scheduler) update(name string, f func(*Status)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if status, ok := s.status[name]; ok {
+		f(status)
+	}
+}
+
+// Status returns the result of the job's last run, and false if there is no
+// such job.
+func (s *Scheduler) Status(name string) (Status, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status, ok := s.status[name]
+	if !ok {
+		return Status{}, false
+	}
+	return *status, true
+}
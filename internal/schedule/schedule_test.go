@@ -0,0 +1,207 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubSchedule is always next due at the same time.
+type stubSchedule struct {
+	next time.Time
+}
+
+func (s stubSchedule) Next(time.Time) time.Time {
+	return s.next
+}
+
+// failingJob returns a job which fails until it has been called succeedOn
+// times, and the times it was called.
+func failingJob(name string, schedule stubSchedule, succeedOn int) (*job, func() []time.Time) {
+	var (
+		mu    sync.Mutex
+		calls []time.Time
+	)
+	j := &job{Job: Job{
+		Name:     name,
+		Schedule: schedule,
+		Run: func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, time.Now())
+			if len(calls) == succeedOn {
+				return nil
+			}
+			return errors.New("bucket unavailable")
+		},
+	}}
+	return j, func() []time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+}
+
+func newTestScheduler(retries int, j *job) *Scheduler {
+	s := New()
+	s.Retries = retries
+	s.RetryDelay = 50 * time.Millisecond
+	s.Add(j.Job)
+	s.jobs[j.Name] = j
+	return s
+}
+
+func TestRunWithRetriesBacksOff(t *testing.T) {
+	j, calls := failingJob(JobBackup, stubSchedule{time.Now().Add(time.Hour)}, 4)
+	s := newTestScheduler(5, j)
+	s.runWithRetries(context.Background(), j)
+
+	got := calls()
+	if len(got) != 4 {
+		t.Fatalf("ran %d times, want 4", len(got))
+	}
+	for i, factor := range []time.Duration{1, 2, 4} {
+		want := factor * s.RetryDelay
+		if gap := got[i+1].Sub(got[i]); gap < want || gap >= 2*want {
+			t.Errorf("retry %d came %v after the last attempt, want %v", i+1, gap, want)
+		}
+	}
+
+	status, _ := s.Status(JobBackup)
+	if !status.OK() || status.Attempts != 4 || status.LastSuccess == nil || status.Running {
+		t.Errorf("status after succeeding on a retry = %+v", status)
+	}
+}
+
+func TestRunWithRetriesGivesUp(t *testing.T) {
+	t.Run("after Retries retries", func(t *testing.T) {
+		j, calls := failingJob(JobBackup, stubSchedule{time.Now().Add(time.Hour)}, 0)
+		s := newTestScheduler(2, j)
+		s.runWithRetries(context.Background(), j)
+
+		if got := len(calls()); got != 3 {
+			t.Errorf("ran %d times, want the first attempt and 2 retries", got)
+		}
+		status, _ := s.Status(JobBackup)
+		if status.OK() || status.LastError != "bucket unavailable" || status.Attempts != 3 || status.LastSuccess != nil || status.Running {
+			t.Errorf("status after giving up = %+v", status)
+		}
+	})
+
+	t.Run("when the next run is due first", func(t *testing.T) {
+		// runs at 0, 50ms and 150ms, and the retry after that would be at
+		// 350ms, after the next run
+		j, calls := failingJob(JobBackup, stubSchedule{time.Now().Add(250 * time.Millisecond)}, 0)
+		s := newTestScheduler(5, j)
+		s.runWithRetries(context.Background(), j)
+
+		if got := len(calls()); got != 3 {
+			t.Errorf("ran %d times, want 3", got)
+		}
+	})
+
+	t.Run("when cancelled", func(t *testing.T) {
+		j, calls := failingJob(JobBackup, stubSchedule{time.Now().Add(time.Hour)}, 0)
+		s := newTestScheduler(5, j)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		s.runWithRetries(ctx, j)
+
+		if got := len(calls()); got != 1 {
+			t.Errorf("ran %d times after being cancelled, want 1", got)
+		}
+	})
+}
+
+func TestRunNowWaitsForRunInProgress(t *testing.T) {
+	var (
+		mu              sync.Mutex
+		active, maxRuns int
+		runs            int
+	)
+	started, release := make(chan struct{}), make(chan struct{})
+	s := New()
+	s.Add(Job{
+		Name:     JobBackup,
+		Schedule: stubSchedule{time.Now().Add(time.Hour)},
+		Run: func() error {
+			mu.Lock()
+			active++
+			runs++
+			maxRuns = max(maxRuns, active)
+			first := runs == 1
+			mu.Unlock()
+
+			if first {
+				close(started)
+				<-release
+			}
+
+			mu.Lock()
+			active--
+			mu.Unlock()
+			return nil
+		},
+	})
+
+	go s.RunNow(JobBackup)
+	<-started
+	if status, _ := s.Status(JobBackup); !status.Running || status.LastRun == nil {
+		t.Errorf("status while running = %+v", status)
+	}
+
+	done := make(chan error)
+	go func() { done <- s.RunNow(JobBackup) }()
+	select {
+	case <-done:
+		t.Fatal("RunNow returned while another run was in progress")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	if runs != 2 || maxRuns != 1 {
+		t.Errorf("ran %d times with up to %d at once, want 2 one at a time", runs, maxRuns)
+	}
+	mu.Unlock()
+	if status, _ := s.Status(JobBackup); status.Running || !status.OK() || status.LastSuccess == nil || status.Attempts != 1 {
+		t.Errorf("status after running = %+v", status)
+	}
+
+	if err := s.RunNow("nightly"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("RunNow of an unknown job = %v, want ErrUnknownJob", err)
+	}
+}
+
+func TestStartSetsNextRun(t *testing.T) {
+	next := time.Now().Add(time.Hour).UTC()
+	j, _ := failingJob(JobBackup, stubSchedule{next}, 1)
+	s := newTestScheduler(5, j)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if status, _ := s.Status(JobBackup); status.NextRun != nil {
+			if !status.NextRun.Equal(next) {
+				t.Errorf("NextRun = %v, want %v", status.NextRun, next)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("NextRun was never set")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, ok := s.Status("nightly"); ok {
+		t.Error("got a status for a job that wasn't added")
+	}
+}
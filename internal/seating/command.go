@@ -0,0 +1,175 @@
+package seating
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+type Store interface {
+	GetAllGuests(includeDeleted bool) ([]models.Guest, error)
+	GetTables() ([]models.Table, error)
+	ReplaceSeatingPlan(seats map[int]int, actor string) error
+}
+
+// pairsFlag collects every -apart flag given on the command line.
+type pairsFlag [][2]string
+
+func (p *pairsFlag) String() string {
+	return fmt.Sprint(*p)
+}
+
+func (p *pairsFlag) Set(v string) error {
+	a, b, ok := strings.Cut(v, ",")
+	if !ok || a == "" || b == "" {
+		return fmt.Errorf("expected two guest codes separated by a comma, got %q", v)
+	}
+	*p = append(*p, [2]string{strings.TrimSpace(a), strings.TrimSpace(b)})
+	return nil
+}
+
+// RunCommand seats every guest who has accepted and prints the plan for
+// review along with a fingerprint of everything it was made from. The plan is
+// only saved, replacing the current one, when -save is given that
+// fingerprint. As the same input and seed always give the same plan, a
+// matching fingerprint means the plan saved is the one reviewed; if a guest
+// has responded or a table changed since, nothing is saved.
+func RunCommand(store Store, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("seat", flag.ContinueOnError)
+	flags.SetOutput(out)
+	seed := flags.Int64("seed", 1, "seed for the solver, the same seed gives the same plan")
+	iterations := flags.Int("iterations", DefaultIterations, "number of improvements to try")
+	save := flags.String("save", "", "replace the current seating plan with this one, given the fingerprint printed with the plan reviewed")
+	var keepApart pairsFlag
+	flags.Var(&keepApart, "apart", "two guest codes separated by a comma who mustn't share a table, can be repeated")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	guests, err := store.GetAllGuests(false)
+	if err != nil {
+		return err
+	}
+
+	var attending []models.Guest
+	guestsByCode := make(map[string]models.Guest)
+	for _, g := range guests {
+		if g.FormStarted && g.Attendance {
+			attending = append(attending, g)
+			guestsByCode[g.Code] = g
+		}
+	}
+	for _, pair := range keepApart {
+		for _, code := range pair {
+			if _, ok := guestsByCode[code]; !ok {
+				return fmt.Errorf("guest %s in -apart hasn't accepted or doesn't exist", code)
+			}
+		}
+	}
+
+	tables, err := store.GetTables()
+	if err != nil {
+		return err
+	}
+	if len(tables) == 0 {
+		return fmt.Errorf("there are no tables, create them with /api/create-table first")
+	}
+
+	problem := Problem{Guests: attending, Tables: tables, KeepApart: keepApart}
+	solution, err := Solve(problem, *seed, *iterations)
+	if err != nil {
+		return err
+	}
+
+	printSolution(out, problem, solution, *seed)
+	planFingerprint := fingerprint(problem, *seed, *iterations)
+	fmt.Fprintf(out, "\nFingerprint: %s\n", planFingerprint)
+
+	if *save == "" {
+		fmt.Fprintf(out, "\nThis plan has not been saved. Run again with the same flags and -save %s to save it.\n", planFingerprint)
+		return nil
+	}
+	if *save != planFingerprint {
+		return fmt.Errorf("the guests, tables or flags have changed since the plan with fingerprint %s was made, so this is a different plan. Review it and save it with -save %s", *save, planFingerprint)
+	}
+
+	if err := store.ReplaceSeatingPlan(solution.Seats, models.ActorAdmin); err != nil {
+		return fmt.Errorf("failed to save seating plan: %v", err)
+	}
+	fmt.Fprintln(out, "\nSeating plan saved.")
+	return nil
+}
+
+// fingerprint identifies everything the solver uses to make a plan, so the
+// same fingerprint means the same plan.
+func fingerprint(problem Problem, seed int64, iterations int) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "seed %d iterations %d\n", seed, iterations)
+	for _, g := range problem.Guests {
+		fmt.Fprintf(hash, "guest %d %q %q %q\n", g.ID, g.Code, g.Household, g.Tags)
+	}
+	for _, t := range problem.Tables {
+		fmt.Fprintf(hash, "table %d %q %d\n", t.ID, t.Name, t.Capacity)
+	}
+	keepApart := slices.Clone(problem.KeepApart)
+	slices.SortFunc(keepApart, func(a, b [2]string) int {
+		return strings.Compare(a[0]+","+a[1], b[0]+","+b[1])
+	})
+	for _, pair := range keepApart {
+		fmt.Fprintf(hash, "apart %q %q\n", pair[0], pair[1])
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:12]
+}
+
+func printSolution(out io.Writer, problem Problem, solution Solution, seed int64) {
+	fmt.Fprintf(out, "Seating plan for %d guests (seed %d, score %d)\n", len(problem.Guests), seed, solution.Score)
+
+	for _, t := range problem.Tables {
+		var seated []models.Guest
+		for _, g := range problem.Guests {
+			if solution.Seats[g.ID] == t.ID {
+				seated = append(seated, g)
+			}
+		}
+
+		fmt.Fprintf(out, "\n%s (%s, %d/%d)\n", t.Name, t.Shape, len(seated), t.Capacity)
+		for _, g := range seated {
+			fmt.Fprintf(out, "  %s (%s)%s\n", g.Name, g.Code, describeGuest(g))
+		}
+	}
+
+	if len(solution.Unseated) > 0 {
+		fmt.Fprintf(out, "\nNo room for %d guests:\n", len(solution.Unseated))
+		for _, g := range solution.Unseated {
+			fmt.Fprintf(out, "  %s (%s)%s\n", g.Name, g.Code, describeGuest(g))
+		}
+	}
+
+	fmt.Fprintf(out, "\nPairs of guests sharing a tag at the same table: %d\n", solution.TagPairs)
+	if len(solution.Violations) > 0 {
+		fmt.Fprintf(out, "Guests who should be kept apart but couldn't be:\n")
+		for _, pair := range solution.Violations {
+			fmt.Fprintf(out, "  %s and %s\n", pair[0], pair[1])
+		}
+	}
+}
+
+func describeGuest(g models.Guest) string {
+	var details []string
+	if g.Household != "" {
+		details = append(details, "household: "+g.Household)
+	}
+	if len(g.Tags) > 0 {
+		details = append(details, "tags: "+strings.Join(g.Tags, ", "))
+	}
+	if len(details) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(details, "; ") + "]"
+}
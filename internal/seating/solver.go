@@ -0,0 +1,292 @@
+package seating
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+// Penalties are large enough that no number of shared tags outweighs leaving
+// a guest without a seat or sitting a "keep apart" pair together.
+const (
+	unseatedPenalty  = 10000
+	keepApartPenalty = 1000
+	sharedTagScore   = 1
+
+	DefaultIterations = 50000
+)
+
+// Problem is everything the solver needs to seat the guests. Guests in the
+// same household always sit together, KeepApart lists pairs of guest codes
+// that mustn't share a table, and guests sharing a tag are seated together
+// where possible.
+type Problem struct {
+	Guests    []models.Guest
+	Tables    []models.Table
+	KeepApart [][2]string
+}
+
+// Solution maps each seated guest's id to their table id.
+type Solution struct {
+	Seats      map[int]int
+	Unseated   []models.Guest
+	Violations [][2]string
+	TagPairs   int
+	Score      int
+}
+
+// unit is a group of guests that have to be seated at the same table.
+type unit struct {
+	guests []models.Guest
+}
+
+type solver struct {
+	problem   Problem
+	units     []unit
+	keepApart map[string]map[string]bool
+	// assignment holds the table index of each unit, or -1 when unseated
+	assignment []int
+	load       []int
+}
+
+// Solve searches for the seating plan with the best score using simulated
+// annealing. The same problem and seed always give the same plan.
+func Solve(problem Problem, seed int64, iterations int) (Solution, error) {
+	s := newSolver(problem)
+
+	maxCapacity := 0
+	for _, t := range problem.Tables {
+		maxCapacity = max(maxCapacity, t.Capacity)
+	}
+	for _, u := range s.units {
+		if len(u.guests) > maxCapacity {
+			return Solution{}, fmt.Errorf("household %q has %d guests but the largest table seats %d", u.guests[0].Household, len(u.guests), maxCapacity)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	s.seatGreedily(rng)
+
+	score := s.score()
+	best := slices.Clone(s.assignment)
+	bestScore := score
+
+	const startTemperature, endTemperature = 5.0, 0.05
+	for iter := 0; iter < iterations && len(s.units) > 1; iter++ {
+		temperature := startTemperature * math.Pow(endTemperature/startTemperature, float64(iter)/float64(iterations))
+
+		undo, ok := s.randomMove(rng)
+		if !ok {
+			continue
+		}
+
+		newScore := s.score()
+		delta := newScore - score
+		if delta >= 0 || rng.Float64() < math.Exp(float64(delta)/temperature) {
+			score = newScore
+			if score > bestScore {
+				bestScore = score
+				best = slices.Clone(s.assignment)
+			}
+		} else {
+			undo()
+		}
+	}
+
+	s.setAssignment(best)
+	return s.solution(), nil
+}
+
+func newSolver(problem Problem) *solver {
+	s := &solver{
+		problem:   problem,
+		keepApart: make(map[string]map[string]bool),
+		load:      make([]int, len(problem.Tables)),
+	}
+
+	householdIdx := make(map[string]int)
+	for _, g := range problem.Guests {
+		if g.Household != "" {
+			if idx, ok := householdIdx[g.Household]; ok {
+				s.units[idx].guests = append(s.units[idx].guests, g)
+				continue
+			}
+			householdIdx[g.Household] = len(s.units)
+		}
+		s.units = append(s.units, unit{guests: []models.Guest{g}})
+	}
+
+	for _, pair := range problem.KeepApart {
+		for _, p := range [][2]string{pair, {pair[1], pair[0]}} {
+			if s.keepApart[p[0]] == nil {
+				s.keepApart[p[0]] = make(map[string]bool)
+			}
+			s.keepApart[p[0]][p[1]] = true
+		}
+	}
+
+	s.assignment = make([]int, len(s.units))
+	for idx := range s.assignment {
+		s.assignment[idx] = -1
+	}
+
+	return s
+}
+
+// seatGreedily gives the solver a starting point by placing the largest
+// units first, each at a random table with room for it.
+func (s *solver) seatGreedily(rng *rand.Rand) {
+	order := rng.Perm(len(s.units))
+	slices.SortStableFunc(order, func(a, b int) int {
+		return len(s.units[b].guests) - len(s.units[a].guests)
+	})
+
+	for _, u := range order {
+		for _, t := range rng.Perm(len(s.problem.Tables)) {
+			if s.fits(u, t) {
+				s.place(u, t)
+				break
+			}
+		}
+	}
+}
+
+func (s *solver) fits(u, t int) bool {
+	return t == -1 || s.load[t]+len(s.units[u].guests) <= s.problem.Tables[t].Capacity
+}
+
+func (s *solver) place(u, t int) {
+	if old := s.assignment[u]; old != -1 {
+		s.load[old] -= len(s.units[u].guests)
+	}
+	s.assignment[u] = t
+	if t != -1 {
+		s.load[t] += len(s.units[u].guests)
+	}
+}
+
+func (s *solver) setAssignment(assignment []int) {
+	for u, t := range assignment {
+		s.place(u, t)
+	}
+}
+
+// randomMove either moves a unit to another table or swaps two units between
+// tables, returning a function that reverts the change.
+func (s *solver) randomMove(rng *rand.Rand) (func(), bool) {
+	u := rng.Intn(len(s.units))
+	from := s.assignment[u]
+	to := rng.Intn(len(s.problem.Tables)+1) - 1
+	if to == from {
+		return nil, false
+	}
+
+	if s.fits(u, to) {
+		s.place(u, to)
+		return func() { s.place(u, from) }, true
+	}
+
+	// The table is full so try swapping with someone sat there
+	var candidates []int
+	for v, t := range s.assignment {
+		if t == to {
+			candidates = append(candidates, v)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, false
+	}
+	v := candidates[rng.Intn(len(candidates))]
+
+	s.place(u, -1)
+	s.place(v, -1)
+	if s.fits(u, to) && s.fits(v, from) {
+		s.place(u, to)
+		s.place(v, from)
+		return func() {
+			s.place(u, -1)
+			s.place(v, -1)
+			s.place(u, from)
+			s.place(v, to)
+		}, true
+	}
+	s.place(u, from)
+	s.place(v, to)
+	return nil, false
+}
+
+func (s *solver) tables() [][]models.Guest {
+	tables := make([][]models.Guest, len(s.problem.Tables))
+	for u, t := range s.assignment {
+		if t != -1 {
+			tables[t] = append(tables[t], s.units[u].guests...)
+		}
+	}
+	return tables
+}
+
+func (s *solver) score() int {
+	score := 0
+	for u, t := range s.assignment {
+		if t == -1 {
+			score -= unseatedPenalty * len(s.units[u].guests)
+		}
+	}
+
+	for _, guests := range s.tables() {
+		violations, tagPairs := scoreTable(guests, s.keepApart)
+		score += tagPairs*sharedTagScore - len(violations)*keepApartPenalty
+	}
+
+	return score
+}
+
+// scoreTable finds the "keep apart" pairs sat together and counts the pairs
+// of guests sharing a tag, once for every tag they share.
+func scoreTable(guests []models.Guest, keepApart map[string]map[string]bool) ([][2]string, int) {
+	var violations [][2]string
+	tagCounts := make(map[string]int)
+
+	for idx, g := range guests {
+		for _, other := range guests[idx+1:] {
+			if keepApart[g.Code][other.Code] {
+				violations = append(violations, [2]string{g.Code, other.Code})
+			}
+		}
+		for _, tag := range g.Tags {
+			tagCounts[tag]++
+		}
+	}
+
+	tagPairs := 0
+	for _, n := range tagCounts {
+		tagPairs += n * (n - 1) / 2
+	}
+
+	return violations, tagPairs
+}
+
+func (s *solver) solution() Solution {
+	solution := Solution{Seats: make(map[int]int), Score: s.score()}
+
+	for u, t := range s.assignment {
+		for _, g := range s.units[u].guests {
+			if t == -1 {
+				solution.Unseated = append(solution.Unseated, g)
+			} else {
+				solution.Seats[g.ID] = s.problem.Tables[t].ID
+			}
+		}
+	}
+
+	for _, guests := range s.tables() {
+		violations, tagPairs := scoreTable(guests, s.keepApart)
+		solution.Violations = append(solution.Violations, violations...)
+		solution.TagPairs += tagPairs
+	}
+
+	return solution
+}
@@ -0,0 +1,219 @@
+package seating
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+func testGuest(id int, household string, tags ...string) models.Guest {
+	return models.Guest{
+		ID:          id,
+		Name:        fmt.Sprintf("Guest %d", id),
+		Code:        fmt.Sprintf("Guest-%06d", id),
+		Household:   household,
+		Tags:        tags,
+		FormStarted: true,
+		Attendance:  true,
+	}
+}
+
+// testProblem is 3 tables of 4 and 10 guests in households of 1 to 3, with
+// tags that can't all be satisfied.
+func testProblem() Problem {
+	return Problem{
+		Guests: []models.Guest{
+			testGuest(1, "smiths", "family"),
+			testGuest(2, "smiths", "family"),
+			testGuest(3, "smiths"),
+			testGuest(4, "joneses", "university"),
+			testGuest(5, "joneses", "university"),
+			testGuest(6, "", "university"),
+			testGuest(7, "", "work"),
+			testGuest(8, "", "work"),
+			testGuest(9, "", "family"),
+			testGuest(10, "", "work", "university"),
+		},
+		Tables: []models.Table{
+			{ID: 1, Name: "Oak", Capacity: 4},
+			{ID: 2, Name: "Ash", Capacity: 4},
+			{ID: 3, Name: "Elm", Capacity: 4},
+		},
+	}
+}
+
+func mustSolve(t *testing.T, problem Problem, seed int64) Solution {
+	t.Helper()
+	solution, err := Solve(problem, seed, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return solution
+}
+
+func TestSolveIsRepeatable(t *testing.T) {
+	problem := testProblem()
+	first := mustSolve(t, problem, 7)
+	for range 3 {
+		if again := mustSolve(t, problem, 7); !maps.Equal(again.Seats, first.Seats) || again.Score != first.Score {
+			t.Fatalf("seed 7 gave %v then %v", first.Seats, again.Seats)
+		}
+	}
+}
+
+func TestSolveSeatsHouseholdsTogether(t *testing.T) {
+	problem := testProblem()
+	for seed := int64(1); seed <= 20; seed++ {
+		solution := mustSolve(t, problem, seed)
+		if len(solution.Unseated) != 0 {
+			t.Errorf("seed %d left %d guests unseated with room for everyone", seed, len(solution.Unseated))
+		}
+
+		households := make(map[string]int)
+		for _, g := range problem.Guests {
+			if g.Household == "" {
+				continue
+			}
+			table, ok := households[g.Household]
+			if !ok {
+				households[g.Household] = solution.Seats[g.ID]
+			} else if solution.Seats[g.ID] != table {
+				t.Errorf("seed %d split household %s", seed, g.Household)
+			}
+		}
+	}
+
+	problem.Guests = append(problem.Guests, testGuest(11, "smiths"), testGuest(12, "smiths"))
+	if _, err := Solve(problem, 1, 100); err == nil {
+		t.Error("seated a household of 5 at tables of 4")
+	}
+}
+
+func TestSolveKeepsPairsApart(t *testing.T) {
+	problem := testProblem()
+	// guests 7, 8 and 10 share a tag so would otherwise sit together
+	problem.KeepApart = [][2]string{{"Guest-000007", "Guest-000008"}, {"Guest-000010", "Guest-000007"}}
+
+	for seed := int64(1); seed <= 20; seed++ {
+		solution := mustSolve(t, problem, seed)
+		for _, pair := range [][2]int{{7, 8}, {10, 7}} {
+			if solution.Seats[pair[0]] == solution.Seats[pair[1]] {
+				t.Errorf("seed %d sat guests %d and %d together", seed, pair[0], pair[1])
+			}
+		}
+		if len(solution.Violations) != 0 {
+			t.Errorf("seed %d reported violations %v", seed, solution.Violations)
+		}
+	}
+}
+
+func TestSolveRespectsCapacity(t *testing.T) {
+	problem := testProblem()
+	// 10 guests but only 8 seats
+	problem.Tables = problem.Tables[:2]
+
+	for seed := int64(1); seed <= 20; seed++ {
+		solution := mustSolve(t, problem, seed)
+		seated := make(map[int]int)
+		for _, table := range solution.Seats {
+			seated[table]++
+		}
+		for _, table := range problem.Tables {
+			if seated[table.ID] > table.Capacity {
+				t.Errorf("seed %d sat %d at %s, which seats %d", seed, seated[table.ID], table.Name, table.Capacity)
+			}
+		}
+		if got := len(solution.Seats) + len(solution.Unseated); got != len(problem.Guests) {
+			t.Errorf("seed %d seated or listed %d of %d guests", seed, got, len(problem.Guests))
+		}
+		if len(solution.Unseated) != 2 {
+			t.Errorf("seed %d left %d unseated, want the 2 there's no room for", seed, len(solution.Unseated))
+		}
+	}
+}
+
+type fakeStore struct {
+	guests []models.Guest
+	tables []models.Table
+	saved  map[int]int
+}
+
+func (f *fakeStore) GetAllGuests(includeDeleted bool) ([]models.Guest, error) {
+	return f.guests, nil
+}
+
+func (f *fakeStore) GetTables() ([]models.Table, error) {
+	return f.tables, nil
+}
+
+func (f *fakeStore) ReplaceSeatingPlan(seats map[int]int, actor string) error {
+	f.saved = seats
+	return nil
+}
+
+var reFingerprint = regexp.MustCompile(`Fingerprint: ([0-9a-f]+)`)
+
+func runSeat(t *testing.T, store *fakeStore, args ...string) (string, error) {
+	t.Helper()
+	var out strings.Builder
+	err := RunCommand(store, args, &out)
+	return out.String(), err
+}
+
+func TestRunCommandSavesReviewedPlan(t *testing.T) {
+	problem := testProblem()
+	declined := testGuest(20, "")
+	declined.Attendance = false
+	notResponded := testGuest(21, "")
+	notResponded.FormStarted, notResponded.Attendance = false, false
+	store := &fakeStore{guests: append(problem.Guests, declined, notResponded), tables: problem.Tables}
+
+	out, err := runSeat(t, store, "-seed", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.saved != nil {
+		t.Fatal("the plan was saved without -save")
+	}
+	match := reFingerprint.FindStringSubmatch(out)
+	if match == nil {
+		t.Fatalf("no fingerprint in:\n%s", out)
+	}
+
+	// a different seed is a different plan
+	if _, err := runSeat(t, store, "-seed", "4", "-save", match[1]); err == nil || store.saved != nil {
+		t.Error("saved a plan made with a different seed")
+	}
+
+	// so is one made after another guest accepts
+	store.guests = append(store.guests, testGuest(22, ""))
+	if _, err := runSeat(t, store, "-seed", "3", "-save", match[1]); err == nil || store.saved != nil {
+		t.Error("saved a plan made after a guest accepted")
+	}
+	store.guests = store.guests[:len(store.guests)-1]
+
+	if _, err := runSeat(t, store, "-seed", "3", "-save", match[1]); err != nil {
+		t.Fatal(err)
+	}
+	if want := mustSolve(t, problem, 3); len(store.saved) != len(want.Seats) {
+		t.Errorf("saved %d seats, want %d", len(store.saved), len(want.Seats))
+	}
+	for _, id := range []int{declined.ID, notResponded.ID} {
+		if _, ok := store.saved[id]; ok {
+			t.Errorf("guest %d, who hasn't accepted, was seated", id)
+		}
+	}
+}
+
+func TestRunCommandRejectsUnknownApartGuest(t *testing.T) {
+	problem := testProblem()
+	store := &fakeStore{guests: problem.Guests, tables: problem.Tables}
+	if err := RunCommand(store, []string{"-apart", "Guest-000001,Nobody-000000"}, io.Discard); err == nil {
+		t.Error("accepted -apart with a guest who doesn't exist")
+	}
+}
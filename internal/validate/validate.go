@@ -0,0 +1,56 @@
+package validate
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/nesquikmike/wedding-rsvps/internal/models"
+)
+
+var (
+	rePhoneNumber         = regexp.MustCompile(`^[0-9+][0-9]+$`)
+	reDietaryRequirements = regexp.MustCompile(`^(?:(?:[A-Za-z’\'\.\,!\"#&()\-£$\d*?/~@\[\]\{\}=+_^%|]{1,100})(?:\s+|$|\.))*(?:[A-Za-z\'’\.\,!\"#&()\-£$\d*?/~@\[\]\{\}=+_^%|]{1,100})$`)
+	reAddressField        = regexp.MustCompile(`^[\p{L}\p{N} ’'\.,\-/&()#]{0,100}$`)
+	rePostcode            = regexp.MustCompile(`^[A-Z0-9][A-Z0-9 \-]{1,9}$`)
+)
+
+func Email(email string) bool {
+	return strings.Contains(email, "@") && strings.Contains(email, ".") && len(email) >= 6
+}
+
+// PhoneNumber expects spaces to have already been removed from phoneNumber.
+func PhoneNumber(phoneNumber string) bool {
+	return rePhoneNumber.MatchString(phoneNumber)
+}
+
+// DietaryRequirements expects newlines to have already been replaced and the
+// input trimmed. An empty string is valid.
+func DietaryRequirements(dietaryRequirements string) bool {
+	switch {
+	case len(dietaryRequirements) > 500:
+		return false
+	case len(dietaryRequirements) > 0 && !reDietaryRequirements.MatchString(dietaryRequirements):
+		return false
+	}
+	return true
+}
+
+// Address allows the address to be left blank but if any of it is given then
+// there needs to be enough to get post to the guest.
+func Address(address models.Address) bool {
+	if address.IsEmpty() {
+		return true
+	}
+
+	if address.Line1 == "" || address.City == "" || address.Postcode == "" {
+		return false
+	}
+
+	for _, field := range []string{address.Line1, address.Line2, address.City, address.County, address.Country} {
+		if !reAddressField.MatchString(field) {
+			return false
+		}
+	}
+
+	return rePostcode.MatchString(address.Postcode)
+}
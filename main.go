@@ -1,138 +1,200 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"database/sql"
 	"encoding/csv"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/nesquikmike/wedding-rsvps/internal/backup"
+	"github.com/nesquikmike/wedding-rsvps/internal/config"
+	"github.com/nesquikmike/wedding-rsvps/internal/content"
 	"github.com/nesquikmike/wedding-rsvps/internal/controllers"
 	"github.com/nesquikmike/wedding-rsvps/internal/database"
+	"github.com/nesquikmike/wedding-rsvps/internal/guestlist"
+	"github.com/nesquikmike/wedding-rsvps/internal/i18n"
+	"github.com/nesquikmike/wedding-rsvps/internal/logging"
+	"github.com/nesquikmike/wedding-rsvps/internal/metrics"
 	"github.com/nesquikmike/wedding-rsvps/internal/models"
+	"github.com/nesquikmike/wedding-rsvps/internal/schedule"
+	"github.com/nesquikmike/wedding-rsvps/internal/seating"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 var tpl *template.Template
 
+// logOutput is where the logs are written, which changes to a new file each
+// day
+var logOutput = logging.NewOutput(os.Stdout)
+
 const (
-	requiredLenSecretCookieKey = 32
-	csvPath                    = "./names.csv"
-	guestsDBFilePath           = "./guests.db"
-	backupTimeInterval         = 24 * time.Hour
+	csvPath          = "./names.csv"
+	guestsDBFilePath = "./guests.db"
 )
 
 func init() {
-	tpl = template.Must(template.ParseGlob("templates/*.gohtml"))
+	tpl = template.Must(template.New("").Funcs(content.FuncMap).Funcs(i18n.FuncMap).ParseGlob("templates/*.gohtml"))
 	template.Must(tpl.ParseGlob("templates/form/*.gohtml"))
 }
 
 func main() {
-	file, err := os.Open(".env")
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	cfg, err := config.LoadDefault()
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer file.Close()
 
-	envVars := make(map[string]string)
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		l := scanner.Text()
-		k, v, ok := strings.Cut(l, "=")
-		if !ok {
-			log.Fatal(fmt.Errorf("no equal sign to split env key-value pair"))
-		}
-		v = strings.Trim(v, "\"'")
-
-		envVars[k] = v
+	// a message missing from a catalogue would show guests its key instead
+	if problems := append(i18n.Check(), i18n.CheckTemplates(tpl)...); len(problems) > 0 {
+		log.Fatalf("message catalogues are incomplete:\n  %s", strings.Join(problems, "\n  "))
 	}
 
-	isProd := envVars["ENVIRONMENT"] == "production"
+	logLevel, err := logging.ParseLevel(cfg.Logging.Level)
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger := logging.New(logOutput, logLevel)
+	slog.SetDefault(logger)
 
 	logFile, err := setNewLogFile(nil)
 	if err != nil {
-		log.Fatalf("error opening log file: %v", err)
+		fatal("error opening log file", err)
 	}
 	defer logFile.Close()
 
-	secretCookieKey, err := hex.DecodeString(envVars["SECRET_COOKIE_KEY"])
+	secretCookieKey, err := cfg.CookieKey()
 	if err != nil {
-		log.Fatal(err)
-	}
-
-	if len(secretCookieKey) != requiredLenSecretCookieKey {
-		log.Fatal(fmt.Errorf("secretCookieKey is %v bytes long when it should be %v", len(secretCookieKey), requiredLenSecretCookieKey))
+		fatal("invalid SECRET_COOKIE_KEY", err)
 	}
 
 	// Open the SQLite database (creates the file if it doesn't exist)
-	db, err := sql.Open("sqlite3", guestsDBFilePath)
+	db, err := sql.Open(database.DriverName, guestsDBFilePath)
 	if err != nil {
-		log.Fatal("Error opening up database: ", err)
+		fatal("error opening up database", err)
 	}
 	defer db.Close()
 
 	rows, err := readCSV(csvPath)
 	if err != nil {
-		log.Fatal("Error reading csv: ", err)
+		fatal("error reading csv", err)
 	}
 
 	guestStore := database.NewGuestStore(db)
-	err = guestStore.SetupDatabase(rows)
+	if guestlist.HasHeader(rows) {
+		err = guestStore.SetupDatabase(nil)
+		if err != nil {
+			fatal("error setting up database", err)
+		}
+
+		// The csv is only compared on start up. Importing it could replace
+		// details guests have given, so is left to the import-guests command
+		// or /api/import-guests once the changes have been reviewed.
+		plan, err := guestlist.Import(guestStore, rows, true, models.ActorAdmin)
+		if err != nil {
+			fatal("error checking guest list", err)
+		}
+		if len(plan.New) > 0 || len(plan.Changed) > 0 {
+			slog.Warn("names.csv has changes which haven't been imported, review them with ./wedding-rsvps import-guests -dry-run",
+				"new", len(plan.New),
+				"changed", len(plan.Changed),
+				"missing", len(plan.Missing),
+			)
+		}
+	} else {
+		err = guestStore.SetupDatabase(rows)
+		if err != nil {
+			fatal("error setting up database", err)
+		}
+	}
+
+	siteContent, err := loadContent(guestStore, cfg)
+	if err != nil {
+		fatal("error loading content", err)
+	}
+
+	backupTargets, err := newBackupTargets(cfg.Backup)
 	if err != nil {
-		log.Fatal("Error setting up database: ", err)
+		fatal("error setting up backup targets", err)
 	}
 
-	s3BucketBackups := envVars["S3_BUCKET_BACKUPS"]
-	var s3Uploader *backup.S3Uploader
-	if s3BucketBackups != "" {
-		s3Uploader, err = backup.NewS3Uploader(s3BucketBackups, isProd)
+	var backupKey []byte
+	if len(backupTargets) > 0 {
+		backupKey, err = cfg.BackupKey()
 		if err != nil {
-			log.Fatal("Error setting up S3Uploader: ", err)
+			fatal("error parsing BACKUP_ENCRYPTION_KEY", err)
 		}
 	}
 
-	go startMidnightTicker(s3Uploader, logFile)
+	scheduler, err := newScheduler(cfg, db, backupTargets, backupKey, logFile)
+	if err != nil {
+		fatal("error setting up schedules", err)
+	}
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+	scheduler.Start(schedulerCtx)
 
-	apiKey := envVars["API_KEY"]
+	apiKey := cfg.APIKey.Value()
 
 	viewData := models.ViewData{
-		Url:                   envVars["URL"],
-		PartnerOne:            envVars["PARTNER_ONE"],
-		PartnerTwo:            envVars["PARTNER_TWO"],
-		Date:                  envVars["DATE"],
-		VenueVague:            envVars["VENUE_VAGUE"],
-		VenueAddress:          template.HTML(strings.ReplaceAll(envVars["VENUE_ADDRESS"], "\\", "")),
-		VenueTravelDetails:    template.HTML(strings.ReplaceAll(envVars["VENUE_TRAVEL_DETAILS"], "\\", "")),
-		TimeStart:             envVars["TIME_START"],
-		TimeArrival:           envVars["TIME_ARRIVAL"],
-		MainPhotoFileName:     envVars["MAIN_PHOTO_FILE_NAME"],
-		PostCeremonyItinerary: template.HTML(strings.ReplaceAll(envVars["POST_CEREMONY_ITINERARY"], "\\", "")),
-		BankName:              envVars["BANK_NAME"],
-		BankAccountName:       envVars["BANK_ACCOUNT_NAME"],
-		BankSortCode:          envVars["BANK_SORT_CODE"],
-		BankAccountNumber:     envVars["BANK_ACCOUNT_NUMBER"],
-		FooterMessage:         template.HTML(strings.ReplaceAll(envVars["FOOTER_MESSAGE"], "\\", "")),
-	}
-
-	s3BucketAssets := envVars["S3_BUCKET_ASSETS"]
+		Url:               cfg.URL,
+		PartnerOne:        cfg.Wedding.PartnerOne,
+		PartnerTwo:        cfg.Wedding.PartnerTwo,
+		Date:              cfg.Wedding.Date,
+		RSVPBy:            cfg.Wedding.RSVPBy.Time,
+		VenueVague:        cfg.Wedding.VenueVague,
+		TimeStart:         cfg.Wedding.TimeStart,
+		TimeArrival:       cfg.Wedding.TimeArrival,
+		MainPhotoFileName: cfg.Wedding.MainPhotoFileName,
+		BankName:          cfg.Bank.Name,
+		BankAccountName:   cfg.Bank.AccountName,
+		BankSortCode:      cfg.Bank.SortCode,
+		BankAccountNumber: cfg.Bank.AccountNumber,
+		Content:           siteContent,
+		PagesPublic:       cfg.PagesVisibleTo == config.PagesVisibleToEveryone,
+	}
+
+	s3BucketAssets := cfg.S3BucketAssets
+
+	// Guests can see their table from this date, leave unset to keep it hidden
+	seatingVisibleFrom := cfg.SeatingVisibleFrom.Time
 
 	srv := &http.Server{
-		Addr: ":8080",
+		Addr:    ":8080",
+		Handler: logging.Middleware(logger, http.DefaultServeMux, metrics.Middleware(http.DefaultServeMux)),
+	}
+
+	// Metrics are served on their own listener, e.g. localhost:9090, so they
+	// aren't public. Leave METRICS_ADDR unset to turn them off.
+	var metricsSrv *http.Server
+	if metricsAddr := cfg.MetricsAddr; metricsAddr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", metrics.Handler())
+		metricsSrv = &http.Server{
+			Addr:    metricsAddr,
+			Handler: metricsMux,
+		}
 	}
 
-	c := controllers.NewController(isProd, tpl, guestStore, log.Default(), &viewData, secretCookieKey, apiKey, s3BucketAssets)
+	c := controllers.NewController(cfg.IsProd(), tpl, guestStore, logger, &viewData, secretCookieKey, apiKey, s3BucketAssets, seatingVisibleFrom, cfg.TrustedProxies, scheduler)
 	if s3BucketAssets != "" {
 		http.HandleFunc("/assets/", c.StaticHandler)
 	} else {
@@ -144,10 +206,42 @@ func main() {
 	http.HandleFunc("/change-details", c.ChangeDetails)
 	http.HandleFunc("/change-attendance-response", c.ChangeAttendanceResponse)
 	http.HandleFunc("/reset-guest", c.ResetGuest)
+	http.HandleFunc("/faq", c.FAQ)
+	http.HandleFunc("/travel", c.Travel)
+	http.HandleFunc("/accommodation", c.Accommodation)
 	http.HandleFunc("/api/add-guest", c.ApiKeyMiddleware(c.AddGuest))
 	http.HandleFunc("/api/get-guest", c.ApiKeyMiddleware(c.GetGuest))
 	http.HandleFunc("/api/get-rsvps", c.ApiKeyMiddleware(c.GetRSVPs))
 	http.HandleFunc("/api/get-visits-data", c.ApiKeyMiddleware(c.GetVisitsData))
+	http.HandleFunc("/api/get-visit-events", c.ApiKeyMiddleware(c.GetVisitEvents))
+	http.HandleFunc("/api/get-invitation-inserts", c.ApiKeyMiddleware(c.GetInvitationInserts))
+	http.HandleFunc("/api/get-addresses", c.ApiKeyMiddleware(c.GetAddresses))
+	http.HandleFunc("/api/get-address-labels", c.ApiKeyMiddleware(c.GetAddressLabels))
+	http.HandleFunc("/api/import-guests", c.ApiKeyMiddleware(c.ImportGuests))
+	http.HandleFunc("/api/rename-guest", c.ApiKeyMiddleware(c.RenameGuest))
+	http.HandleFunc("/api/delete-guest", c.ApiKeyMiddleware(c.DeleteGuest))
+	http.HandleFunc("/api/restore-guest", c.ApiKeyMiddleware(c.RestoreGuest))
+	http.HandleFunc("/api/merge-guests", c.ApiKeyMiddleware(c.MergeGuests))
+	http.HandleFunc("/api/get-guest-timeline", c.ApiKeyMiddleware(c.GetGuestTimeline))
+	http.HandleFunc("/api/create-table", c.ApiKeyMiddleware(c.CreateTable))
+	http.HandleFunc("/api/update-table", c.ApiKeyMiddleware(c.UpdateTable))
+	http.HandleFunc("/api/delete-table", c.ApiKeyMiddleware(c.DeleteTable))
+	http.HandleFunc("/api/assign-seat", c.ApiKeyMiddleware(c.AssignSeat))
+	http.HandleFunc("/api/unassign-seat", c.ApiKeyMiddleware(c.UnassignSeat))
+	http.HandleFunc("/api/get-seating-plan", c.ApiKeyMiddleware(c.GetSeatingPlan))
+	http.HandleFunc("/api/get-place-cards", c.ApiKeyMiddleware(c.GetPlaceCards))
+	http.HandleFunc("/api/get-table-plan", c.ApiKeyMiddleware(c.GetTablePlan))
+	http.HandleFunc("/api/get-stats", c.ApiKeyMiddleware(c.GetStats))
+	http.HandleFunc("/api/get-funnel", c.ApiKeyMiddleware(c.GetFunnel))
+	http.HandleFunc("/api/run-backup", c.ApiKeyMiddleware(c.RunBackup))
+	http.HandleFunc("/api/get-content", c.ApiKeyMiddleware(c.GetContent))
+	http.HandleFunc("/api/update-content", c.ApiKeyMiddleware(c.UpdateContent))
+	http.HandleFunc("/health", c.Health)
+	http.HandleFunc("/admin/login", c.AdminLogin)
+	http.HandleFunc("/check-in", c.AdminCookieMiddleware(c.CheckIn))
+	http.HandleFunc("/check-in/count", c.AdminCookieMiddleware(c.GetCheckInCounts))
+	http.HandleFunc("/admin/stats", c.AdminCookieMiddleware(c.Stats))
+	http.HandleFunc("/admin/content", c.AdminCookieMiddleware(c.EditContent))
 	http.Handle("/favicon.ico", http.NotFoundHandler())
 
 	// Channel to listen for termination signals
@@ -157,24 +251,188 @@ func main() {
 	// Start the server in a goroutine
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("ListenAndServe(): %v", err)
+			fatal("error serving", err)
 		}
 	}()
-	log.Print("Server is running on port 8080...")
+	slog.Info("server is running", "addr", srv.Addr)
+
+	if metricsSrv != nil {
+		go func() {
+			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fatal("error serving metrics", err)
+			}
+		}()
+		slog.Info("metrics are being served", "addr", metricsSrv.Addr)
+	}
 
 	// Wait for a termination signal
 	<-quit
-	log.Print("Shutting down gracefully...")
+	slog.Info("shutting down gracefully")
 
 	// Create a context with a timeout for the shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
 	// Attempt a graceful shutdown
+	if metricsSrv != nil {
+		metricsSrv.Shutdown(ctx)
+	}
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		fatal("server forced to shutdown", err)
 	}
-	log.Print("Server stopped.")
+	slog.Info("server stopped")
+}
+
+// runCommand runs one of the admin commands against the database instead of
+// starting the server, e.g. `./wedding-rsvps seat -seed 7`.
+func runCommand(name string, args []string) error {
+	switch name {
+	case "seat":
+		db, err := sql.Open(database.DriverName, guestsDBFilePath)
+		if err != nil {
+			return fmt.Errorf("error opening up database: %v", err)
+		}
+		defer db.Close()
+
+		guestStore := database.NewGuestStore(db)
+		if err := guestStore.SetupDatabase(nil); err != nil {
+			return fmt.Errorf("error setting up database: %v", err)
+		}
+
+		return seating.RunCommand(guestStore, args, os.Stdout)
+	case "import-guests":
+		records, err := readCSV(csvPath)
+		if err != nil {
+			return fmt.Errorf("error reading csv: %v", err)
+		}
+
+		db, err := sql.Open(database.DriverName, guestsDBFilePath)
+		if err != nil {
+			return fmt.Errorf("error opening up database: %v", err)
+		}
+		defer db.Close()
+
+		guestStore := database.NewGuestStore(db)
+		if err := guestStore.SetupDatabase(nil); err != nil {
+			return fmt.Errorf("error setting up database: %v", err)
+		}
+
+		return guestlist.RunCommand(guestStore, records, args, os.Stdout)
+	case "decrypt":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: decrypt <encrypted file> <output file>")
+		}
+
+		cfg, err := config.LoadDefault()
+		if err != nil {
+			return err
+		}
+		backupKey, err := cfg.BackupKey()
+		if err != nil {
+			return err
+		}
+
+		return backup.DecryptFile(backupKey, args[0], args[1])
+	case "backup", "restore", "prune-backups":
+		cfg, err := config.LoadDefault()
+		if err != nil {
+			return err
+		}
+
+		backupTargets, err := newBackupTargets(cfg.Backup)
+		if err != nil {
+			return err
+		}
+		if len(backupTargets) == 0 {
+			return fmt.Errorf("no backup targets are set, set S3_BUCKET_BACKUPS or BACKUP_DIR")
+		}
+
+		backupKey, err := cfg.BackupKey()
+		if err != nil {
+			return err
+		}
+		retention := cfg.Backup.Retention()
+
+		switch name {
+		case "backup":
+			db, err := sql.Open(database.DriverName, guestsDBFilePath)
+			if err != nil {
+				return fmt.Errorf("error opening up database: %v", err)
+			}
+			defer db.Close()
+
+			if err := performBackups(db, backupTargets, backupKey, retention); err != nil {
+				return err
+			}
+			fmt.Println("Backup complete.")
+			return nil
+		case "restore":
+			return backup.RunRestoreCommand(backupTargets, backupKey, guestsDBFilePath, args, os.Stdout)
+		default:
+			return backup.RunPruneCommand(backupTargets, retention, args, os.Stdout)
+		}
+	case "config":
+		return config.RunCommand(args, os.Stdout)
+	default:
+		return fmt.Errorf("unknown command %q, available commands are: seat, backup, restore, prune-backups, decrypt, config", name)
+	}
+}
+
+// newBackupTargets sets up every configured backup target. Backups go to
+// each of them, so an S3 bucket and a local directory can be used together.
+func newBackupTargets(cfg config.BackupConfig) ([]backup.BackupTarget, error) {
+	var targets []backup.BackupTarget
+
+	if cfg.S3Bucket != "" {
+		s3Target, err := backup.NewS3Target(backup.S3Config{
+			Bucket:   cfg.S3Bucket,
+			Region:   cfg.S3Region,
+			Endpoint: cfg.S3Endpoint,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error setting up S3 backups: %v", err)
+		}
+		targets = append(targets, s3Target)
+	}
+
+	if cfg.Dir != "" {
+		dirTarget, err := backup.NewDirTarget(cfg.Dir)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, dirTarget)
+	}
+
+	return targets, nil
+}
+
+// loadContent returns the content guests see. The first time the server runs
+// with a content table it is filled in from the old VENUE_ADDRESS,
+// VENUE_TRAVEL_DETAILS, POST_CEREMONY_ITINERARY and FOOTER_MESSAGE settings,
+// after which it is edited at /admin/content.
+func loadContent(guestStore database.GuestStore, cfg *config.Config) (*models.Content, error) {
+	saved, err := guestStore.GetContent()
+	if err != nil || saved != nil {
+		return saved, err
+	}
+
+	seeded := content.FromSettings(
+		cfg.Wedding.VenueAddress,
+		cfg.Wedding.VenueTravelDetails,
+		cfg.Wedding.PostCeremonyItinerary,
+		cfg.Wedding.FooterMessage,
+	)
+	if err := guestStore.SaveContent(seeded); err != nil {
+		return nil, err
+	}
+	slog.Info("content created from settings")
+	return &seeded, nil
+}
+
+// fatal logs err and exits, as slog has no Fatal.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
 
 func readCSV(filePath string) ([][]string, error) {
@@ -185,6 +443,8 @@ func readCSV(filePath string) ([][]string, error) {
 	defer file.Close()
 
 	reader := csv.NewReader(file)
+	// Address columns are optional so rows may have differing lengths
+	reader.FieldsPerRecord = -1
 	records, err := reader.ReadAll()
 	if err != nil {
 		return nil, err
@@ -194,97 +454,199 @@ func readCSV(filePath string) ([][]string, error) {
 }
 
 func setNewLogFile(oldFile *os.File) (*os.File, error) {
-	oldFile.Close()
-
 	err := os.MkdirAll("logs", os.ModePerm)
 	if err != nil {
-		log.Println("error creating logs folder: ", err)
+		slog.Error("error creating logs folder", "error", err)
 		return nil, err
 	}
 
-	logFileName := fmt.Sprintf("logs/server_%s.log", time.Now().Format("2006-01-02"))
+	now := time.Now()
+	logFileName := fmt.Sprintf("logs/server_%s.log", now.Format("2006-01-02"))
+	if oldFile != nil && oldFile.Name() == logFileName {
+		// logs are being rotated more than once a day
+		logFileName = fmt.Sprintf("logs/server_%s.log", now.Format("2006-01-02T15-04-05"))
+	}
 	logFile, err := os.OpenFile(logFileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 	if err != nil {
 		return logFile, err
 	}
 
-	multiWriter := io.MultiWriter(os.Stdout, logFile)
-	log.SetOutput(multiWriter)
+	logOutput.Set(io.MultiWriter(os.Stdout, logFile))
+	oldFile.Close()
 
-	log.Println("new logger set")
+	slog.Info("new log file set", "file", logFileName)
 
 	return logFile, nil
 }
 
-func startMidnightTicker(s3Uploader *backup.S3Uploader, oldLogFile *os.File) {
-	serverStart := time.Now()
-	firstMidnight := serverStart.Truncate(backupTimeInterval).Add(backupTimeInterval)
-	durationUntilFirstMidnight := firstMidnight.Sub(serverStart)
+// newScheduler sets up log rotation and, when there is somewhere to send them,
+// backups on the schedules in LOG_ROTATION_SCHEDULE and BACKUP_SCHEDULE, which
+// both default to midnight UTC.
+func newScheduler(cfg *config.Config, db *sql.DB, backupTargets []backup.BackupTarget, backupKey []byte, logFile *os.File) (*schedule.Scheduler, error) {
+	scheduler := schedule.New()
 
-	time.Sleep(durationUntilFirstMidnight)
+	logRotationSchedule, err := schedule.Parse(cfg.Logging.RotationSchedule)
+	if err != nil {
+		return nil, fmt.Errorf("LOG_ROTATION_SCHEDULE: %v", err)
+	}
+
+	// log files which have been rotated but not archived yet, so a retry only
+	// archives them rather than rotating again
+	var unarchivedLogFiles []string
+	scheduler.Add(schedule.Job{
+		Name:     schedule.JobLogRotation,
+		Schedule: logRotationSchedule,
+		Run: func() error {
+			if len(unarchivedLogFiles) == 0 {
+				oldLogFileName := logFile.Name()
+				newLogFile, err := setNewLogFile(logFile)
+				if err != nil {
+					return err
+				}
+				logFile = newLogFile
+				unarchivedLogFiles = append(unarchivedLogFiles, oldLogFileName)
+			}
 
-	ticker := time.NewTicker(backupTimeInterval)
-	defer ticker.Stop()
+			var err error
+			unarchivedLogFiles, err = archiveLogFiles(backupTargets, backupKey, unarchivedLogFiles)
+			return err
+		},
+	})
 
-	logFile, err := setNewLogFile(oldLogFile)
+	if len(backupTargets) == 0 {
+		return scheduler, nil
+	}
+
+	backupSchedule, err := schedule.Parse(cfg.Backup.Schedule)
 	if err != nil {
-		log.Fatalf("error opening log file: %v", err)
+		return nil, fmt.Errorf("BACKUP_SCHEDULE: %v", err)
 	}
 
-	if s3Uploader != nil {
-		err = performBackups(s3Uploader)
-		if err != nil {
-			log.Printf("error performing backups: %v", err)
-		}
+	scheduler.Add(schedule.Job{
+		Name:     schedule.JobBackup,
+		Schedule: backupSchedule,
+		Run: func() error {
+			return performBackups(db, backupTargets, backupKey, cfg.Backup.Retention())
+		},
+	})
+
+	// incremental backups are off unless asked for, as outside the busy
+	// weeks a nightly backup is plenty
+	if cfg.Backup.IncrementalSchedule == "" {
+		return scheduler, nil
+	}
+	incrementalSchedule, err := schedule.Parse(cfg.Backup.IncrementalSchedule)
+	if err != nil {
+		return nil, fmt.Errorf("INCREMENTAL_BACKUP_SCHEDULE: %v", err)
 	}
 
-	for range ticker.C {
-		logFile, err = setNewLogFile(logFile)
-		if err != nil {
-			log.Printf("error opening log file: %v", err)
-		}
+	guestStore := database.NewGuestStore(db)
+	scheduler.Add(schedule.Job{
+		Name:     schedule.JobIncrementalBackup,
+		Schedule: incrementalSchedule,
+		Run: func() error {
+			return shipChanges(guestStore, backupTargets, backupKey)
+		},
+	})
+
+	return scheduler, nil
+}
 
-		if s3Uploader != nil {
-			err = performBackups(s3Uploader)
+// archiveLogFiles uploads each log file to every target, encrypted as they
+// contain guests' details, and returns the ones which failed.
+func archiveLogFiles(backupTargets []backup.BackupTarget, backupKey []byte, logFileNames []string) ([]string, error) {
+	var failed []string
+	var errs []error
+	for _, logFileName := range logFileNames {
+		uploaded := true
+		for _, target := range backupTargets {
+			err := backup.UploadEncrypted(target, backupKey, logFileName, logFileName)
 			if err != nil {
-				log.Printf("error performing backups: %v", err)
+				slog.Error("error uploading log file", "target", target.Name(), "file", logFileName, "error", err)
+				errs = append(errs, fmt.Errorf("log upload of %s to %s: %v", logFileName, target.Name(), err))
+				uploaded = false
 			}
 		}
+		if !uploaded {
+			failed = append(failed, logFileName)
+		}
 	}
 
-	defer logFile.Close()
+	return failed, errors.Join(errs...)
 }
 
-func performBackups(s3Uploader *backup.S3Uploader) error {
-	ydayDate := time.Now().Add(-backupTimeInterval).Format("2006-01-02")
-	oldLogFileName := fmt.Sprintf("logs/server_%s.log", ydayDate)
-	err := s3Uploader.UploadFile(oldLogFileName, oldLogFileName)
-	if err != nil {
-		log.Printf("error uploading log file %s to s3: %v", oldLogFileName, err)
+// shipChanges uploads the changes made since the last incremental backup to
+// every target.
+func shipChanges(guestStore database.GuestStore, backupTargets []backup.BackupTarget, backupKey []byte) error {
+	var errs []error
+	for _, target := range backupTargets {
+		shipped, err := backup.ShipChanges(target, backupKey, guestStore)
+		if shipped > 0 {
+			slog.Info("shipped changes", "target", target.Name(), "changes", shipped)
+		}
+		if err != nil {
+			slog.Error("error shipping changes", "target", target.Name(), "error", err)
+			errs = append(errs, fmt.Errorf("incremental backup to %s: %v", target.Name(), err))
+		}
 	}
 
-	dbBackupFileName := fmt.Sprintf("guests_%s.db", ydayDate)
-	dbBackupFilePath := fmt.Sprintf("/tmp/%s", dbBackupFileName)
-	err = backup.BackupDatabaseLocally(guestsDBFilePath, dbBackupFilePath)
-	if err != nil {
-		log.Printf("error creating db backup file %s: %v", dbBackupFileName, err)
-	}
+	return errors.Join(errs...)
+}
+
+// performBackups uploads a backup of the database to every target, encrypted
+// with backupKey as it contains guests' contact details. Backups are named after
+// the day they were taken, so a later backup the same day replaces the earlier.
+func performBackups(db *sql.DB, backupTargets []backup.BackupTarget, backupKey []byte, retention backup.Retention) error {
+	var failures []string
 
-	// backup the current db as well in case of a server restart and db gets deleted
-	err = s3Uploader.UploadFile(dbBackupFilePath, strings.TrimPrefix(guestsDBFilePath, "./"))
+	now := time.Now().UTC()
+	tmpDir, err := os.MkdirTemp("", "backup")
 	if err != nil {
-		log.Printf("error uploading db backup file %s to s3: %v", guestsDBFilePath, err)
+		return err
 	}
+	defer os.RemoveAll(tmpDir)
 
-	dbBackupS3FilePath := fmt.Sprintf("backup_dbs/%s", dbBackupFileName)
-	err = s3Uploader.UploadFile(dbBackupFilePath, dbBackupS3FilePath)
+	dbBackupFileName := fmt.Sprintf("guests_%s.db", now.Format("2006-01-02"))
+	dbBackupFilePath := filepath.Join(tmpDir, dbBackupFileName)
+	err = backup.BackupDatabaseLocally(db, dbBackupFilePath)
 	if err != nil {
-		log.Printf("error uploading db backup file %s to s3: %v", dbBackupS3FilePath, err)
+		// a failed or corrupt db backup must never replace a good one
+		slog.Error("error creating db backup file", "file", dbBackupFileName, "error", err)
+		failures = append(failures, "db backup")
+	} else {
+		for _, target := range backupTargets {
+			// backup the current db as well in case of a server restart and db gets deleted
+			err = backup.UploadEncrypted(target, backupKey, dbBackupFilePath, strings.TrimPrefix(guestsDBFilePath, "./"))
+			if err != nil {
+				slog.Error("error uploading db backup file", "target", target.Name(), "file", guestsDBFilePath, "error", err)
+				failures = append(failures, "current db upload to "+target.Name())
+			}
+
+			dbBackupKey := backup.DBBackupKey(now)
+			err = backup.UploadEncrypted(target, backupKey, dbBackupFilePath, dbBackupKey)
+			if err != nil {
+				slog.Error("error uploading db backup file", "target", target.Name(), "file", dbBackupKey, "error", err)
+				failures = append(failures, "db backup upload to "+target.Name())
+			} else if retention.Enabled() {
+				// only prune once the new backup is safely uploaded
+				pruned, prunedIncrements, err := backup.PruneBackups(target, retention, false)
+				for _, b := range pruned {
+					slog.Info("pruned db backup", "target", target.Name(), "file", b.Key)
+				}
+				for _, inc := range prunedIncrements {
+					slog.Info("pruned incremental backup", "target", target.Name(), "file", inc.Key)
+				}
+				if err != nil {
+					slog.Error("error pruning db backups", "target", target.Name(), "error", err)
+					failures = append(failures, "prune of "+target.Name())
+				}
+			}
+		}
 	}
 
-	err = os.Remove(dbBackupFilePath)
-	if err != nil {
-		log.Printf("error removing db backup file %s locally: %v", dbBackupFilePath, err)
+	metrics.BackupFinished(len(failures) == 0)
+	if len(failures) > 0 {
+		return fmt.Errorf("backup steps failed: %s", strings.Join(failures, ", "))
 	}
 
 	return nil